@@ -0,0 +1,21 @@
+package coworking
+
+import (
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+// TaskQueue is the task queue the coworking workflows run on
+const TaskQueue = "coworking"
+
+// NewWorker returns a worker on TaskQueue with the coworking workflows and
+// activities registered
+func NewWorker(c client.Client, options worker.Options) worker.Worker {
+	w := worker.New(c, TaskQueue, options)
+	w.RegisterWorkflow(BookingWorkflow)
+	w.RegisterWorkflow(RoomEntryWorkflow)
+	w.RegisterActivity(CheckAvailability)
+	w.RegisterActivity(ProcessPayment)
+	w.RegisterActivity(ValidateRoomAccess)
+	return w
+}
@@ -7,8 +7,19 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+
+	"github.com/leowmjw/go-durable-x/telemetry"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/worker"
+
+	"imleowla/internal/coworking"
 )
 
+// TemporalHostPortEnv is the Temporal frontend the coworking worker connects
+// to; unset runs the gateway without a worker
+const TemporalHostPortEnv = "TEMPORAL_HOSTPORT"
+
 // This will represent the Zuplo API Gateway; assuming Cloudflare is up
 // If issue; will use this raw Golang; need to find the correct tuning for handling
 // 1MM continuous concurrent per 30 mins; with minimal latency due to this layer
@@ -32,6 +43,35 @@ func Run() {
 
 	// Try to use what is available in the new routing .. --> https://go.dev/blog/routing-enhancements
 
+	// Metrics and traces of the coworking workflows and the gateway's calls
+	tel, err := telemetry.FromEnv("imleowla")
+	if err != nil {
+		fmt.Println("Invalid telemetry settings:", err)
+		os.Exit(1)
+	}
+	defer tel.Shutdown(context.Background())
+
+	if hostPort := os.Getenv(TemporalHostPortEnv); hostPort != "" {
+		c, err := client.Dial(client.Options{
+			HostPort:     hostPort,
+			Interceptors: []interceptor.ClientInterceptor{tel.ClientInterceptor()},
+		})
+		if err != nil {
+			fmt.Println("Unable to create Temporal client:", err)
+			os.Exit(1)
+		}
+		defer c.Close()
+
+		w := coworking.NewWorker(c, worker.Options{
+			Interceptors: []interceptor.WorkerInterceptor{tel.WorkerInterceptor()},
+		})
+		if err := w.Start(); err != nil {
+			fmt.Println("Unable to start worker:", err)
+			os.Exit(1)
+		}
+		defer w.Stop()
+	}
+
 	// Create a new ServeMux
 	mux := http.NewServeMux()
 
@@ -49,6 +89,7 @@ func Run() {
 		return
 	})
 	mux.HandleFunc("/", defaultHandler)
+	mux.Handle("GET /metrics", tel.Handler())
 	// Read in Default Not Found page .. cross sell .. Search
 	// Read in Default Error Page .. cross sell .. LLM + Customer Support ..
 	// LLM can use Cloudflare AI or AWS Bedrock; have both available ..
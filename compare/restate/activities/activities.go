@@ -4,38 +4,89 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"math/rand"
+	"net/http"
 	"time"
 
-	"github.com/davecgh/go-spew/spew"
+	restate "github.com/restatedev/sdk-go"
+
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
 	"github.com/leowmjw/go-durable-x/temporal/types"
 )
 
 // Activities implementation
 type Activities struct {
-	logger *slog.Logger
+	logger    *slog.Logger
+	providers *simulator.Client
+	faults    *scenario.Faults
 }
 
+// faults are injected into every provider call when a scenario is in use
+var faults *scenario.Faults
+
+// UseFaults makes activities created from now on inject f into their provider
+// calls. Nil turns injection off.
+func UseFaults(f *scenario.Faults) {
+	faults = f
+}
+
+// NewActivities returns activities that call the provider simulator at
+// simulator.URLFromEnv
 func NewActivities(logger *slog.Logger) *Activities {
+	return NewActivitiesWithProviders(logger, simulator.NewClient(simulator.URLFromEnv(), http.DefaultClient))
+}
+
+// NewActivitiesWithProviders returns activities that call the given provider
+// simulator
+func NewActivitiesWithProviders(logger *slog.Logger, providers *simulator.Client) *Activities {
 	return &Activities{
-		logger: logger,
+		logger:    logger,
+		providers: providers,
+		faults:    faults,
 	}
 }
 
-// Hotel Activities
-func (a *Activities) BookHotel(ctx context.Context, booking *types.HotelBooking) error {
-	fmt.Println("INSIDE BookHotel>>>>>>>>>>>")
-	spew.Dump(booking)
-	// Simulate external API call
-	time.Sleep(time.Second)
+// idempotencyKey identifies a provider call made by the Restate invocation in
+// ctx. Restate keeps the invocation ID across retries, so a retried call
+// carries the same key. Outside an invocation there is no key.
+func idempotencyKey(ctx context.Context, operation string) string {
+	rctx, ok := ctx.(restate.RunContext)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%x/%s", rctx.Request().ID, operation)
+}
 
-	// Simulate random failure
-	if rand.Float32() < 0.2 { // 20% chance of failure
-		return fmt.Errorf("hotel booking failed: service unavailable")
+// RunHeader carries the invocation ID of the BookTravel run a booking step
+// belongs to, so scenario faults are counted per booking run
+const RunHeader = "x-booking-run"
+
+// faultRun identifies the booking run of the Restate invocation in ctx: the
+// run named by RunHeader, or the invocation itself when it was called on its
+// own, so every run replays the scenario from its first attempt
+func faultRun(ctx context.Context) string {
+	rctx, ok := ctx.(restate.RunContext)
+	if !ok {
+		return ""
 	}
+	if run := rctx.Request().Headers[RunHeader]; run != "" {
+		return run
+	}
+	return fmt.Sprintf("%x", rctx.Request().ID)
+}
 
-	booking.BookingRef = fmt.Sprintf("HTL-%d", rand.Int31())
-	booking.Status = types.StatusConfirmed
+// Hotel Activities
+func (a *Activities) BookHotel(ctx context.Context, booking *types.HotelBooking) error {
+	if err := a.faults.Check(faultRun(ctx), types.ComponentHotel, scenario.OperationBook); err != nil {
+		return fmt.Errorf("hotel booking failed: %w", err)
+	}
+	confirmation, err := a.providers.Book(ctx, types.ComponentHotel, idempotencyKey(ctx, "book-hotel"),
+		simulator.BookingRequest{Item: booking.HotelID, Price: booking.Price})
+	if err != nil {
+		return fmt.Errorf("hotel booking failed: %w", err)
+	}
+	booking.BookingRef = confirmation.Ref
+	booking.Status = confirmation.Status
 
 	a.logger.Info("Hotel booked successfully",
 		slog.String("booking_ref", booking.BookingRef),
@@ -45,9 +96,12 @@ func (a *Activities) BookHotel(ctx context.Context, booking *types.HotelBooking)
 }
 
 func (a *Activities) CancelHotel(ctx context.Context, bookingRef string) error {
-	fmt.Println("INSIDE CancelHotel>>>>>>>>>>>")
-	// Simulate external API call
-	time.Sleep(time.Second)
+	if err := a.faults.Check(faultRun(ctx), types.ComponentHotel, scenario.OperationCancel); err != nil {
+		return fmt.Errorf("hotel cancellation failed: %w", err)
+	}
+	if err := a.providers.Cancel(ctx, types.ComponentHotel, idempotencyKey(ctx, "cancel-hotel"), bookingRef); err != nil {
+		return fmt.Errorf("hotel cancellation failed: %w", err)
+	}
 
 	a.logger.Info("Hotel booking cancelled",
 		slog.String("booking_ref", bookingRef))
@@ -57,18 +111,16 @@ func (a *Activities) CancelHotel(ctx context.Context, bookingRef string) error {
 
 // Flight Activities
 func (a *Activities) BookFlight(ctx context.Context, booking *types.FlightBooking) error {
-	fmt.Println("INSIDE BookFlight>>>>>>>>>>>")
-	spew.Dump(booking)
-	// Simulate external API call
-	time.Sleep(time.Second)
-
-	// Simulate random failure
-	if rand.Float32() < 0.2 { // 20% chance of failure
-		return fmt.Errorf("flight booking failed: no seats available")
+	if err := a.faults.Check(faultRun(ctx), types.ComponentFlight, scenario.OperationBook); err != nil {
+		return fmt.Errorf("flight booking failed: %w", err)
 	}
-
-	booking.BookingRef = fmt.Sprintf("FLT-%d", rand.Int31())
-	booking.Status = types.StatusConfirmed
+	confirmation, err := a.providers.Book(ctx, types.ComponentFlight, idempotencyKey(ctx, "book-flight"),
+		simulator.BookingRequest{Item: booking.FlightNumber, Price: booking.Price})
+	if err != nil {
+		return fmt.Errorf("flight booking failed: %w", err)
+	}
+	booking.BookingRef = confirmation.Ref
+	booking.Status = confirmation.Status
 
 	a.logger.Info("Flight booked successfully",
 		slog.String("booking_ref", booking.BookingRef),
@@ -78,9 +130,12 @@ func (a *Activities) BookFlight(ctx context.Context, booking *types.FlightBookin
 }
 
 func (a *Activities) CancelFlight(ctx context.Context, bookingRef string) error {
-	fmt.Println("INSIDE CancelFlight>>>>>>>>>>>")
-	// Simulate external API call
-	time.Sleep(time.Second)
+	if err := a.faults.Check(faultRun(ctx), types.ComponentFlight, scenario.OperationCancel); err != nil {
+		return fmt.Errorf("flight cancellation failed: %w", err)
+	}
+	if err := a.providers.Cancel(ctx, types.ComponentFlight, idempotencyKey(ctx, "cancel-flight"), bookingRef); err != nil {
+		return fmt.Errorf("flight cancellation failed: %w", err)
+	}
 
 	a.logger.Info("Flight booking cancelled",
 		slog.String("booking_ref", bookingRef))
@@ -90,18 +145,16 @@ func (a *Activities) CancelFlight(ctx context.Context, bookingRef string) error
 
 // Car Activities
 func (a *Activities) BookCar(ctx context.Context, booking *types.CarBooking) error {
-	fmt.Println("INSIDE BookCar>>>>>>>>>>>")
-	spew.Dump(booking)
-	// Simulate external API call
-	time.Sleep(time.Second)
-
-	// Simulate random failure
-	if rand.Float32() < 0.2 { // 20% chance of failure
-		return fmt.Errorf("car booking failed: no cars available")
+	if err := a.faults.Check(faultRun(ctx), types.ComponentCar, scenario.OperationBook); err != nil {
+		return fmt.Errorf("car booking failed: %w", err)
 	}
-
-	booking.BookingRef = fmt.Sprintf("CAR-%d", rand.Int31())
-	booking.Status = types.StatusConfirmed
+	confirmation, err := a.providers.Book(ctx, types.ComponentCar, idempotencyKey(ctx, "book-car"),
+		simulator.BookingRequest{Item: booking.CarType, Price: booking.Price})
+	if err != nil {
+		return fmt.Errorf("car booking failed: %w", err)
+	}
+	booking.BookingRef = confirmation.Ref
+	booking.Status = confirmation.Status
 
 	a.logger.Info("Car booked successfully",
 		slog.String("booking_ref", booking.BookingRef),
@@ -111,10 +164,12 @@ func (a *Activities) BookCar(ctx context.Context, booking *types.CarBooking) err
 }
 
 func (a *Activities) CancelCar(ctx context.Context, bookingRef string) error {
-	fmt.Println("INSIDE CancelCar>>>>>>>>>>>")
-	fmt.Println("bookingRef", bookingRef)
-	// Simulate external API call
-	time.Sleep(time.Second)
+	if err := a.faults.Check(faultRun(ctx), types.ComponentCar, scenario.OperationCancel); err != nil {
+		return fmt.Errorf("car cancellation failed: %w", err)
+	}
+	if err := a.providers.Cancel(ctx, types.ComponentCar, idempotencyKey(ctx, "cancel-car"), bookingRef); err != nil {
+		return fmt.Errorf("car cancellation failed: %w", err)
+	}
 
 	a.logger.Info("Car booking cancelled",
 		slog.String("booking_ref", bookingRef))
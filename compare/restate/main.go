@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
@@ -13,6 +14,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/leowmjw/go-durable-x/restate/activities"
 
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
 	"github.com/leowmjw/go-durable-x/temporal/types"
 	restate "github.com/restatedev/sdk-go"
 	"github.com/restatedev/sdk-go/server"
@@ -38,6 +40,16 @@ type TravelBookingService struct {
 	funcSendEmail    func(ctx context.Context, to, subject, body string) error
 }
 
+// providerError stops Restate retrying a provider failure that cannot
+// succeed on retry, such as no availability
+func providerError(err error) error {
+	var failure types.BookingError
+	if errors.As(err, &failure) && !failure.Kind.Retryable() {
+		return restate.TerminalError(err, http.StatusConflict)
+	}
+	return err
+}
+
 // BookHotel handles hotel booking
 func (s *TravelBookingService) BookHotel(ctx restate.Context, booking *types.HotelBooking) error {
 	if s.funcBookHotel == nil {
@@ -46,7 +58,7 @@ func (s *TravelBookingService) BookHotel(ctx restate.Context, booking *types.Hot
 	//restate.Run()
 	s.logger.Info("booking hotel", "hotelId", booking.HotelID)
 	if err := s.funcBookHotel(ctx, booking); err != nil {
-		return fmt.Errorf("funcBookHotel: %w", err)
+		return providerError(fmt.Errorf("funcBookHotel: %w", err))
 	}
 	// Attach the data ..
 	s.bookingDetails.HotelBooking = booking
@@ -73,8 +85,10 @@ func (s *TravelBookingService) BookFlight(ctx restate.Context, booking *types.Fl
 		return restate.TerminalError(fmt.Errorf("funcBookFlight is nil"), 4404)
 	}
 	s.logger.Info("booking flight", "flightNumber", booking.FlightNumber)
-	booking.Status = types.StatusConfirmed
-	booking.BookingRef = "F123" // Simulated booking reference
+	if err := s.funcBookFlight(ctx, booking); err != nil {
+		return providerError(fmt.Errorf("funcBookFlight: %w", err))
+	}
+	s.bookingDetails.FlightBooking = booking
 	return nil
 }
 
@@ -98,8 +112,10 @@ func (s *TravelBookingService) BookCar(ctx restate.Context, booking *types.CarBo
 		return restate.TerminalError(fmt.Errorf("funcBookCar is nil"), 4404)
 	}
 	s.logger.Info("booking car", "carType", booking.CarType)
-	booking.Status = types.StatusConfirmed
-	booking.BookingRef = "C123" // Simulated booking reference
+	if err := s.funcBookCar(ctx, booking); err != nil {
+		return providerError(fmt.Errorf("funcBookCar: %w", err))
+	}
+	s.bookingDetails.CarBooking = booking
 	return nil
 }
 
@@ -187,20 +203,24 @@ func (s *TravelBookingService) BookTravel(ctx restate.Context, booking types.Tra
 	// }
 	// Attach teh data from the example calls
 
+	// Every step carries this run's invocation ID, so scenario faults count
+	// the attempts of this booking from the first
+	run := restate.WithHeaders(map[string]string{activities.RunHeader: fmt.Sprintf("%x", ctx.Request().ID)})
+
 	// Book hotel
-	if _, err := restate.Service[restate.Void](ctx, ServiceName, "BookHotel").Request(booking.HotelBooking); err != nil {
+	if _, err := restate.Service[restate.Void](ctx, ServiceName, "BookHotel").Request(booking.HotelBooking, run); err != nil {
 		s.logger.Error("failed to book hotel", "error", err)
 		s.bookingDetails.Status = types.StatusFailed
 
 		return err
 	}
 	// Book flight
-	if _, err := restate.Service[restate.Void](ctx, ServiceName, "BookFlight").Request(booking.FlightBooking); err != nil {
+	if _, err := restate.Service[restate.Void](ctx, ServiceName, "BookFlight").Request(booking.FlightBooking, run); err != nil {
 		s.logger.Error("failed to book flight", "error", err)
 		s.bookingDetails.Status = types.StatusFailed
 
 		// Compensate hotel booking
-		if _, cerr := restate.Service[restate.Void](ctx, ServiceName, "CancelHotel").Request(booking.HotelBooking.BookingRef); cerr != nil {
+		if _, cerr := restate.Service[restate.Void](ctx, ServiceName, "CancelHotel").Request(booking.HotelBooking.BookingRef, run); cerr != nil {
 			s.logger.Error("failed to cancel hotel", "error", cerr)
 			return cerr
 		}
@@ -231,17 +251,29 @@ func (s *TravelBookingService) BookTravel(ctx restate.Context, booking types.Tra
 
 func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	// Inject the faults of a scenario script or seed into provider calls
+	faults, err := scenario.FromEnv()
+	if err != nil {
+		logger.Error("invalid fault scenario", "error", err)
+		os.Exit(1)
+	}
+	if faults != nil {
+		activities.UseFaults(faults)
+		logger.Info("injecting scenario faults", "scenario", faults.Script().Name, "seed", faults.Script().Seed)
+	}
+
 	a := activities.NewActivities(logger)
 	svc := &TravelBookingService{
-		logger:         logger,
-		bookingDetails: types.TravelBooking{},
-		funcBookHotel:  a.BookHotel,
-		// funcCancelHotel:  a.CancelHotel,
-		funcBookFlight: a.BookFlight,
-		// funcCancelFlight: a.CancelFlight,
-		// funcBookCar:      a.BookCar,
-		// funcCancelCar:    a.CancelCar,
-		// funcSendEmail:    a.SendEmail,
+		logger:           logger,
+		bookingDetails:   types.TravelBooking{},
+		funcBookHotel:    a.BookHotel,
+		funcCancelHotel:  a.CancelHotel,
+		funcBookFlight:   a.BookFlight,
+		funcCancelFlight: a.CancelFlight,
+		funcBookCar:      a.BookCar,
+		funcCancelCar:    a.CancelCar,
+		funcSendEmail:    a.SendEmail,
 	}
 
 	// Start Restate server in a goroutine
@@ -2,48 +2,238 @@ package activities
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log/slog"
-	"math/rand"
+	"net/http"
 	"time"
 
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/leowmjw/go-durable-x/temporal/breaker"
+	"github.com/leowmjw/go-durable-x/temporal/notification"
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
 	"github.com/leowmjw/go-durable-x/temporal/types"
 )
 
 // Activities implementation
 type Activities struct {
-	logger *slog.Logger
+	logger    *slog.Logger
+	providers *simulator.Client
+	payments  *PaymentProvider
+	faults    *scenario.Faults
+	breakers  *breaker.Set
+	notifier  *notification.Notifier
+}
+
+// faults are injected into every provider call when a scenario is in use
+var faults *scenario.Faults
+
+// breakers guard every provider call when circuit breaking is in use
+var breakers *breaker.Set
+
+// notifier sends every notification; it only logs until UseNotifier is called
+var notifier = notification.Default()
+
+// UseNotifier makes activities created from now on send notifications
+// through n
+func UseNotifier(n *notification.Notifier) {
+	notifier = n
 }
 
+// UseFaults makes activities created from now on inject f into their provider
+// calls. Nil turns injection off.
+func UseFaults(f *scenario.Faults) {
+	faults = f
+}
+
+// UseBreakers makes activities created from now on call providers through
+// the breakers of s, shared by all of them. Nil turns circuit breaking off.
+func UseBreakers(s *breaker.Set) {
+	breakers = s
+}
+
+// NewActivities returns activities that call the provider simulator at
+// simulator.URLFromEnv
 func NewActivities(logger *slog.Logger) *Activities {
+	return NewActivitiesWithProviders(logger, simulator.NewClient(simulator.URLFromEnv(), http.DefaultClient))
+}
+
+// NewActivitiesWithProviders returns activities that call the given provider
+// simulator
+func NewActivitiesWithProviders(logger *slog.Logger, providers *simulator.Client) *Activities {
 	return &Activities{
-		logger: logger,
+		logger:    logger,
+		providers: providers,
+		payments:  payments,
+		faults:    faults,
+		breakers:  breakers,
+		notifier:  notifier,
 	}
 }
 
-// Hotel Activities
-func (a *Activities) BookHotel(ctx context.Context, booking *types.HotelBooking) error {
-	// Simulate external API call
-	time.Sleep(time.Second)
+// faultRun identifies the workflow run of the activity in ctx, so every run
+// replays the scenario from its first attempt
+func faultRun(ctx context.Context) string {
+	info := activity.GetInfo(ctx)
+	return info.WorkflowExecution.ID + "/" + info.WorkflowExecution.RunID
+}
+
+// call runs fn against provider through its circuit breaker. An open
+// breaker fails the call at once with a retryable ErrCircuitOpen; failures
+// that retrying might fix count towards opening it. Errors record the
+// attempt of the activity running in ctx. The breaker hears how the call
+// went even if fn panics, which counts as a failure.
+func (a *Activities) call(ctx context.Context, provider string, fn func() error) error {
+	if err := a.breakers.Allow(provider); err != nil {
+		return withAttempt(ctx, providerError(types.NewBookingError(provider, types.ErrCircuitOpen, "%v", err)))
+	}
+	failed := true
+	defer func() { a.breakers.Done(provider, failed) }()
+
+	err := fn()
+	failed = err != nil && providerFailure(provider, err).Kind.Retryable()
+	if err != nil {
+		return withAttempt(ctx, clientError(provider, err))
+	}
+	return nil
+}
+
+// book makes a booking with provider under the idempotency key of the running
+// activity, so a retry of a call that already booked gets the original
+// confirmation back
+func (a *Activities) book(ctx context.Context, provider, item string, price float64) (types.BookingConfirmation, error) {
+	var booking simulator.Booking
+	err := a.call(ctx, provider, func() (err error) {
+		if err := a.faults.Check(faultRun(ctx), provider, scenario.OperationBook); err != nil {
+			return err
+		}
+		booking, err = a.providers.Book(ctx, provider, IdempotencyKey(ctx), simulator.BookingRequest{Item: item, Price: price})
+		return err
+	})
+	if err != nil {
+		return types.BookingConfirmation{}, err
+	}
+	return booking.Confirmation(), nil
+}
+
+// cancel cancels a booking with provider under the idempotency key of the
+// running activity
+func (a *Activities) cancel(ctx context.Context, provider, bookingRef string) error {
+	return a.call(ctx, provider, func() error {
+		if err := a.faults.Check(faultRun(ctx), provider, scenario.OperationCancel); err != nil {
+			return err
+		}
+		return a.providers.Cancel(ctx, provider, IdempotencyKey(ctx), bookingRef)
+	})
+}
+
+// hold sets item aside with provider for holdFor under the idempotency key of
+// the running activity
+func (a *Activities) hold(ctx context.Context, provider, item string, price float64, holdFor time.Duration) (types.BookingConfirmation, error) {
+	var hold simulator.Booking
+	err := a.call(ctx, provider, func() (err error) {
+		if err := a.faults.Check(faultRun(ctx), provider, scenario.OperationHold); err != nil {
+			return err
+		}
+		hold, err = a.providers.Hold(ctx, provider, IdempotencyKey(ctx), simulator.HoldRequest{Item: item, Price: price, HoldFor: holdFor.String()})
+		return err
+	})
+	if err != nil {
+		return types.BookingConfirmation{}, err
+	}
+
+	a.logger.Info("Hold placed",
+		slog.String("component", provider),
+		slog.String("hold_ref", hold.Ref),
+		slog.Time("expires_at", hold.ExpiresAt))
+
+	return hold.Confirmation(), nil
+}
+
+// confirmHold turns a hold with provider into a booking
+func (a *Activities) confirmHold(ctx context.Context, provider, holdRef string) (types.BookingConfirmation, error) {
+	var booking simulator.Booking
+	err := a.call(ctx, provider, func() (err error) {
+		if err := a.faults.Check(faultRun(ctx), provider, scenario.OperationConfirm); err != nil {
+			return err
+		}
+		booking, err = a.providers.Confirm(ctx, provider, IdempotencyKey(ctx), holdRef)
+		return err
+	})
+	if err != nil {
+		return types.BookingConfirmation{}, err
+	}
+
+	a.logger.Info("Hold confirmed",
+		slog.String("component", provider),
+		slog.String("booking_ref", booking.Ref))
 
-	// Simulate random failure
-	if rand.Float32() < 0.2 { // 20% chance of failure
-		return fmt.Errorf("hotel booking failed: service unavailable")
+	return booking.Confirmation(), nil
+}
+
+// release gives a hold back to provider
+func (a *Activities) release(ctx context.Context, provider, holdRef string) error {
+	err := a.call(ctx, provider, func() error {
+		if err := a.faults.Check(faultRun(ctx), provider, scenario.OperationRelease); err != nil {
+			return err
+		}
+		return a.providers.Release(ctx, provider, IdempotencyKey(ctx), holdRef)
+	})
+	if err != nil {
+		return err
 	}
 
-	booking.BookingRef = fmt.Sprintf("HTL-%d", rand.Int31())
-	booking.Status = types.StatusConfirmed
+	a.logger.Info("Hold released",
+		slog.String("component", provider),
+		slog.String("hold_ref", holdRef))
+
+	return nil
+}
+
+// verify looks up a booking with provider to see whether it still stands
+func (a *Activities) verify(ctx context.Context, provider, bookingRef string) (types.BookingConfirmation, error) {
+	var booking simulator.Booking
+	err := a.call(ctx, provider, func() (err error) {
+		booking, err = a.providers.Booking(ctx, provider, bookingRef)
+		return err
+	})
+	if err != nil {
+		return types.BookingConfirmation{}, err
+	}
+
+	a.logger.Info("Booking verified",
+		slog.String("component", provider),
+		slog.String("booking_ref", bookingRef),
+		slog.String("status", string(booking.Status)))
+
+	return booking.Confirmation(), nil
+}
+
+// Hotel Activities
+func (a *Activities) BookHotel(ctx context.Context, booking *types.HotelBooking) (types.BookingConfirmation, error) {
+	if booking == nil || booking.HotelID == "" {
+		return types.BookingConfirmation{}, providerError(types.NewBookingError(types.ComponentHotel, types.ErrInvalidRequest,
+			"hotel booking failed: missing HotelID"))
+	}
+
+	confirmation, err := a.book(ctx, types.ComponentHotel, booking.HotelID, booking.Price)
+	if err != nil {
+		return types.BookingConfirmation{}, err
+	}
 
 	a.logger.Info("Hotel booked successfully",
-		slog.String("booking_ref", booking.BookingRef),
+		slog.String("booking_ref", confirmation.BookingRef),
 		slog.String("hotel_id", booking.HotelID))
 
-	return nil
+	return confirmation, nil
 }
 
 func (a *Activities) CancelHotel(ctx context.Context, bookingRef string) error {
-	// Simulate external API call
-	time.Sleep(time.Second)
+	if err := a.cancel(ctx, types.ComponentHotel, bookingRef); err != nil {
+		return err
+	}
 
 	a.logger.Info("Hotel booking cancelled",
 		slog.String("booking_ref", bookingRef))
@@ -51,29 +241,49 @@ func (a *Activities) CancelHotel(ctx context.Context, bookingRef string) error {
 	return nil
 }
 
-// Flight Activities
-func (a *Activities) BookFlight(ctx context.Context, booking *types.FlightBooking) error {
-	// Simulate external API call
-	time.Sleep(time.Second)
+func (a *Activities) VerifyHotel(ctx context.Context, bookingRef string) (types.BookingConfirmation, error) {
+	return a.verify(ctx, types.ComponentHotel, bookingRef)
+}
+
+func (a *Activities) HoldHotel(ctx context.Context, booking *types.HotelBooking, holdFor time.Duration) (types.BookingConfirmation, error) {
+	if booking == nil || booking.HotelID == "" {
+		return types.BookingConfirmation{}, providerError(types.NewBookingError(types.ComponentHotel, types.ErrInvalidRequest,
+			"hotel hold failed: missing HotelID"))
+	}
+	return a.hold(ctx, types.ComponentHotel, booking.HotelID, booking.Price, holdFor)
+}
 
-	// Simulate random failure
-	if rand.Float32() < 0.2 { // 20% chance of failure
-		return fmt.Errorf("flight booking failed: no seats available")
+func (a *Activities) ConfirmHotel(ctx context.Context, holdRef string) (types.BookingConfirmation, error) {
+	return a.confirmHold(ctx, types.ComponentHotel, holdRef)
+}
+
+func (a *Activities) ReleaseHotel(ctx context.Context, holdRef string) error {
+	return a.release(ctx, types.ComponentHotel, holdRef)
+}
+
+// Flight Activities
+func (a *Activities) BookFlight(ctx context.Context, booking *types.FlightBooking) (types.BookingConfirmation, error) {
+	if booking == nil || booking.FlightNumber == "" {
+		return types.BookingConfirmation{}, providerError(types.NewBookingError(types.ComponentFlight, types.ErrInvalidRequest,
+			"flight booking failed: missing FlightNumber"))
 	}
 
-	booking.BookingRef = fmt.Sprintf("FLT-%d", rand.Int31())
-	booking.Status = types.StatusConfirmed
+	confirmation, err := a.book(ctx, types.ComponentFlight, booking.FlightNumber, booking.Price)
+	if err != nil {
+		return types.BookingConfirmation{}, err
+	}
 
 	a.logger.Info("Flight booked successfully",
-		slog.String("booking_ref", booking.BookingRef),
+		slog.String("booking_ref", confirmation.BookingRef),
 		slog.String("flight_number", booking.FlightNumber))
 
-	return nil
+	return confirmation, nil
 }
 
 func (a *Activities) CancelFlight(ctx context.Context, bookingRef string) error {
-	// Simulate external API call
-	time.Sleep(time.Second)
+	if err := a.cancel(ctx, types.ComponentFlight, bookingRef); err != nil {
+		return err
+	}
 
 	a.logger.Info("Flight booking cancelled",
 		slog.String("booking_ref", bookingRef))
@@ -81,29 +291,49 @@ func (a *Activities) CancelFlight(ctx context.Context, bookingRef string) error
 	return nil
 }
 
-// Car Activities
-func (a *Activities) BookCar(ctx context.Context, booking *types.CarBooking) error {
-	// Simulate external API call
-	time.Sleep(time.Second)
+func (a *Activities) VerifyFlight(ctx context.Context, bookingRef string) (types.BookingConfirmation, error) {
+	return a.verify(ctx, types.ComponentFlight, bookingRef)
+}
 
-	// Simulate random failure
-	if rand.Float32() < 0.2 { // 20% chance of failure
-		return fmt.Errorf("car booking failed: no cars available")
+func (a *Activities) HoldFlight(ctx context.Context, booking *types.FlightBooking, holdFor time.Duration) (types.BookingConfirmation, error) {
+	if booking == nil || booking.FlightNumber == "" {
+		return types.BookingConfirmation{}, providerError(types.NewBookingError(types.ComponentFlight, types.ErrInvalidRequest,
+			"flight hold failed: missing FlightNumber"))
 	}
+	return a.hold(ctx, types.ComponentFlight, booking.FlightNumber, booking.Price, holdFor)
+}
 
-	booking.BookingRef = fmt.Sprintf("CAR-%d", rand.Int31())
-	booking.Status = types.StatusConfirmed
+func (a *Activities) ConfirmFlight(ctx context.Context, holdRef string) (types.BookingConfirmation, error) {
+	return a.confirmHold(ctx, types.ComponentFlight, holdRef)
+}
+
+func (a *Activities) ReleaseFlight(ctx context.Context, holdRef string) error {
+	return a.release(ctx, types.ComponentFlight, holdRef)
+}
+
+// Car Activities
+func (a *Activities) BookCar(ctx context.Context, booking *types.CarBooking) (types.BookingConfirmation, error) {
+	if booking == nil || booking.CarType == "" {
+		return types.BookingConfirmation{}, providerError(types.NewBookingError(types.ComponentCar, types.ErrInvalidRequest,
+			"car booking failed: missing CarType"))
+	}
+
+	confirmation, err := a.book(ctx, types.ComponentCar, booking.CarType, booking.Price)
+	if err != nil {
+		return types.BookingConfirmation{}, err
+	}
 
 	a.logger.Info("Car booked successfully",
-		slog.String("booking_ref", booking.BookingRef),
+		slog.String("booking_ref", confirmation.BookingRef),
 		slog.String("car_type", booking.CarType))
 
-	return nil
+	return confirmation, nil
 }
 
 func (a *Activities) CancelCar(ctx context.Context, bookingRef string) error {
-	// Simulate external API call
-	time.Sleep(time.Second)
+	if err := a.cancel(ctx, types.ComponentCar, bookingRef); err != nil {
+		return err
+	}
 
 	a.logger.Info("Car booking cancelled",
 		slog.String("booking_ref", bookingRef))
@@ -111,14 +341,43 @@ func (a *Activities) CancelCar(ctx context.Context, bookingRef string) error {
 	return nil
 }
 
+func (a *Activities) VerifyCar(ctx context.Context, bookingRef string) (types.BookingConfirmation, error) {
+	return a.verify(ctx, types.ComponentCar, bookingRef)
+}
+
+func (a *Activities) HoldCar(ctx context.Context, booking *types.CarBooking, holdFor time.Duration) (types.BookingConfirmation, error) {
+	if booking == nil || booking.CarType == "" {
+		return types.BookingConfirmation{}, providerError(types.NewBookingError(types.ComponentCar, types.ErrInvalidRequest,
+			"car hold failed: missing CarType"))
+	}
+	return a.hold(ctx, types.ComponentCar, booking.CarType, booking.Price, holdFor)
+}
+
+func (a *Activities) ConfirmCar(ctx context.Context, holdRef string) (types.BookingConfirmation, error) {
+	return a.confirmHold(ctx, types.ComponentCar, holdRef)
+}
+
+func (a *Activities) ReleaseCar(ctx context.Context, holdRef string) error {
+	return a.release(ctx, types.ComponentCar, holdRef)
+}
+
 // Notification Activities
-func (a *Activities) SendEmail(ctx context.Context, to string, subject string, body string) error {
-	// Simulate sending email
-	time.Sleep(time.Second)
 
-	a.logger.Info("Email sent",
-		slog.String("to", to),
-		slog.String("subject", subject))
+// SendNotification renders msg and sends it through every transport, under
+// the idempotency key of the running activity so a retry only resends
+// through the transports that failed
+func (a *Activities) SendNotification(ctx context.Context, msg notification.Message) error {
+	if err := a.notifier.Send(ctx, IdempotencyKey(ctx), msg); err != nil {
+		if errors.Is(err, notification.ErrUnknownEvent) || errors.Is(err, notification.ErrInvalidHeader) {
+			return temporal.NewNonRetryableApplicationError(err.Error(), string(types.ErrInvalidRequest), err)
+		}
+		return err
+	}
+
+	a.logger.Info("Notification sent",
+		slog.String("booking_id", msg.BookingID),
+		slog.String("event", string(msg.Event)),
+		slog.String("subject", msg.Subject))
 
 	return nil
 }
@@ -0,0 +1,295 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+
+	"github.com/leowmjw/go-durable-x/temporal/breaker"
+	"github.com/leowmjw/go-durable-x/temporal/notification"
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// newTestActivities returns activities calling a fresh provider simulator,
+// and a client to inspect or set up that simulator
+func newTestActivities(t *testing.T) (*Activities, *simulator.Client) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := httptest.NewServer(simulator.NewServer(logger).Handler())
+	t.Cleanup(server.Close)
+	providers := simulator.NewClient(server.URL, server.Client())
+	return NewActivitiesWithProviders(logger, providers), providers
+}
+
+func TestActivities_BookAndCancelWithSimulator(t *testing.T) {
+	a, providers := newTestActivities(t)
+	env := (&testsuite.WorkflowTestSuite{}).NewTestActivityEnvironment()
+	env.RegisterActivity(a)
+
+	result, err := env.ExecuteActivity(a.BookCar, &types.CarBooking{CarType: "SUV", Price: 100})
+	require.NoError(t, err)
+	var confirmation types.BookingConfirmation
+	require.NoError(t, result.Get(&confirmation))
+	require.Equal(t, "CAR-1", confirmation.BookingRef)
+	require.Equal(t, types.StatusConfirmed, confirmation.Status)
+
+	result, err = env.ExecuteActivity(a.VerifyCar, confirmation.BookingRef)
+	require.NoError(t, err)
+	var verified types.BookingConfirmation
+	require.NoError(t, result.Get(&verified))
+	require.Equal(t, types.StatusConfirmed, verified.Status)
+
+	_, err = env.ExecuteActivity(a.CancelCar, confirmation.BookingRef)
+	require.NoError(t, err)
+
+	result, err = env.ExecuteActivity(a.VerifyCar, confirmation.BookingRef)
+	require.NoError(t, err)
+	require.NoError(t, result.Get(&verified))
+	require.Equal(t, types.StatusCancelled, verified.Status)
+
+	booking, err := providers.Booking(context.Background(), types.ComponentCar, confirmation.BookingRef)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusCancelled, booking.Status)
+}
+
+func TestActivities_ProviderFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(providers *simulator.Client) error
+		wantKind     types.ErrorKind
+		nonRetryable bool
+	}{
+		{
+			name: "sold out",
+			setup: func(providers *simulator.Client) error {
+				return providers.SetInventory(context.Background(), types.ComponentFlight, "FL123", 0)
+			},
+			wantKind:     types.ErrNotAvailable,
+			nonRetryable: true,
+		},
+		{
+			name: "provider down",
+			setup: func(providers *simulator.Client) error {
+				return providers.SetFault(context.Background(), types.ComponentFlight, simulator.Fault{FailNext: 1})
+			},
+			wantKind: types.ErrProviderDown,
+		},
+		{
+			name: "rate limited",
+			setup: func(providers *simulator.Client) error {
+				return providers.SetFault(context.Background(), types.ComponentFlight,
+					simulator.Fault{FailNext: 1, ErrorKind: types.ErrRateLimited})
+			},
+			wantKind: types.ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, providers := newTestActivities(t)
+			require.NoError(t, tt.setup(providers))
+			env := (&testsuite.WorkflowTestSuite{}).NewTestActivityEnvironment()
+			env.RegisterActivity(a)
+
+			_, err := env.ExecuteActivity(a.BookFlight, &types.FlightBooking{FlightNumber: "FL123", Price: 500})
+
+			var appErr *temporal.ApplicationError
+			require.ErrorAs(t, err, &appErr)
+			require.Equal(t, string(tt.wantKind), appErr.Type())
+			require.Equal(t, tt.nonRetryable, appErr.NonRetryable())
+
+			// The failure records the attempt it happened on
+			var details types.BookingError
+			require.NoError(t, appErr.Details(&details))
+			require.Equal(t, 1, details.RetryCount)
+		})
+	}
+}
+
+func TestActivities_ScenarioFaults(t *testing.T) {
+	UseFaults(scenario.NewFaults(&scenario.Script{Name: "car-down", Faults: []scenario.Fault{
+		{Provider: types.ComponentCar, Attempts: scenario.Attempts{{From: 1, To: 1}}, Kind: types.ErrNotAvailable},
+	}}))
+	t.Cleanup(func() { UseFaults(nil) })
+
+	a, providers := newTestActivities(t)
+	env := (&testsuite.WorkflowTestSuite{}).NewTestActivityEnvironment()
+	env.RegisterActivity(a)
+
+	_, err := env.ExecuteActivity(a.BookCar, &types.CarBooking{CarType: "SUV", Price: 100})
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, string(types.ErrNotAvailable), appErr.Type())
+
+	inventory, err := providers.Inventory(context.Background(), types.ComponentCar)
+	require.NoError(t, err)
+	require.Empty(t, inventory, "a failed call never reaches the provider")
+
+	_, err = env.ExecuteActivity(a.BookCar, &types.CarBooking{CarType: "SUV", Price: 100})
+	require.NoError(t, err, "only the first attempt fails")
+}
+
+func TestActivities_HoldConfirmAndRelease(t *testing.T) {
+	a, providers := newTestActivities(t)
+	// Each case uses its own provider, as every test environment runs its
+	// activities with the same idempotency key
+	execute := func(activity any, args ...any) (types.BookingConfirmation, error) {
+		env := (&testsuite.WorkflowTestSuite{}).NewTestActivityEnvironment()
+		env.RegisterActivity(a)
+		result, err := env.ExecuteActivity(activity, args...)
+		var confirmation types.BookingConfirmation
+		if err == nil && result.HasValue() {
+			require.NoError(t, result.Get(&confirmation))
+		}
+		return confirmation, err
+	}
+
+	held, err := execute(a.HoldHotel, &types.HotelBooking{HotelID: "hotel-1", Price: 200}, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusHeld, held.Status)
+	require.False(t, held.ExpiresAt.IsZero())
+	confirmation, err := execute(a.ConfirmHotel, held.BookingRef)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusConfirmed, confirmation.Status)
+	require.Equal(t, held.BookingRef, confirmation.BookingRef)
+
+	held, err = execute(a.HoldFlight, &types.FlightBooking{FlightNumber: "FL123", Price: 500}, time.Minute)
+	require.NoError(t, err)
+	_, err = execute(a.ReleaseFlight, held.BookingRef)
+	require.NoError(t, err)
+	_, err = execute(a.ReleaseFlight, held.BookingRef)
+	require.NoError(t, err, "releasing twice is harmless")
+	booking, err := providers.Booking(context.Background(), types.ComponentFlight, held.BookingRef)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusReleased, booking.Status)
+
+	held, err = execute(a.HoldCar, &types.CarBooking{CarType: "SUV", Price: 100}, time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = execute(a.ConfirmCar, held.BookingRef)
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, string(types.ErrHoldExpired), appErr.Type())
+	require.True(t, appErr.NonRetryable())
+}
+
+func TestActivities_CircuitBreaker(t *testing.T) {
+	breakers := breaker.NewSet(breaker.Settings{FailureThreshold: 2, OpenFor: time.Hour})
+	UseBreakers(breakers)
+	t.Cleanup(func() { UseBreakers(nil) })
+
+	a, providers := newTestActivities(t)
+	env := (&testsuite.WorkflowTestSuite{}).NewTestActivityEnvironment()
+	env.RegisterActivity(a)
+	book := func() error {
+		_, err := env.ExecuteActivity(a.BookFlight, &types.FlightBooking{FlightNumber: "FL123", Price: 500})
+		return err
+	}
+
+	// Sold out is an answer, not a failing provider
+	require.NoError(t, providers.SetInventory(context.Background(), types.ComponentFlight, "FL123", 0))
+	for range 3 {
+		require.Error(t, book())
+	}
+	require.Equal(t, breaker.Closed, breakers.State(types.ComponentFlight))
+
+	require.NoError(t, providers.SetInventory(context.Background(), types.ComponentFlight, "FL123", 10))
+	require.NoError(t, providers.SetFault(context.Background(), types.ComponentFlight, simulator.Fault{FailNext: 2}))
+	require.Error(t, book())
+	require.Error(t, book())
+	require.Equal(t, breaker.Open, breakers.State(types.ComponentFlight))
+
+	// The provider has recovered, but the open breaker fails fast without
+	// calling it
+	err := book()
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, string(types.ErrCircuitOpen), appErr.Type())
+	require.False(t, appErr.NonRetryable())
+
+	inventory, err := providers.Inventory(context.Background(), types.ComponentFlight)
+	require.NoError(t, err)
+	require.Equal(t, 10, inventory["FL123"])
+
+	// Other providers have breakers of their own
+	_, err = env.ExecuteActivity(a.BookCar, &types.CarBooking{CarType: "SUV", Price: 100})
+	require.NoError(t, err)
+}
+
+func TestActivities_CircuitBreakerSurvivesPanic(t *testing.T) {
+	breakers := breaker.NewSet(breaker.Settings{FailureThreshold: 1, OpenFor: time.Nanosecond})
+	UseBreakers(breakers)
+	t.Cleanup(func() { UseBreakers(nil) })
+
+	a, _ := newTestActivities(t)
+	ctx := context.Background()
+	require.Error(t, a.call(ctx, types.ComponentFlight, func() error {
+		return types.NewBookingError(types.ComponentFlight, types.ErrProviderDown, "provider down")
+	}))
+	require.Equal(t, breaker.Open, breakers.State(types.ComponentFlight))
+
+	// The half-open trial call panics, which counts as a failure
+	require.Panics(t, func() {
+		a.call(ctx, types.ComponentFlight, func() error { panic("provider client bug") })
+	})
+	require.Equal(t, breaker.Open, breakers.State(types.ComponentFlight))
+
+	// The next trial is still let through
+	require.NoError(t, a.call(ctx, types.ComponentFlight, func() error { return nil }))
+	require.Equal(t, breaker.Closed, breakers.State(types.ComponentFlight))
+}
+
+func TestActivities_SendNotification(t *testing.T) {
+	var subjects []string
+	failures := 1
+	notifier := notification.NewNotifier(slog.New(slog.NewTextHandler(io.Discard, nil)), notification.DefaultSender,
+		notification.NewLog(nil), map[string]notification.Transport{
+			"test": notification.TransportFunc(func(_ context.Context, email notification.Email) error {
+				subjects = append(subjects, email.Subject)
+				if failures > 0 {
+					failures--
+					return errors.New("mail server busy")
+				}
+				return nil
+			}),
+		})
+	UseNotifier(notifier)
+	t.Cleanup(func() { UseNotifier(notification.Default()) })
+
+	a, _ := newTestActivities(t)
+	env := (&testsuite.WorkflowTestSuite{}).NewTestActivityEnvironment()
+	env.RegisterActivity(a)
+	msg := notification.Message{
+		Event:     notification.EventCancelled,
+		BookingID: "TRIP-1",
+		Contact:   types.Contact{Email: "ada@example.com"},
+		Subject:   "Travel Booking Cancelled",
+	}
+
+	_, err := env.ExecuteActivity(a.SendNotification, msg)
+	require.ErrorContains(t, err, "mail server busy")
+	_, err = env.ExecuteActivity(a.SendNotification, msg)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Travel Booking Cancelled", "Travel Booking Cancelled"}, subjects)
+
+	records := notifier.Log().Records()
+	require.Len(t, records, 2)
+	require.Equal(t, notification.StatusFailed, records[0].Status)
+	require.Equal(t, notification.StatusSent, records[1].Status)
+
+	// A message without a template can never be sent
+	msg.Event = "lost"
+	_, err = env.ExecuteActivity(a.SendNotification, msg)
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	require.True(t, appErr.NonRetryable())
+}
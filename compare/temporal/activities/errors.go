@@ -0,0 +1,52 @@
+package activities
+
+import (
+	"context"
+	"errors"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// providerError turns a classified booking error into a Temporal application
+// error whose type is the error kind. Kinds that cannot succeed on retry are
+// marked non-retryable so the activity fails fast. The booking error travels
+// as the error details.
+func providerError(err types.BookingError) error {
+	if err.Kind.Retryable() {
+		return temporal.NewApplicationError(err.Error(), string(err.Kind), err)
+	}
+	return temporal.NewNonRetryableApplicationError(err.Error(), string(err.Kind), nil, err)
+}
+
+// withAttempt records the attempt of the activity running in ctx as the
+// RetryCount of err, an error built by providerError, so the workflow can
+// report how often a call was tried once it gives up. Other errors, and
+// errors outside an activity, are returned as they are.
+func withAttempt(ctx context.Context, err error) error {
+	var appErr *temporal.ApplicationError
+	var failure types.BookingError
+	if !activity.IsActivity(ctx) || !errors.As(err, &appErr) || !appErr.HasDetails() || appErr.Details(&failure) != nil {
+		return err
+	}
+	failure.RetryCount = int(activity.GetInfo(ctx).Attempt)
+	return providerError(failure)
+}
+
+// clientError converts an error from the provider simulator client, which is
+// normally already classified, into a provider error for component
+func clientError(component string, err error) error {
+	return providerError(providerFailure(component, err))
+}
+
+// providerFailure returns the classified booking error in err, taking
+// anything unclassified as the provider being down
+func providerFailure(component string, err error) types.BookingError {
+	var failure types.BookingError
+	if !errors.As(err, &failure) {
+		failure = types.NewBookingError(component, types.ErrProviderDown, "%v", err)
+	}
+	return failure
+}
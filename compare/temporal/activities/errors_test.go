@@ -0,0 +1,39 @@
+package activities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+func TestProviderError(t *testing.T) {
+	tests := []struct {
+		kind         types.ErrorKind
+		nonRetryable bool
+	}{
+		{kind: types.ErrNotAvailable, nonRetryable: true},
+		{kind: types.ErrInvalidRequest, nonRetryable: true},
+		{kind: types.ErrProviderDown},
+		{kind: types.ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			err := providerError(types.NewBookingError(types.ComponentFlight, tt.kind, "flight %s failed", "FL123"))
+
+			var appErr *temporal.ApplicationError
+			require.ErrorAs(t, err, &appErr)
+			require.Equal(t, string(tt.kind), appErr.Type())
+			require.Equal(t, tt.nonRetryable, appErr.NonRetryable())
+
+			var details types.BookingError
+			require.NoError(t, appErr.Details(&details))
+			require.Equal(t, types.ComponentFlight, details.Component)
+			require.Equal(t, tt.kind, details.Kind)
+			require.Equal(t, "flight FL123 failed", details.Message)
+		})
+	}
+}
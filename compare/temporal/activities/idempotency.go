@@ -0,0 +1,68 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.temporal.io/sdk/activity"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// IdempotencyKey identifies the activity running in ctx. Temporal retries an
+// activity under the same workflow ID, run ID and activity ID, so every
+// attempt of one call carries the same key.
+func IdempotencyKey(ctx context.Context) string {
+	info := activity.GetInfo(ctx)
+	return RunKey(info.WorkflowExecution.ID, info.WorkflowExecution.RunID, info.ActivityID)
+}
+
+// RunKey is the idempotency key of the activity activityID in one workflow
+// run, as IdempotencyKey builds it. A workflow that needs the key before the
+// activity runs, to pass it along, builds it here.
+func RunKey(workflowID, runID, activityID string) string {
+	return fmt.Sprintf("%s/%s/%s", workflowID, runID, activityID)
+}
+
+// ledger remembers the result of every call by idempotency key, so a
+// repeated call gets the original result instead of being carried out again
+type ledger struct {
+	component string
+
+	mu      sync.Mutex
+	seq     int
+	results map[string]types.BookingConfirmation
+}
+
+func newLedger(component string) ledger {
+	return ledger{component: component, results: make(map[string]types.BookingConfirmation)}
+}
+
+// once runs call unless key has been seen, in which case the first result
+// is returned. Failed calls are not remembered so they can be retried. call
+// runs with the ledger locked.
+func (l *ledger) once(key string, call func() (types.BookingConfirmation, error)) (types.BookingConfirmation, error) {
+	if key == "" {
+		return types.BookingConfirmation{}, providerError(types.NewBookingError(l.component, types.ErrInvalidRequest,
+			"request rejected: missing idempotency key"))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if result, ok := l.results[key]; ok {
+		return result, nil
+	}
+	result, err := call()
+	if err != nil {
+		return result, err
+	}
+	l.results[key] = result
+	return result, nil
+}
+
+// ref returns a new reference; the ledger must be locked
+func (l *ledger) ref(prefix string) string {
+	l.seq++
+	return fmt.Sprintf("%s-%d", prefix, l.seq)
+}
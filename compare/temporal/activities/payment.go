@@ -0,0 +1,201 @@
+package activities
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// PaymentProvider is a local fake payment gateway. Every call carries an
+// idempotency key; repeating a key returns the original result instead of
+// moving money again, the way a real gateway treats a retried request.
+type PaymentProvider struct {
+	ledger
+	authorizations map[string]*authorization
+}
+
+// authorization tracks the money held and taken against one authorization
+type authorization struct {
+	amount   float64
+	captures int
+	// duplicates counts captures rejected because one already went through
+	duplicates int
+	captured   float64
+	refunded   float64
+	voided     bool
+}
+
+// NewPaymentProvider returns an empty fake gateway
+func NewPaymentProvider() *PaymentProvider {
+	return &PaymentProvider{
+		ledger:         newLedger(types.ComponentPayment),
+		authorizations: make(map[string]*authorization),
+	}
+}
+
+// payments is the gateway shared by every Activities in this process, so
+// retried activities see the results of earlier attempts
+var payments = NewPaymentProvider()
+
+// Authorize holds amount and returns the authorization reference
+func (p *PaymentProvider) Authorize(key string, amount float64) (types.BookingConfirmation, error) {
+	return p.once(key, func() (types.BookingConfirmation, error) {
+		if amount <= 0 {
+			return types.BookingConfirmation{}, paymentError("payment authorization failed: amount must be positive")
+		}
+		ref := p.ref("AUTH")
+		p.authorizations[ref] = &authorization{amount: amount}
+		return p.confirmation(ref, types.StatusAuthorized, amount), nil
+	})
+}
+
+// Capture takes amount, up to the amount held, from an authorization. An
+// authorization can only be captured once.
+func (p *PaymentProvider) Capture(key, authorizationRef string, amount float64) (types.BookingConfirmation, error) {
+	return p.once(key, func() (types.BookingConfirmation, error) {
+		auth, err := p.authorization(authorizationRef)
+		if err != nil {
+			return types.BookingConfirmation{}, err
+		}
+		switch {
+		case auth.voided:
+			return types.BookingConfirmation{}, paymentError("payment capture failed: %s is voided", authorizationRef)
+		case auth.captures > 0:
+			auth.duplicates++
+			return types.BookingConfirmation{}, paymentError("payment capture failed: %s is already captured", authorizationRef)
+		case amount > auth.amount:
+			return types.BookingConfirmation{}, paymentError("payment capture failed: %.2f exceeds the %.2f authorized", amount, auth.amount)
+		}
+		auth.captures++
+		auth.captured = amount
+		return p.confirmation(p.ref("CAP"), types.StatusCaptured, amount), nil
+	})
+}
+
+// Void releases an authorization that has not been captured
+func (p *PaymentProvider) Void(key, authorizationRef string) (types.BookingConfirmation, error) {
+	return p.once(key, func() (types.BookingConfirmation, error) {
+		auth, err := p.authorization(authorizationRef)
+		if err != nil {
+			return types.BookingConfirmation{}, err
+		}
+		if auth.captures > 0 {
+			return types.BookingConfirmation{}, paymentError("payment void failed: %s is captured; refund it instead", authorizationRef)
+		}
+		auth.voided = true
+		return p.confirmation(p.ref("VOID"), types.StatusVoided, auth.amount), nil
+	})
+}
+
+// Refund gives back amount, up to what is left of the capture
+func (p *PaymentProvider) Refund(key, authorizationRef string, amount float64) (types.BookingConfirmation, error) {
+	return p.once(key, func() (types.BookingConfirmation, error) {
+		auth, err := p.authorization(authorizationRef)
+		if err != nil {
+			return types.BookingConfirmation{}, err
+		}
+		if amount > auth.captured-auth.refunded {
+			return types.BookingConfirmation{}, paymentError("payment refund failed: %.2f exceeds the %.2f captured",
+				amount, auth.captured-auth.refunded)
+		}
+		auth.refunded += amount
+		return p.confirmation(p.ref("REF"), types.StatusRefunded, amount), nil
+	})
+}
+
+// Captures returns how many captures of an authorization went through
+func (p *PaymentProvider) Captures(authorizationRef string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if auth, ok := p.authorizations[authorizationRef]; ok {
+		return auth.captures
+	}
+	return 0
+}
+
+// DuplicateCaptures returns how many times an authorization that was already
+// captured was asked to capture again under a different idempotency key
+func (p *PaymentProvider) DuplicateCaptures(authorizationRef string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if auth, ok := p.authorizations[authorizationRef]; ok {
+		return auth.duplicates
+	}
+	return 0
+}
+
+func (p *PaymentProvider) authorization(ref string) (*authorization, error) {
+	auth, ok := p.authorizations[ref]
+	if !ok {
+		return nil, paymentError("unknown authorization %q", ref)
+	}
+	return auth, nil
+}
+
+func (p *PaymentProvider) confirmation(ref string, status types.BookingStatus, amount float64) types.BookingConfirmation {
+	return types.BookingConfirmation{
+		BookingRef:  ref,
+		Status:      status,
+		Price:       amount,
+		ConfirmedAt: time.Now(),
+	}
+}
+
+// paymentError is a request the gateway will never accept, however often it
+// is retried
+func paymentError(format string, args ...any) error {
+	return providerError(types.NewBookingError(types.ComponentPayment, types.ErrInvalidRequest, format, args...))
+}
+
+// Payment Activities
+func (a *Activities) AuthorizePayment(ctx context.Context, idempotencyKey string, amount float64) (types.BookingConfirmation, error) {
+	confirmation, err := a.payments.Authorize(idempotencyKey, amount)
+	if err != nil {
+		return confirmation, withAttempt(ctx, err)
+	}
+
+	a.logger.Info("Payment authorized",
+		slog.String("authorization_ref", confirmation.BookingRef),
+		slog.Float64("amount", amount))
+
+	return confirmation, nil
+}
+
+func (a *Activities) CapturePayment(ctx context.Context, idempotencyKey string, authorizationRef string, amount float64) (types.BookingConfirmation, error) {
+	confirmation, err := a.payments.Capture(idempotencyKey, authorizationRef, amount)
+	if err != nil {
+		return confirmation, withAttempt(ctx, err)
+	}
+
+	a.logger.Info("Payment captured",
+		slog.String("authorization_ref", authorizationRef),
+		slog.String("capture_ref", confirmation.BookingRef),
+		slog.Float64("amount", amount))
+
+	return confirmation, nil
+}
+
+func (a *Activities) VoidPayment(ctx context.Context, idempotencyKey string, authorizationRef string) error {
+	if _, err := a.payments.Void(idempotencyKey, authorizationRef); err != nil {
+		return withAttempt(ctx, err)
+	}
+
+	a.logger.Info("Payment voided",
+		slog.String("authorization_ref", authorizationRef))
+
+	return nil
+}
+
+func (a *Activities) RefundPayment(ctx context.Context, idempotencyKey string, authorizationRef string, amount float64) error {
+	if _, err := a.payments.Refund(idempotencyKey, authorizationRef, amount); err != nil {
+		return withAttempt(ctx, err)
+	}
+
+	a.logger.Info("Payment refunded",
+		slog.String("authorization_ref", authorizationRef),
+		slog.Float64("amount", amount))
+
+	return nil
+}
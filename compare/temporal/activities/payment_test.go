@@ -0,0 +1,120 @@
+package activities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+func TestPaymentProvider_RepeatedKeysReturnOriginalResult(t *testing.T) {
+	p := NewPaymentProvider()
+
+	auth, err := p.Authorize("trip-1/authorize", 800)
+	require.NoError(t, err)
+	again, err := p.Authorize("trip-1/authorize", 800)
+	require.NoError(t, err)
+	require.Equal(t, auth, again)
+
+	capture, err := p.Capture("trip-1/capture", auth.BookingRef, 800)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusCaptured, capture.Status)
+	again, err = p.Capture("trip-1/capture", auth.BookingRef, 800)
+	require.NoError(t, err)
+	require.Equal(t, capture, again)
+
+	require.Equal(t, 1, p.Captures(auth.BookingRef))
+	require.Zero(t, p.DuplicateCaptures(auth.BookingRef))
+}
+
+func TestPaymentProvider_DetectsDuplicateCapture(t *testing.T) {
+	p := NewPaymentProvider()
+	auth, err := p.Authorize("trip-1/authorize", 800)
+	require.NoError(t, err)
+
+	_, err = p.Capture("trip-1/capture", auth.BookingRef, 800)
+	require.NoError(t, err)
+	_, err = p.Capture("trip-1/capture-again", auth.BookingRef, 800)
+	require.ErrorContains(t, err, "already captured")
+
+	require.Equal(t, 1, p.Captures(auth.BookingRef))
+	require.Equal(t, 1, p.DuplicateCaptures(auth.BookingRef))
+}
+
+func TestPaymentProvider_Rejections(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    func(p *PaymentProvider, authRef string) error
+		wantErr string
+	}{
+		{
+			name: "capture more than authorized",
+			call: func(p *PaymentProvider, authRef string) error {
+				_, err := p.Capture("capture", authRef, 900)
+				return err
+			},
+			wantErr: "exceeds the 800.00 authorized",
+		},
+		{
+			name: "capture after void",
+			call: func(p *PaymentProvider, authRef string) error {
+				if _, err := p.Void("void", authRef); err != nil {
+					return err
+				}
+				_, err := p.Capture("capture", authRef, 800)
+				return err
+			},
+			wantErr: "is voided",
+		},
+		{
+			name: "void after capture",
+			call: func(p *PaymentProvider, authRef string) error {
+				if _, err := p.Capture("capture", authRef, 800); err != nil {
+					return err
+				}
+				_, err := p.Void("void", authRef)
+				return err
+			},
+			wantErr: "refund it instead",
+		},
+		{
+			name: "refund more than captured",
+			call: func(p *PaymentProvider, authRef string) error {
+				if _, err := p.Capture("capture", authRef, 600); err != nil {
+					return err
+				}
+				_, err := p.Refund("refund", authRef, 700)
+				return err
+			},
+			wantErr: "exceeds the 600.00 captured",
+		},
+		{
+			name: "unknown authorization",
+			call: func(p *PaymentProvider, authRef string) error {
+				_, err := p.Void("void", "AUTH-404")
+				return err
+			},
+			wantErr: "unknown authorization",
+		},
+		{
+			name: "missing idempotency key",
+			call: func(p *PaymentProvider, authRef string) error {
+				_, err := p.Refund("", authRef, 100)
+				return err
+			},
+			wantErr: "missing idempotency key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPaymentProvider()
+			auth, err := p.Authorize("authorize", 800)
+			require.NoError(t, err)
+
+			err = tt.call(p, auth.BookingRef)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
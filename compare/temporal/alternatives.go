@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// bookChoice books, or holds, the choice of component ranked n: 0 for the
+// first choice, then its alternatives from 1
+type bookChoice func(ctx workflow.Context, n int) (types.BookingConfirmation, error)
+
+// withAlternatives books component with its first choice and, while the
+// provider has none available, with each of its alternatives in rank order.
+// Alternatives priced above the component's MaxPrice are skipped. Any other
+// failure ends the search, as a provider that is down for one choice is down
+// for the rest.
+func withAlternatives(ctx workflow.Context, booking types.TravelBooking, component string, book bookChoice) (types.BookingConfirmation, error) {
+	logger := workflow.GetLogger(ctx)
+
+	alternatives := alternativesOf(booking, component)
+	confirmation, err := book(ctx, 0)
+	for n := 1; err != nil && n <= len(alternatives); n++ {
+		if providerFailure(component, err).Kind != types.ErrNotAvailable {
+			break
+		}
+		alt := alternatives[n-1]
+		if alt.maxPrice > 0 && alt.price > alt.maxPrice {
+			logger.Info("Skipping alternative over budget",
+				slog.String("component", component),
+				slog.String("alternative", alt.id),
+				slog.Float64("price", alt.price),
+				slog.Float64("max_price", alt.maxPrice))
+			continue
+		}
+
+		logger.Warn("No availability; trying alternative",
+			slog.String("component", component),
+			slog.Int("rank", n),
+			slog.String("alternative", alt.id),
+			slog.String("error", err.Error()))
+		confirmation, err = book(ctx, n)
+		confirmation.Alternative = n
+	}
+	if err != nil {
+		return types.BookingConfirmation{}, err
+	}
+	return confirmation, nil
+}
+
+// alternative is one of a component's alternatives as withAlternatives
+// needs it
+type alternative struct {
+	id       string
+	price    float64
+	maxPrice float64
+}
+
+// alternativesOf lists the alternatives of component in rank order
+func alternativesOf(booking types.TravelBooking, component string) []alternative {
+	var alternatives []alternative
+	switch kind, _ := types.ComponentKind(component); kind {
+	case types.ComponentHotel:
+		hotel := booking.Hotel(component)
+		for _, alt := range hotel.Alternatives {
+			alternatives = append(alternatives, alternative{alt.HotelID, alt.Price, hotel.MaxPrice})
+		}
+	case types.ComponentFlight:
+		flight := booking.Flight(component)
+		for _, alt := range flight.Alternatives {
+			alternatives = append(alternatives, alternative{alt.FlightNumber, alt.Price, flight.MaxPrice})
+		}
+	case types.ComponentCar:
+		car := booking.Car(component)
+		for _, alt := range car.Alternatives {
+			alternatives = append(alternatives, alternative{alt.CarType, alt.Price, car.MaxPrice})
+		}
+	}
+	return alternatives
+}
+
+// alternativeBooked describes the alternatives booked for booking, for the
+// user's confirmation; empty if every component is the first choice
+func alternativeBooked(booking types.TravelBooking) string {
+	var detail string
+	for i, b := range booking.HotelLegs() {
+		if b.Alternative > 0 && b.Status == types.StatusConfirmed {
+			detail += fmt.Sprintf(" Your first choice of %s was full, so we booked %s instead.", types.ComponentName(types.ComponentHotel, i), b.HotelID)
+		}
+	}
+	for i, b := range booking.FlightLegs() {
+		if b.Alternative > 0 && b.Status == types.StatusConfirmed {
+			detail += fmt.Sprintf(" Your first choice of %s was full, so we booked %s instead.", types.ComponentName(types.ComponentFlight, i), b.FlightNumber)
+		}
+	}
+	for i, b := range booking.CarLegs() {
+		if b.Alternative > 0 && b.Status == types.StatusConfirmed {
+			detail += fmt.Sprintf(" Your first choice of %s was not available, so we booked a %s instead.", types.ComponentName(types.ComponentCar, i), b.CarType)
+		}
+	}
+	return detail
+}
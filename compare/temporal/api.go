@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+	"github.com/leowmjw/go-durable-x/temporal/web"
+)
+
+// HTTPAddr is where the booking API listens
+const HTTPAddr = "0.0.0.0:8081"
+
+// BookingWorkflowID returns the workflow ID used for a booking, so every call
+// for the same booking reaches the same workflow
+func BookingWorkflowID(bookingID string) string {
+	return "travel-booking-" + bookingID
+}
+
+// bookingClient is the part of client.Client the booking API calls
+type bookingClient interface {
+	ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow any, args ...any) (client.WorkflowRun, error)
+	SignalWorkflow(ctx context.Context, workflowID, runID, signalName string, arg any) error
+	CancelWorkflow(ctx context.Context, workflowID, runID string) error
+	QueryWorkflow(ctx context.Context, workflowID, runID, queryType string, args ...any) (converter.EncodedValue, error)
+	UpdateWorkflow(ctx context.Context, workflowID, runID, updateName string, args ...any) (client.WorkflowUpdateHandle, error)
+}
+
+// BookingAPI serves the HTTP booking API on top of a Temporal client
+type BookingAPI struct {
+	logger *slog.Logger
+	client bookingClient
+
+	// script and providers are set by WithScenario
+	script    *scenario.Script
+	providers *simulator.Client
+}
+
+// NewBookingAPI returns an API backed by c, usually a client.Client
+func NewBookingAPI(logger *slog.Logger, c bookingClient) *BookingAPI {
+	return &BookingAPI{logger: logger, client: c}
+}
+
+// Handler returns the API routes wrapped in request logging
+func (a *BookingAPI) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /bookings", a.createBooking)
+	mux.HandleFunc("GET /bookings/{id}", a.getBooking)
+	mux.HandleFunc("POST /bookings/{id}/approval", a.decidePartialBooking)
+	mux.HandleFunc("POST /bookings/{id}/cancel", a.cancelBooking)
+	mux.HandleFunc("POST /bookings/{id}/cancel-trip", a.cancelTrip)
+	mux.HandleFunc("POST /scenario", a.runScenario)
+	return web.LogRequests(a.logger, "HTTP request", mux)
+}
+
+// ApprovalRequest is the user's answer to a partial booking
+type ApprovalRequest struct {
+	Approved bool `json:"approved"`
+}
+
+// CancelTripRequest is the user's request to cancel a confirmed trip; the
+// body is optional
+type CancelTripRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (a *BookingAPI) createBooking(w http.ResponseWriter, r *http.Request) {
+	var booking types.TravelBooking
+	if err := json.NewDecoder(r.Body).Decode(&booking); err != nil {
+		a.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid booking: %w", err))
+		return
+	}
+	if err := booking.Validate(); err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	workflowID, err := a.startBooking(r.Context(), booking)
+	if err != nil {
+		a.writeTemporalError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusCreated, map[string]string{
+		"booking_id":  booking.BookingID,
+		"workflow_id": workflowID,
+	})
+}
+
+// startBooking starts the workflow for booking and returns its ID
+func (a *BookingAPI) startBooking(ctx context.Context, booking types.TravelBooking) (string, error) {
+	run, err := a.client.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        BookingWorkflowID(booking.BookingID),
+		TaskQueue: TaskQueueName,
+	}, TravelBookingWorkflow, booking)
+	if err != nil {
+		return "", err
+	}
+	return run.GetID(), nil
+}
+
+func (a *BookingAPI) getBooking(w http.ResponseWriter, r *http.Request) {
+	result, err := a.client.QueryWorkflow(r.Context(), BookingWorkflowID(r.PathValue("id")), "", QueryBookingStatus)
+	if err != nil {
+		a.writeTemporalError(w, err)
+		return
+	}
+	var booking types.TravelBooking
+	if err := result.Get(&booking); err != nil {
+		a.writeTemporalError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, booking)
+}
+
+func (a *BookingAPI) decidePartialBooking(w http.ResponseWriter, r *http.Request) {
+	var req ApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid approval: %w", err))
+		return
+	}
+
+	signal := SignalRejectPartialBooking
+	if req.Approved {
+		signal = SignalApprovePartialBooking
+	}
+	if err := a.client.SignalWorkflow(r.Context(), BookingWorkflowID(r.PathValue("id")), "", signal, nil); err != nil {
+		a.writeTemporalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *BookingAPI) cancelBooking(w http.ResponseWriter, r *http.Request) {
+	if err := a.client.CancelWorkflow(r.Context(), BookingWorkflowID(r.PathValue("id")), ""); err != nil {
+		a.writeTemporalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// cancelTrip cancels a confirmed trip and responds with what it cost. A trip
+// that cannot be cancelled, such as one still being booked, is a conflict.
+func (a *BookingAPI) cancelTrip(w http.ResponseWriter, r *http.Request) {
+	var req CancelTripRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		a.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid cancellation: %w", err))
+		return
+	}
+
+	var breakdown types.CancellationBreakdown
+	handle, err := a.client.UpdateWorkflow(r.Context(), BookingWorkflowID(r.PathValue("id")), "", UpdateCancelTrip,
+		types.TripCancellation{Reason: req.Reason})
+	if err == nil {
+		err = handle.Get(r.Context(), &breakdown)
+	}
+	if err != nil {
+		a.writeTemporalError(w, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, breakdown)
+}
+
+// writeTemporalError maps Temporal service errors onto HTTP statuses. Errors
+// raised by the workflow are mapped by their types.ErrorKind; kinds the caller
+// cannot fix are reported as the booking service failing.
+func (a *BookingAPI) writeTemporalError(w http.ResponseWriter, err error) {
+	var notFound *serviceerror.NotFound
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	var appErr *temporal.ApplicationError
+	switch {
+	case errors.As(err, &notFound):
+		a.writeError(w, http.StatusNotFound, errors.New("booking not found"))
+	case errors.As(err, &alreadyStarted):
+		a.writeError(w, http.StatusConflict, errors.New("booking already exists"))
+	case errors.As(err, &appErr) && types.ErrorKind(appErr.Type()) == types.ErrInvalidRequest:
+		a.writeError(w, http.StatusBadRequest, errors.New(appErr.Message()))
+	case errors.As(err, &appErr) && types.ErrorKind(appErr.Type()) == types.ErrStateConflict:
+		a.writeError(w, http.StatusConflict, errors.New(appErr.Message()))
+	default:
+		a.logger.Error("Temporal call failed", slog.String("error", err.Error()))
+		a.writeError(w, http.StatusBadGateway, errors.New("booking service unavailable"))
+	}
+}
+
+func (a *BookingAPI) writeError(w http.ResponseWriter, status int, err error) {
+	a.writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (a *BookingAPI) writeJSON(w http.ResponseWriter, status int, v any) {
+	web.WriteJSON(w, a.logger, status, v)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// fakeClient stands in for the Temporal client. It records every call the
+// API makes and fails them with err when it is set.
+type fakeClient struct {
+	calls   []string
+	booking types.TravelBooking
+	err     error
+}
+
+func (c *fakeClient) ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow any, args ...any) (client.WorkflowRun, error) {
+	booking := args[0].(types.TravelBooking)
+	c.calls = append(c.calls, fmt.Sprintf("start %s %s on %s", options.ID, booking.BookingID, options.TaskQueue))
+	if c.err != nil {
+		return nil, c.err
+	}
+	return fakeRun{id: options.ID}, nil
+}
+
+func (c *fakeClient) SignalWorkflow(ctx context.Context, workflowID, runID, signalName string, arg any) error {
+	c.calls = append(c.calls, "signal "+workflowID+" "+signalName)
+	return c.err
+}
+
+func (c *fakeClient) CancelWorkflow(ctx context.Context, workflowID, runID string) error {
+	c.calls = append(c.calls, "cancel "+workflowID)
+	return c.err
+}
+
+func (c *fakeClient) QueryWorkflow(ctx context.Context, workflowID, runID, queryType string, args ...any) (converter.EncodedValue, error) {
+	c.calls = append(c.calls, "query "+workflowID+" "+queryType)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return fakeValue{c.booking}, nil
+}
+
+func (c *fakeClient) UpdateWorkflow(ctx context.Context, workflowID, runID, updateName string, args ...any) (client.WorkflowUpdateHandle, error) {
+	request := args[0].(types.TripCancellation)
+	c.calls = append(c.calls, "update "+workflowID+" "+updateName+" "+request.Reason)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return fakeUpdate{value: types.CancellationBreakdown{RefundRate: 0.5, Fees: 400, Refund: 400}}, nil
+}
+
+// fakeRun is the workflow run returned by fakeClient.ExecuteWorkflow
+type fakeRun struct {
+	client.WorkflowRun
+	id string
+}
+
+func (r fakeRun) GetID() string { return r.id }
+
+// fakeValue is a query result round-tripped through the default data converter
+type fakeValue struct {
+	value any
+}
+
+func (v fakeValue) HasValue() bool { return v.value != nil }
+
+func (v fakeValue) Get(valuePtr any) error {
+	dc := converter.GetDefaultDataConverter()
+	payload, err := dc.ToPayload(v.value)
+	if err != nil {
+		return err
+	}
+	return dc.FromPayload(payload, valuePtr)
+}
+
+// fakeUpdate is the update handle returned by fakeClient.UpdateWorkflow
+type fakeUpdate struct {
+	client.WorkflowUpdateHandle
+	value any
+}
+
+func (u fakeUpdate) Get(ctx context.Context, valuePtr any) error {
+	return fakeValue{u.value}.Get(valuePtr)
+}
+
+func bookingJSON(t *testing.T, booking types.TravelBooking) string {
+	data, err := json.Marshal(booking)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestBookingAPI(t *testing.T) {
+	invalid := newTestBooking("TEST-200")
+	invalid.EndDate = invalid.StartDate.Add(-time.Hour)
+	noAlternativeID := newTestBooking("TEST-200")
+	noAlternativeID.HotelBooking.Alternatives = []types.HotelAlternative{{RoomType: "deluxe", Price: 220}}
+	itinerary := newTestItinerary("TEST-201", time.Now().Add(14*24*time.Hour))
+	checkInTooEarly := newTestItinerary("TEST-200", time.Now().Add(14*24*time.Hour))
+	checkInTooEarly.Hotels[0].CheckIn = checkInTooEarly.Flights[0].Arrival.Add(-time.Hour)
+	returnTooEarly := newTestItinerary("TEST-200", time.Now().Add(14*24*time.Hour))
+	returnTooEarly.Flights[1].Departure = returnTooEarly.Flights[0].Departure.Add(time.Hour)
+	lateAlternative := newTestItinerary("TEST-200", time.Now().Add(14*24*time.Hour))
+	lateAlternative.Flights[0].Alternatives = []types.FlightAlternative{{
+		FlightNumber: "FL789", SeatClass: "economy", Price: 520,
+		Departure: lateAlternative.Flights[0].Departure.Add(4 * time.Hour), Arrival: lateAlternative.Flights[0].Arrival.Add(4 * time.Hour),
+	}}
+	bothForms := newTestItinerary("TEST-200", time.Now().Add(14*24*time.Hour))
+	bothForms.FlightBooking = bothForms.Flights[0]
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		err        error
+		wantStatus int
+		wantCalls  []string
+		wantBody   string
+	}{
+		{
+			name:       "create booking",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, newTestBooking("TEST-200")),
+			wantStatus: http.StatusCreated,
+			wantCalls:  []string{"start travel-booking-TEST-200 TEST-200 on " + TaskQueueName},
+			wantBody:   `"workflow_id":"travel-booking-TEST-200"`,
+		},
+		{
+			name:       "create booking with bad json",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       `{"BookingID":`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid booking",
+		},
+		{
+			name:       "create booking that fails validation",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, invalid),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "end date must be after start date",
+		},
+		{
+			name:       "create booking with an alternative missing its id",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, noAlternativeID),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "hotel alternative 1 has no id",
+		},
+		{
+			name:       "create itinerary",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, itinerary),
+			wantStatus: http.StatusCreated,
+			wantCalls:  []string{"start travel-booking-TEST-201 TEST-201 on " + TaskQueueName},
+		},
+		{
+			name:       "create itinerary with check-in before the flight lands",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, checkInTooEarly),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "hotel check-in at",
+		},
+		{
+			name:       "create itinerary with return flight before outbound lands",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, returnTooEarly),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "flight-2 departs at",
+		},
+		{
+			name:       "create itinerary with check-in before an alternative flight lands",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, lateAlternative),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "before flight alternative 1 lands at",
+		},
+		{
+			name:       "create itinerary with single and listed flights",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, bothForms),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "not both",
+		},
+		{
+			name:       "create booking twice",
+			method:     http.MethodPost,
+			path:       "/bookings",
+			body:       bookingJSON(t, newTestBooking("TEST-200")),
+			err:        serviceerror.NewWorkflowExecutionAlreadyStarted("started", "", ""),
+			wantStatus: http.StatusConflict,
+			wantCalls:  []string{"start travel-booking-TEST-200 TEST-200 on " + TaskQueueName},
+		},
+		{
+			name:       "get booking",
+			method:     http.MethodGet,
+			path:       "/bookings/TEST-200",
+			wantStatus: http.StatusOK,
+			wantCalls:  []string{"query travel-booking-TEST-200 " + QueryBookingStatus},
+			wantBody:   `"Status":"CONFIRMED"`,
+		},
+		{
+			name:       "get unknown booking",
+			method:     http.MethodGet,
+			path:       "/bookings/TEST-404",
+			err:        serviceerror.NewNotFound("workflow not found"),
+			wantStatus: http.StatusNotFound,
+			wantCalls:  []string{"query travel-booking-TEST-404 " + QueryBookingStatus},
+		},
+		{
+			name:       "approve partial booking",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/approval",
+			body:       `{"approved": true}`,
+			wantStatus: http.StatusAccepted,
+			wantCalls:  []string{"signal travel-booking-TEST-200 " + SignalApprovePartialBooking},
+		},
+		{
+			name:       "reject partial booking",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/approval",
+			body:       `{"approved": false}`,
+			wantStatus: http.StatusAccepted,
+			wantCalls:  []string{"signal travel-booking-TEST-200 " + SignalRejectPartialBooking},
+		},
+		{
+			name:       "approval with bad json",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/approval",
+			body:       `yes`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "cancel booking",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/cancel",
+			wantStatus: http.StatusAccepted,
+			wantCalls:  []string{"cancel travel-booking-TEST-200"},
+		},
+		{
+			name:       "temporal unavailable",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/cancel",
+			err:        errors.New("connection refused"),
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  []string{"cancel travel-booking-TEST-200"},
+			wantBody:   "booking service unavailable",
+		},
+		{
+			name:       "invalid request error",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/approval",
+			body:       `{"approved": true}`,
+			err:        temporal.NewNonRetryableApplicationError("no partial booking to approve", string(types.ErrInvalidRequest), nil),
+			wantStatus: http.StatusBadRequest,
+			wantCalls:  []string{"signal travel-booking-TEST-200 " + SignalApprovePartialBooking},
+			wantBody:   "no partial booking to approve",
+		},
+		{
+			name:       "provider error",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/approval",
+			body:       `{"approved": true}`,
+			err:        temporal.NewApplicationError("provider down", string(types.ErrProviderDown)),
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  []string{"signal travel-booking-TEST-200 " + SignalApprovePartialBooking},
+			wantBody:   "booking service unavailable",
+		},
+		{
+			name:       "cancel trip",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/cancel-trip",
+			body:       `{"reason": "change of plans"}`,
+			wantStatus: http.StatusOK,
+			wantCalls:  []string{"update travel-booking-TEST-200 " + UpdateCancelTrip + " change of plans"},
+			wantBody:   `"Refund":400`,
+		},
+		{
+			name:       "cancel trip without a reason",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/cancel-trip",
+			wantStatus: http.StatusOK,
+			wantCalls:  []string{"update travel-booking-TEST-200 " + UpdateCancelTrip + " "},
+		},
+		{
+			name:       "cancel trip that is not confirmed",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/cancel-trip",
+			err:        temporal.NewNonRetryableApplicationError("only a confirmed trip can be cancelled; booking is PENDING", string(types.ErrStateConflict), nil),
+			wantStatus: http.StatusConflict,
+			wantCalls:  []string{"update travel-booking-TEST-200 " + UpdateCancelTrip + " "},
+			wantBody:   "only a confirmed trip can be cancelled",
+		},
+		{
+			name:       "cancel trip that fails to unwind",
+			method:     http.MethodPost,
+			path:       "/bookings/TEST-200/cancel-trip",
+			err:        temporal.NewApplicationError("hotel provider down", string(types.ErrProviderDown)),
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  []string{"update travel-booking-TEST-200 " + UpdateCancelTrip + " "},
+			wantBody:   "booking service unavailable",
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodDelete,
+			path:       "/bookings/TEST-200",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			booking := newTestBooking("TEST-200")
+			booking.Status = types.StatusConfirmed
+			c := &fakeClient{booking: booking, err: tt.err}
+			api := NewBookingAPI(slog.New(slog.NewTextHandler(&logs, nil)), c)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			api.Handler().ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			require.Equal(t, tt.wantCalls, nilIfEmpty(c.calls))
+			if tt.wantBody != "" {
+				require.Contains(t, rec.Body.String(), tt.wantBody)
+			}
+
+			// One log line per request, with its outcome
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			last := lines[len(lines)-1]
+			require.Contains(t, last, "HTTP request")
+			require.Contains(t, last, "method="+tt.method)
+			require.Contains(t, last, "path="+tt.path)
+			require.Contains(t, last, "status="+strconv.Itoa(tt.wantStatus))
+		})
+	}
+}
+
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/notification"
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// Signals a user sends to accept or decline a trip that is missing a component
+const (
+	SignalApprovePartialBooking = "approve-partial-booking"
+	SignalRejectPartialBooking  = "reject-partial-booking"
+
+	// DefaultApprovalTimeout applies when TravelBooking.ApprovalTimeout is unset
+	DefaultApprovalTimeout = 24 * time.Hour
+)
+
+// approvalTimeout returns the deadline for the user to answer an approval request
+func approvalTimeout(booking types.TravelBooking) time.Duration {
+	if booking.ApprovalTimeout > 0 {
+		return booking.ApprovalTimeout
+	}
+	return DefaultApprovalTimeout
+}
+
+// approvalAbandoned unwinds the trip when awaiting the user's approval failed
+// with cause, which only happens when the workflow is cancelled, so nothing
+// booked is left in place. Runs started before changeTripCancellation return
+// cause as they always did.
+func approvalAbandoned(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga, cause error) error {
+	if !changed(ctx, changeTripCancellation) {
+		return cause
+	}
+	err := compensate(ctx, booking, compensations, cause)
+	setStatus(ctx, booking, types.ComponentBooking, types.StatusFailed, "workflow cancelled while awaiting approval")
+	return err
+}
+
+// awaitPartialApproval asks the user whether to continue the trip without the
+// given component and blocks until they approve, reject or the deadline
+// passes. A reminder is emailed halfway to the deadline. Only an explicit
+// approval returns true; silence is treated as a rejection.
+func awaitPartialApproval(ctx workflow.Context, booking types.TravelBooking, component string) (bool, error) {
+	logger := workflow.GetLogger(ctx)
+	timeout := approvalTimeout(booking)
+
+	replyBy := workflow.Now(ctx).Add(timeout)
+	sendEmail := func(subject string) {
+		msg := newMessage(booking, notification.EventNeedsApproval, subject,
+			fmt.Sprintf("Your travel booking %s could not include a %s. Reply within %s to keep the rest of the trip, otherwise it will be cancelled",
+				booking.BookingID, component, timeout))
+		msg.Component = component
+		msg.ReplyBy = replyBy
+		notifyUser(ctx, msg)
+	}
+	sendEmail("Approval Needed: Travel Booking Without " + component)
+
+	timerCtx, cancelTimers := workflow.WithCancel(ctx)
+	defer cancelTimers()
+
+	var approved, decided, remind bool
+	var err error
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(workflow.GetSignalChannel(ctx, SignalApprovePartialBooking), func(c workflow.ReceiveChannel, _ bool) {
+		c.Receive(ctx, nil)
+		approved, decided = true, true
+	})
+	selector.AddReceive(workflow.GetSignalChannel(ctx, SignalRejectPartialBooking), func(c workflow.ReceiveChannel, _ bool) {
+		c.Receive(ctx, nil)
+		decided = true
+	})
+	selector.AddFuture(workflow.NewTimer(timerCtx, timeout/2), func(workflow.Future) {
+		remind = true
+	})
+	selector.AddFuture(workflow.NewTimer(timerCtx, timeout), func(f workflow.Future) {
+		// Only fails when the workflow itself is cancelled
+		err = f.Get(timerCtx, nil)
+		decided = true
+	})
+
+	for !decided {
+		selector.Select(ctx)
+		if err != nil {
+			return false, err
+		}
+		if remind {
+			remind = false
+			sendEmail("Reminder: Approval Needed: Travel Booking Without " + component)
+		}
+	}
+
+	logger.Info("Partial booking decision",
+		slog.String("component", component),
+		slog.Bool("approved", approved))
+	return approved, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// QueryBookingStatus returns the current types.TravelBooking, including the
+// status of every component and the audit log
+const QueryBookingStatus = "booking-status"
+
+// setStatus moves component of booking to status and records the transition
+// in the audit log. Setting the status it already has is a no-op.
+func setStatus(ctx workflow.Context, booking *types.TravelBooking, component string, to types.BookingStatus, reason string) {
+	status := componentStatus(booking, component)
+	if status == nil || *status == to {
+		return
+	}
+	from := *status
+	*status = to
+	recordTransition(ctx, booking, component, from, to, reason)
+}
+
+// confirm applies a provider confirmation to component of booking and records
+// the resulting status change
+func confirm(ctx workflow.Context, booking *types.TravelBooking, component string, confirmation types.BookingConfirmation) {
+	status := componentStatus(booking, component)
+	if status == nil {
+		return
+	}
+	from := *status
+
+	switch kind, _ := types.ComponentKind(component); kind {
+	case types.ComponentHotel:
+		booking.Hotel(component).Confirm(confirmation)
+	case types.ComponentFlight:
+		booking.Flight(component).Confirm(confirmation)
+	case types.ComponentCar:
+		booking.Car(component).Confirm(confirmation)
+	}
+	reason := "booked as " + confirmation.BookingRef
+	if confirmation.Status == types.StatusHeld {
+		reason = fmt.Sprintf("held as %s until %s", confirmation.BookingRef, confirmation.ExpiresAt.Format(time.RFC3339))
+	}
+	if confirmation.Alternative > 0 {
+		reason += fmt.Sprintf(" (alternative %d)", confirmation.Alternative)
+	}
+	recordTransition(ctx, booking, component, from, confirmation.Status, reason)
+}
+
+// recordTransition appends to the booking's audit log
+func recordTransition(ctx workflow.Context, booking *types.TravelBooking, component string, from, to types.BookingStatus, reason string) {
+	booking.AuditLog = append(booking.AuditLog, types.StateTransition{
+		Component: component,
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		At:        workflow.Now(ctx),
+	})
+
+	workflow.GetLogger(ctx).Info("Booking state transition",
+		slog.String("booking_id", booking.BookingID),
+		slog.String("component", component),
+		slog.String("from", string(from)),
+		slog.String("to", string(to)),
+		slog.String("reason", reason))
+}
+
+// componentStatus returns the status field of the named component, such as
+// "flight-2", or nil if the booking has no such component
+func componentStatus(booking *types.TravelBooking, component string) *types.BookingStatus {
+	hotel, flight, car := booking.Hotel(component), booking.Flight(component), booking.Car(component)
+	switch {
+	case component == types.ComponentBooking:
+		return &booking.Status
+	case hotel != nil:
+		return &hotel.Status
+	case flight != nil:
+		return &flight.Status
+	case car != nil:
+		return &car.Status
+	case component == types.ComponentPayment && booking.Payment != nil:
+		return &booking.Payment.Status
+	}
+	return nil
+}
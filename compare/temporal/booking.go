@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// bookInSequence books every hotel, then every flight, then every car of the
+// itinerary in order, compensating whatever was already booked as soon as a
+// hotel or flight fails. Failed cars can still be accepted by the user once
+// the rest is booked.
+func bookInSequence(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga) error {
+	logger := workflow.GetLogger(ctx)
+
+	var carsFailed []error
+	for _, component := range booking.Components() {
+		confirmation, err := bookComponent(ctx, *booking, component)
+		if err != nil {
+			logger.Error("Failed to book "+component, slog.String("error", err.Error()))
+			recordFailure(ctx, booking, component, err)
+			if kind, _ := types.ComponentKind(component); kind == types.ComponentCar {
+				carsFailed = append(carsFailed, err)
+				continue
+			}
+			err = compensate(ctx, booking, compensations, err)
+			setStatus(ctx, booking, types.ComponentBooking, types.StatusFailed, component+" booking failed")
+			return err
+		}
+		booked(ctx, booking, compensations, component, confirmation)
+	}
+
+	if len(carsFailed) > 0 {
+		return continueWithoutCar(ctx, booking, compensations, carsFailed[0])
+	}
+	return nil
+}
+
+// bookInParallel books every component of the itinerary at the same time.
+// Once all have finished, only the ones that succeeded are compensated if a
+// hotel or flight failed. Failed cars on their own can still be accepted by
+// the user.
+func bookInParallel(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga) error {
+	logger := workflow.GetLogger(ctx)
+
+	components := booking.Components()
+	confirmations := make([]types.BookingConfirmation, len(components))
+	errs := make([]error, len(components))
+
+	wg := workflow.NewWaitGroup(ctx)
+	for i, component := range components {
+		wg.Add(1)
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer wg.Done()
+			confirmations[i], errs[i] = bookComponent(ctx, *booking, component)
+		})
+	}
+	wg.Wait(ctx)
+
+	// Record outcomes in booking order so the audit log reads the same every run
+	var failed []error
+	var essentialFailed bool
+	for i, component := range components {
+		if errs[i] != nil {
+			logger.Error("Failed to book "+component, slog.String("error", errs[i].Error()))
+			recordFailure(ctx, booking, component, errs[i])
+			failed = append(failed, errs[i])
+			kind, _ := types.ComponentKind(component)
+			essentialFailed = essentialFailed || kind != types.ComponentCar
+			continue
+		}
+		booked(ctx, booking, compensations, component, confirmations[i])
+	}
+
+	switch {
+	case len(failed) == 0:
+		return nil
+	case !essentialFailed:
+		return continueWithoutCar(ctx, booking, compensations, failed[0])
+	}
+
+	err := compensate(ctx, booking, compensations, errors.Join(failed...))
+	setStatus(ctx, booking, types.ComponentBooking, types.StatusFailed, "parallel booking failed")
+	return err
+}
+
+// bookComponent books component with the provider, falling back on its
+// alternatives when the first choice is not available
+func bookComponent(ctx workflow.Context, booking types.TravelBooking, component string) (types.BookingConfirmation, error) {
+	return withAlternatives(ctx, booking, component, func(ctx workflow.Context, n int) (types.BookingConfirmation, error) {
+		var confirmation types.BookingConfirmation
+		var err error
+		switch kind, _ := types.ComponentKind(component); kind {
+		case types.ComponentHotel:
+			confirmation, err = bookHotelWithRetries(ctx, booking, component, n)
+		case types.ComponentFlight:
+			err = executeActivity(ctx, BookFlightActivity, booking.Flight(component).Choice(n)).Get(ctx, &confirmation)
+		case types.ComponentCar:
+			err = executeActivity(ctx, BookCarActivity, booking.Car(component).Choice(n)).Get(ctx, &confirmation)
+		}
+		return confirmation, err
+	})
+}
+
+// booked records a confirmed component and how to cancel it
+func booked(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga, component string, confirmation types.BookingConfirmation) {
+	confirm(ctx, booking, component, confirmation)
+	compensations.AddCompensation(component, cancelComponent(booking, component))
+}
+
+// failedCars names the cars of booking that could not be booked
+func failedCars(booking types.TravelBooking) []string {
+	var failed []string
+	for i, car := range booking.CarLegs() {
+		if car.Status == types.StatusFailed {
+			failed = append(failed, types.ComponentName(types.ComponentCar, i))
+		}
+	}
+	return failed
+}
+
+// continueWithoutCar asks the user whether to go ahead without the cars that
+// failed, the first with cause, compensating everything else if they decline
+func continueWithoutCar(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga, cause error) error {
+	approved, err := awaitPartialApproval(ctx, *booking, strings.Join(failedCars(*booking), ", "))
+	if err != nil {
+		return approvalAbandoned(ctx, booking, compensations, err)
+	}
+	if approved {
+		return nil
+	}
+
+	err = compensate(ctx, booking, compensations, cause)
+	setStatus(ctx, booking, types.ComponentBooking, types.StatusFailed, "car booking failed and trip without car not accepted")
+	return err
+}
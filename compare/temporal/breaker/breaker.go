@@ -0,0 +1,226 @@
+// Package breaker keeps a circuit breaker per provider, so that once a
+// provider keeps failing every activity in the worker stops calling it for a
+// while instead of each retrying on its own.
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Environment variables read by FromEnv
+const (
+	// FailureThresholdEnv is how many failures in a row open a breaker
+	FailureThresholdEnv = "BREAKER_FAILURE_THRESHOLD"
+	// OpenForEnv is how long an open breaker rejects calls, as a Go duration
+	OpenForEnv = "BREAKER_OPEN_FOR"
+)
+
+// State is where a breaker is in its cycle. The values are also the breaker
+// state metric.
+type State int
+
+const (
+	// Closed lets every call through and counts failures in a row
+	Closed State = iota
+	// HalfOpen lets one trial call through to see if the provider is back
+	HalfOpen
+	// Open rejects every call until Settings.OpenFor has passed
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// ErrOpen is returned by Allow while a breaker rejects calls
+var ErrOpen = errors.New("circuit breaker open")
+
+// Settings controls when breakers open and close
+type Settings struct {
+	// FailureThreshold is how many failures in a row open a breaker
+	FailureThreshold int
+	// OpenFor is how long an open breaker rejects calls before letting a
+	// trial call through
+	OpenFor time.Duration
+}
+
+// DefaultSettings opens a breaker after five failures in a row for half a
+// minute
+var DefaultSettings = Settings{FailureThreshold: 5, OpenFor: 30 * time.Second}
+
+// FromEnv returns DefaultSettings overridden by FailureThresholdEnv and
+// OpenForEnv
+func FromEnv() (Settings, error) {
+	settings := DefaultSettings
+	if v := os.Getenv(FailureThresholdEnv); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return Settings{}, fmt.Errorf("%s must be a positive number, got %q", FailureThresholdEnv, v)
+		}
+		settings.FailureThreshold = n
+	}
+	if v := os.Getenv(OpenForEnv); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return Settings{}, fmt.Errorf("%s must be a positive duration, got %q", OpenForEnv, v)
+		}
+		settings.OpenFor = d
+	}
+	return settings, nil
+}
+
+// breaker is the state of one provider's breaker
+type breaker struct {
+	state    State
+	failures int
+	openedAt time.Time
+	// probing is set while the trial call of a half-open breaker is out
+	probing bool
+}
+
+// Set holds a breaker per provider, created closed on first use. It is safe
+// for concurrent use. A nil *Set lets every call through.
+type Set struct {
+	settings Settings
+	// OnChange, if set, is called with the set locked whenever a breaker
+	// changes state
+	OnChange func(provider string, from, to State)
+
+	mu       sync.Mutex
+	now      func() time.Time
+	breakers map[string]*breaker
+	rejected map[string]int
+}
+
+// NewSet returns a set of closed breakers
+func NewSet(settings Settings) *Set {
+	return &Set{
+		settings: settings,
+		now:      time.Now,
+		breakers: make(map[string]*breaker),
+		rejected: make(map[string]int),
+	}
+}
+
+// Allow reports whether a call to provider may go ahead. It returns ErrOpen
+// while the breaker is open, or half-open with its trial call already out.
+// Every allowed call must be followed by Done.
+func (s *Set) Allow(provider string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.breaker(provider)
+	if b.state == Open && s.now().Sub(b.openedAt) >= s.settings.OpenFor {
+		s.change(provider, b, HalfOpen)
+	}
+	switch {
+	case b.state == Open, b.state == HalfOpen && b.probing:
+		s.rejected[provider]++
+		return fmt.Errorf("%s: %w", provider, ErrOpen)
+	case b.state == HalfOpen:
+		b.probing = true
+	}
+	return nil
+}
+
+// Done records the outcome of a call Allow let through. Only failures that
+// say the provider is unhealthy should be passed as failed; a provider
+// answering that nothing is available is working fine.
+func (s *Set) Done(provider string, failed bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.breaker(provider)
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		if b.state != Closed {
+			s.change(provider, b, Closed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == HalfOpen || b.state == Closed && b.failures >= s.settings.FailureThreshold {
+		b.openedAt = s.now()
+		s.change(provider, b, Open)
+	}
+}
+
+// State returns the state of provider's breaker
+func (s *Set) State(provider string) State {
+	if s == nil {
+		return Closed
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.breaker(provider).state
+}
+
+func (s *Set) breaker(provider string) *breaker {
+	b, ok := s.breakers[provider]
+	if !ok {
+		b = &breaker{}
+		s.breakers[provider] = b
+	}
+	return b
+}
+
+func (s *Set) change(provider string, b *breaker, to State) {
+	from := b.state
+	b.state = to
+	if to == Closed {
+		b.failures = 0
+	}
+	if s.OnChange != nil {
+		s.OnChange(provider, from, to)
+	}
+}
+
+var (
+	stateDesc = prometheus.NewDesc("provider_circuit_breaker_state",
+		"Circuit breaker state per provider: 0 closed, 1 half-open, 2 open.",
+		[]string{"provider"}, nil)
+	rejectedDesc = prometheus.NewDesc("provider_circuit_breaker_rejected_total",
+		"Calls failed fast by an open circuit breaker.",
+		[]string{"provider"}, nil)
+)
+
+// Describe implements prometheus.Collector
+func (s *Set) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stateDesc
+	ch <- rejectedDesc
+}
+
+// Collect implements prometheus.Collector, reporting every breaker used so
+// far
+func (s *Set) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for provider, b := range s.breakers {
+		ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, float64(b.state), provider)
+		ch <- prometheus.MustNewConstMetric(rejectedDesc, prometheus.CounterValue, float64(s.rejected[provider]), provider)
+	}
+}
@@ -0,0 +1,134 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSet returns a set on a clock the test moves by hand
+func newTestSet(t *testing.T) (*Set, *time.Time, *[]string) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	var changes []string
+	s := NewSet(Settings{FailureThreshold: 3, OpenFor: time.Minute})
+	s.now = func() time.Time { return now }
+	s.OnChange = func(provider string, from, to State) {
+		changes = append(changes, provider+": "+from.String()+" -> "+to.String())
+	}
+	return s, &now, &changes
+}
+
+func TestSet_OpensAfterFailuresInARow(t *testing.T) {
+	s, _, changes := newTestSet(t)
+
+	// A success in between starts the count again
+	for _, failed := range []bool{true, true, false, true, true} {
+		require.NoError(t, s.Allow("hotel"))
+		s.Done("hotel", failed)
+	}
+	require.Equal(t, Closed, s.State("hotel"))
+
+	require.NoError(t, s.Allow("hotel"))
+	s.Done("hotel", true)
+	require.Equal(t, Open, s.State("hotel"))
+	require.ErrorIs(t, s.Allow("hotel"), ErrOpen)
+	require.NoError(t, s.Allow("flight"), "each provider has its own breaker")
+	require.Equal(t, []string{"hotel: closed -> open"}, *changes)
+}
+
+func TestSet_HalfOpenTrialCall(t *testing.T) {
+	tests := []struct {
+		name      string
+		failed    bool
+		wantState State
+	}{
+		{name: "provider back", failed: false, wantState: Closed},
+		{name: "provider still down", failed: true, wantState: Open},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, now, changes := newTestSet(t)
+			for range 3 {
+				require.NoError(t, s.Allow("car"))
+				s.Done("car", true)
+			}
+
+			*now = now.Add(59 * time.Second)
+			require.ErrorIs(t, s.Allow("car"), ErrOpen)
+
+			// Once OpenFor has passed only one trial call goes through
+			*now = now.Add(time.Second)
+			require.NoError(t, s.Allow("car"))
+			require.Equal(t, HalfOpen, s.State("car"))
+			require.ErrorIs(t, s.Allow("car"), ErrOpen)
+
+			s.Done("car", tt.failed)
+			require.Equal(t, tt.wantState, s.State("car"))
+			require.Equal(t, []string{"car: closed -> open", "car: open -> half-open", "car: half-open -> " + tt.wantState.String()}, *changes)
+		})
+	}
+}
+
+func TestSet_Nil(t *testing.T) {
+	var s *Set
+	require.NoError(t, s.Allow("hotel"))
+	s.Done("hotel", true)
+	require.Equal(t, Closed, s.State("hotel"))
+}
+
+func TestSet_Metrics(t *testing.T) {
+	s, _, _ := newTestSet(t)
+	require.NoError(t, s.Allow("flight"))
+	s.Done("flight", false)
+	for range 3 {
+		require.NoError(t, s.Allow("hotel"))
+		s.Done("hotel", true)
+	}
+	require.Error(t, s.Allow("hotel"))
+	require.Error(t, s.Allow("hotel"))
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(s))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	got := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			value := m.GetGauge().GetValue() + m.GetCounter().GetValue()
+			got[family.GetName()+"/"+m.GetLabel()[0].GetValue()] = value
+		}
+	}
+	require.Equal(t, map[string]float64{
+		"provider_circuit_breaker_state/flight":          float64(Closed),
+		"provider_circuit_breaker_state/hotel":           float64(Open),
+		"provider_circuit_breaker_rejected_total/flight": 0,
+		"provider_circuit_breaker_rejected_total/hotel":  2,
+	}, got)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(FailureThresholdEnv, "")
+	t.Setenv(OpenForEnv, "")
+	settings, err := FromEnv()
+	require.NoError(t, err)
+	require.Equal(t, DefaultSettings, settings)
+
+	t.Setenv(FailureThresholdEnv, "10")
+	t.Setenv(OpenForEnv, "2m")
+	settings, err = FromEnv()
+	require.NoError(t, err)
+	require.Equal(t, Settings{FailureThreshold: 10, OpenFor: 2 * time.Minute}, settings)
+
+	t.Setenv(FailureThresholdEnv, "0")
+	_, err = FromEnv()
+	require.ErrorContains(t, err, FailureThresholdEnv)
+
+	t.Setenv(FailureThresholdEnv, "")
+	t.Setenv(OpenForEnv, "soon")
+	_, err = FromEnv()
+	require.ErrorContains(t, err, OpenForEnv)
+}
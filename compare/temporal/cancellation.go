@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/notification"
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// SignalProviderCancellation carries a types.ProviderCancellation from a
+// hotel, flight or car provider that cancelled a confirmed booking
+const SignalProviderCancellation = "provider-cancellation"
+
+// handleProviderCancellation applies a single provider cancellation. Before
+// the trip starts, losing the hotel or flight makes the trip pointless so the
+// rest is cancelled, while losing the car only needs the user to accept
+// travelling without one. Once the trip is under way nothing is unwound; the
+// user is told and the remaining bookings stand.
+func handleProviderCancellation(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga, cancellation types.ProviderCancellation) error {
+	logger := workflow.GetLogger(ctx)
+
+	status := componentStatus(booking, cancellation.Component)
+	if cancellation.Component == types.ComponentBooking || status == nil || *status != types.StatusConfirmed {
+		logger.Warn("Ignoring cancellation for a component that is not confirmed",
+			slog.String("component", cancellation.Component),
+			slog.String("booking_ref", cancellation.BookingRef))
+		return nil
+	}
+	setStatus(ctx, booking, cancellation.Component, types.StatusCancelled, "cancelled by provider: "+cancellation.Reason)
+	compensations.Remove(cancellation.Component)
+
+	logger.Warn("Provider cancelled booking",
+		slog.String("component", cancellation.Component),
+		slog.String("booking_ref", cancellation.BookingRef),
+		slog.String("reason", cancellation.Reason))
+
+	if !workflow.Now(ctx).Before(booking.StartDate) {
+		msg := newMessage(*booking, notification.EventUpdated, "Travel Booking Changed",
+			fmt.Sprintf("The %s for your travel booking %s was cancelled by the provider: %s",
+				cancellation.Component, booking.BookingID, cancellation.Reason))
+		msg.Component = cancellation.Component
+		notifyUser(ctx, msg)
+		return nil
+	}
+
+	if kind, _ := types.ComponentKind(cancellation.Component); kind == types.ComponentCar {
+		approved, err := awaitPartialApproval(ctx, *booking, cancellation.Component)
+		if err != nil {
+			return approvalAbandoned(ctx, booking, compensations, err)
+		}
+		if approved {
+			setStatus(ctx, booking, types.ComponentBooking, types.StatusPartiallyConfirmed, "user accepted trip without car")
+			return nil
+		}
+	}
+
+	err := compensate(ctx, booking, compensations, fmt.Errorf("travel booking %s cancelled: %s booking cancelled by provider",
+		booking.BookingID, cancellation.Component))
+	setStatus(ctx, booking, types.ComponentBooking, types.StatusCancelled, cancellation.Component+" cancelled by provider")
+	msg := newMessage(*booking, notification.EventCancelled, "Travel Booking Cancelled",
+		fmt.Sprintf("Your travel booking %s has been cancelled because the %s was cancelled by the provider: %s",
+			booking.BookingID, cancellation.Component, cancellation.Reason))
+	msg.Component = cancellation.Component
+	notifyUser(ctx, msg)
+
+	return err
+}
+
+// newMessage returns a notification about booking for its contact
+func newMessage(booking types.TravelBooking, event notification.Event, subject, detail string) notification.Message {
+	return notification.Message{
+		Event:     event,
+		BookingID: booking.BookingID,
+		Contact:   booking.Contact,
+		Subject:   subject,
+		Detail:    detail,
+	}
+}
+
+// notifyUser sends msg to the user; failures are logged and otherwise ignored
+func notifyUser(ctx workflow.Context, msg notification.Message) {
+	var err error
+	if changed(ctx, changeNotifications) {
+		err = executeActivity(ctx, SendNotificationActivity, msg).Get(ctx, nil)
+	} else {
+		err = executeActivity(ctx, SendEmailActivity, "user@example.com", msg.Subject, msg.Detail).Get(ctx, nil)
+	}
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to send notification",
+			slog.String("subject", msg.Subject),
+			slog.String("error", err.Error()))
+	}
+}
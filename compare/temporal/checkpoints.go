@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/notification"
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// VerifyBeforeStart is how long before StartDate the bookings are checked
+// with the providers again
+const VerifyBeforeStart = 2 * 24 * time.Hour
+
+// checkpoint is a point in the trip the workflow acts on
+type checkpoint int
+
+const (
+	checkpointNone checkpoint = iota
+	checkpointVerify
+	checkpointReminder
+	checkpointEnd
+)
+
+// travelDay returns the start of the day booking starts on, in the time zone
+// of its StartDate
+func travelDay(booking *types.TravelBooking) time.Time {
+	y, m, d := booking.StartDate.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, booking.StartDate.Location())
+}
+
+// monitorTrip keeps a confirmed booking alive until its EndDate. It
+// re-verifies the bookings VerifyBeforeStart ahead of the trip, reminds the
+// user on the day of travel, reacts to provider cancellations as they arrive
+// and marks the booking completed once the trip is over. Checkpoints already
+// in the past are skipped. The user can cancel the trip through canceller
+// until it starts; cancelling the workflow cancels the trip the same way. It
+// returns an error if a provider cancellation ends up cancelling the whole
+// trip.
+func monitorTrip(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga, canceller *tripCanceller) error {
+	timerCtx, cancelTimers := workflow.WithCancel(ctx)
+	defer cancelTimers()
+
+	var reached checkpoint
+	var err error
+	selector := workflow.NewSelector(ctx)
+	at := func(when time.Time, c checkpoint) {
+		wait := when.Sub(workflow.Now(ctx))
+		if wait <= 0 && c != checkpointEnd {
+			return
+		}
+		selector.AddFuture(workflow.NewTimer(timerCtx, max(wait, 0)), func(f workflow.Future) {
+			// Only fails when the workflow itself is cancelled
+			err = f.Get(timerCtx, nil)
+			reached = c
+		})
+	}
+	// Runs started before the checkpoints only wait for the end of the trip
+	if changed(ctx, changeTripCheckpoints) {
+		at(booking.StartDate.Add(-VerifyBeforeStart), checkpointVerify)
+		at(travelDay(booking), checkpointReminder)
+	}
+	at(booking.EndDate, checkpointEnd)
+
+	var cancellation types.ProviderCancellation
+	selector.AddReceive(workflow.GetSignalChannel(ctx, SignalProviderCancellation), func(c workflow.ReceiveChannel, _ bool) {
+		c.Receive(ctx, &cancellation)
+	})
+	var request types.TripCancellation
+	var cancelRequested bool
+	selector.AddReceive(canceller.requests, func(c workflow.ReceiveChannel, _ bool) {
+		c.Receive(ctx, &request)
+		cancelRequested = true
+	})
+
+	for {
+		reached = checkpointNone
+		selector.Select(ctx)
+		if err != nil {
+			if temporal.IsCanceledError(err) && changed(ctx, changeTripCancellation) &&
+				(booking.Status == types.StatusConfirmed || booking.Status == types.StatusPartiallyConfirmed) {
+				// The trip goes with the workflow, unwound as if the user cancelled it
+				if _, cancelErr := cancelTrip(ctx, booking, compensations, types.TripCancellation{Reason: "booking workflow cancelled"}); cancelErr != nil {
+					workflow.GetLogger(ctx).Error("Trip not fully cancelled", slog.String("error", cancelErr.Error()))
+				}
+			}
+			return err
+		}
+		if cancelRequested {
+			canceller.cancel(ctx, booking, compensations, request)
+			return nil
+		}
+
+		switch reached {
+		case checkpointVerify:
+			if err := verifyBookings(ctx, booking, compensations); err != nil {
+				return err
+			}
+		case checkpointReminder:
+			remindTraveller(ctx, booking)
+		case checkpointEnd:
+			completeTrip(ctx, booking)
+			return nil
+		default:
+			if err := handleProviderCancellation(ctx, booking, compensations, cancellation); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// verifyBookings asks each provider whether its confirmed booking still
+// stands. A booking the provider no longer has confirmed is handled as if the
+// provider had sent a cancellation. A provider that cannot be asked is logged
+// and recorded but leaves the booking as it is.
+func verifyBookings(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga) error {
+	logger := workflow.GetLogger(ctx)
+
+	for _, component := range booking.Components() {
+		status := componentStatus(booking, component)
+		if status == nil || *status != types.StatusConfirmed {
+			continue
+		}
+
+		var verify any
+		_, bookingRef := componentPrice(booking, component)
+		switch kind, _ := types.ComponentKind(component); kind {
+		case types.ComponentHotel:
+			verify = VerifyHotelActivity
+		case types.ComponentFlight:
+			verify = VerifyFlightActivity
+		case types.ComponentCar:
+			verify = VerifyCarActivity
+		}
+
+		var current types.BookingConfirmation
+		if err := executeActivity(ctx, verify, bookingRef).Get(ctx, &current); err != nil {
+			failure := providerFailure(component, err)
+			booking.Errors = append(booking.Errors, failure)
+			logger.Warn("Could not verify booking",
+				slog.String("component", component),
+				slog.String("booking_ref", bookingRef),
+				slog.String("error", err.Error()))
+			continue
+		}
+		if current.Status == types.StatusConfirmed {
+			continue
+		}
+
+		err := handleProviderCancellation(ctx, booking, compensations, types.ProviderCancellation{
+			Component:  component,
+			BookingRef: bookingRef,
+			Reason:     fmt.Sprintf("%s at pre-trip verification", current.Status),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	booking.VerifiedAt = workflow.Now(ctx)
+	logger.Info("Bookings verified before the trip", slog.String("booking_id", booking.BookingID))
+	return nil
+}
+
+// remindTraveller reminds the user on the day of travel of what is booked
+func remindTraveller(ctx workflow.Context, booking *types.TravelBooking) {
+	if booking.Status != types.StatusConfirmed && booking.Status != types.StatusPartiallyConfirmed {
+		return
+	}
+	detail := fmt.Sprintf("Your trip %s starts today, %s.", booking.BookingID, booking.StartDate.Format("Mon 2 Jan 2006 15:04 MST"))
+	for _, component := range booking.Components() {
+		if *componentStatus(booking, component) == types.StatusConfirmed {
+			_, bookingRef := componentPrice(booking, component)
+			detail += fmt.Sprintf(" Your %s booking reference is %s.", component, bookingRef)
+		}
+	}
+	notifyUser(ctx, newMessage(*booking, notification.EventReminder, "Travel Day Reminder", detail))
+}
+
+// completeTrip marks a trip that went ahead as completed
+func completeTrip(ctx workflow.Context, booking *types.TravelBooking) {
+	if booking.Status != types.StatusConfirmed && booking.Status != types.StatusPartiallyConfirmed {
+		return
+	}
+	setStatus(ctx, booking, types.ComponentBooking, types.StatusCompleted, "trip ended")
+}
@@ -0,0 +1,51 @@
+// Command codecserver decrypts travel booking payloads for operators, as the
+// remote codec of `temporal workflow show --codec-endpoint` or of the Web UI.
+// It reads the same keyfile as the worker; see package codec.
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/leowmjw/go-durable-x/temporal/codec"
+)
+
+// Addr is where the codec server listens unless CODEC_SERVER_ADDR says
+// otherwise. It serves decrypted data, so it only listens locally.
+const Addr = "127.0.0.1:8083"
+
+// WebUIOrigin is the Web UI of `temporal server start-dev`, allowed to call
+// the codec server unless CODEC_CORS_ORIGIN says otherwise
+const WebUIOrigin = "http://localhost:8233"
+
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	payloads, err := codec.FromEnv()
+	if err != nil {
+		logger.Error("Invalid keyfile", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if payloads == nil {
+		logger.Error("No keyfile; set " + codec.KeyfileEnv)
+		os.Exit(1)
+	}
+
+	addr := os.Getenv("CODEC_SERVER_ADDR")
+	if addr == "" {
+		addr = Addr
+	}
+	origin, ok := os.LookupEnv("CODEC_CORS_ORIGIN")
+	if !ok {
+		origin = WebUIOrigin
+	}
+
+	logger.Info("Codec server started",
+		slog.String("address", addr),
+		slog.String("active_key", payloads.ActiveKey()))
+	if err := http.ListenAndServe(addr, payloads.Handler(origin)); err != nil {
+		logger.Error("Codec server failed", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,29 @@
+// Command simulator serves the simulated hotel, flight and car providers
+// that the booking activities call; see package simulator for the API.
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
+)
+
+// Addr is where the simulator listens unless SIMULATOR_ADDR says otherwise
+const Addr = "0.0.0.0:8082"
+
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	addr := os.Getenv("SIMULATOR_ADDR")
+	if addr == "" {
+		addr = Addr
+	}
+
+	logger.Info("Provider simulator started", slog.String("address", addr))
+	if err := http.ListenAndServe(addr, simulator.NewServer(logger).Handler()); err != nil {
+		logger.Error("Provider simulator failed", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,197 @@
+// Package codec encrypts workflow payloads with AES-GCM before they reach
+// Temporal, so booking details are never stored in plaintext in history.
+//
+// Keys are read from a JSON keyfile holding every key by ID and the ID of
+// the one to encrypt with:
+//
+//	{"active": "2025-03", "keys": {"2025-01": "<base64>", "2025-03": "<base64>"}}
+//
+// Each key is 16, 24 or 32 random bytes, for example from
+// `openssl rand -base64 32`. To rotate, add a key and make it active; keep
+// the old keys for as long as histories encrypted with them are around.
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// KeyfileEnv names the keyfile; unset leaves payloads unencrypted
+const KeyfileEnv = "PAYLOAD_KEYFILE"
+
+// Metadata of an encrypted payload
+const (
+	// MetadataEncoding is the encoding of every encrypted payload
+	MetadataEncoding = "binary/encrypted"
+	// MetadataKeyID is the metadata field naming the key a payload is
+	// encrypted with
+	MetadataKeyID = "encryption-key-id"
+)
+
+// Codec is a converter.PayloadCodec that encrypts with the active key and
+// decrypts with whichever key a payload names. Payloads that are not
+// encrypted are decoded as they are, so histories from before encryption was
+// turned on still read.
+type Codec struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+var _ converter.PayloadCodec = (*Codec)(nil)
+
+// keyfile is the JSON layout of a keyfile
+type keyfile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// New returns a codec encrypting with keys[active]
+func New(active string, keys map[string][]byte) (*Codec, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyfile", active)
+	}
+	c := &Codec{active: active, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if c.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+	}
+	return c, nil
+}
+
+// Parse returns the codec of a keyfile's contents
+func Parse(data []byte) (*Codec, error) {
+	var f keyfile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse keyfile: %w", err)
+	}
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, encoded := range f.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return New(f.Active, keys)
+}
+
+// Load returns the codec of the keyfile at path
+func Load(path string) (*Codec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keyfile: %w", err)
+	}
+	return Parse(data)
+}
+
+// FromEnv returns the codec of the keyfile named by KeyfileEnv, or nil if
+// it is unset
+func FromEnv() (*Codec, error) {
+	path := os.Getenv(KeyfileEnv)
+	if path == "" {
+		return nil, nil
+	}
+	return Load(path)
+}
+
+// ActiveKey returns the ID of the key new payloads are encrypted with
+func (c *Codec) ActiveKey() string {
+	return c.active
+}
+
+// DataConverter returns the default data converter with payloads encrypted
+// by c, for client.Options.DataConverter
+func (c *Codec) DataConverter() converter.DataConverter {
+	return converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), c)
+}
+
+// Encode encrypts each whole payload, metadata included, with the active key
+func (c *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	aead := c.keys[c.active]
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		plaintext, err := p.Marshal()
+		if err != nil {
+			return payloads, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return payloads, err
+		}
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{
+				converter.MetadataEncoding: []byte(MetadataEncoding),
+				MetadataKeyID:              []byte(c.active),
+			},
+			// The key ID is authenticated with the data, so a payload cannot
+			// be relabelled to another key
+			Data: aead.Seal(nonce, nonce, plaintext, []byte(c.active)),
+		}
+	}
+	return result, nil
+}
+
+// Decode decrypts encrypted payloads and passes others through
+func (c *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.GetMetadata()[converter.MetadataEncoding]) != MetadataEncoding {
+			result[i] = p
+			continue
+		}
+
+		id := string(p.Metadata[MetadataKeyID])
+		aead, ok := c.keys[id]
+		if !ok {
+			return payloads, fmt.Errorf("payload encrypted with unknown key %q", id)
+		}
+		if len(p.Data) < aead.NonceSize() {
+			return payloads, errors.New("encrypted payload too short")
+		}
+		nonce, ciphertext := p.Data[:aead.NonceSize()], p.Data[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+		if err != nil {
+			return payloads, fmt.Errorf("decrypt payload with key %q: %w", id, err)
+		}
+		result[i] = &commonpb.Payload{}
+		if err := result[i].Unmarshal(plaintext); err != nil {
+			return payloads, fmt.Errorf("decrypted payload: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// Handler serves the Temporal remote codec endpoints, POST /encode and
+// POST /decode, so the CLI and the Web UI at allowOrigin can show decrypted
+// payloads. The Web UI sends the namespace in the X-Namespace header.
+func (c *Codec) Handler(allowOrigin string) http.Handler {
+	codec := converter.NewPayloadCodecHTTPHandler(c)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type,X-Namespace")
+			w.Header().Set("Access-Control-Allow-Methods", "POST,OPTIONS")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		codec.ServeHTTP(w, r)
+	})
+}
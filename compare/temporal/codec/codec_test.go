@@ -0,0 +1,212 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, 32)
+	newKey = bytes.Repeat([]byte{2}, 32)
+)
+
+// writeKeyfile writes a keyfile with the given active key and returns its
+// path
+func writeKeyfile(t *testing.T, active string, keys map[string][]byte) string {
+	f := keyfile{Active: active, Keys: map[string]string{}}
+	for id, key := range keys {
+		f.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	}
+	data, err := json.Marshal(f)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func newTestPayload(t *testing.T) *commonpb.Payload {
+	p, err := converter.GetDefaultDataConverter().ToPayload(types.TravelBooking{
+		BookingID: "TRIP-1",
+		UserID:    "ada@example.com",
+		HotelBooking: &types.HotelBooking{
+			HotelID:    "hotel-1",
+			BookingRef: "HTL-SECRET",
+			Price:      200,
+		},
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	c, err := Load(writeKeyfile(t, "2025-03", map[string][]byte{"2025-03": newKey}))
+	require.NoError(t, err)
+	payload := newTestPayload(t)
+
+	encoded, err := c.Encode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	require.Len(t, encoded, 1)
+	require.Equal(t, MetadataEncoding, string(encoded[0].Metadata[converter.MetadataEncoding]))
+	require.Equal(t, "2025-03", string(encoded[0].Metadata[MetadataKeyID]))
+	for _, secret := range []string{"ada@example.com", "HTL-SECRET", "json/plain"} {
+		require.NotContains(t, string(encoded[0].Data), secret)
+	}
+
+	again, err := c.Encode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	require.NotEqual(t, encoded[0].Data, again[0].Data, "every payload gets a fresh nonce")
+
+	decoded, err := c.Decode(encoded)
+	require.NoError(t, err)
+	require.True(t, payload.Equal(decoded[0]))
+
+	var booking types.TravelBooking
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(decoded[0], &booking))
+	require.Equal(t, "HTL-SECRET", booking.HotelBooking.BookingRef)
+}
+
+func TestCodec_KeyRotation(t *testing.T) {
+	before, err := New("2025-01", map[string][]byte{"2025-01": oldKey})
+	require.NoError(t, err)
+	encodedBefore, err := before.Encode([]*commonpb.Payload{newTestPayload(t)})
+	require.NoError(t, err)
+
+	after, err := New("2025-03", map[string][]byte{"2025-01": oldKey, "2025-03": newKey})
+	require.NoError(t, err)
+	encodedAfter, err := after.Encode([]*commonpb.Payload{newTestPayload(t)})
+	require.NoError(t, err)
+	require.Equal(t, "2025-03", string(encodedAfter[0].Metadata[MetadataKeyID]))
+
+	// Payloads from before the rotation, and from before encryption, still
+	// decode
+	plain := newTestPayload(t)
+	decoded, err := after.Decode([]*commonpb.Payload{encodedBefore[0], encodedAfter[0], plain})
+	require.NoError(t, err)
+	for _, p := range decoded {
+		require.True(t, plain.Equal(p))
+	}
+
+	_, err = before.Decode(encodedAfter)
+	require.ErrorContains(t, err, `unknown key "2025-03"`)
+}
+
+func TestCodec_TamperedPayloads(t *testing.T) {
+	c, err := New("2025-03", map[string][]byte{"2025-01": oldKey, "2025-03": newKey})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		tamper  func(p *commonpb.Payload)
+		wantErr string
+	}{
+		{
+			name:    "flipped bit",
+			tamper:  func(p *commonpb.Payload) { p.Data[len(p.Data)-1] ^= 1 },
+			wantErr: "decrypt payload",
+		},
+		{
+			name:    "relabelled key",
+			tamper:  func(p *commonpb.Payload) { p.Metadata[MetadataKeyID] = []byte("2025-01") },
+			wantErr: "decrypt payload",
+		},
+		{
+			name:    "truncated",
+			tamper:  func(p *commonpb.Payload) { p.Data = p.Data[:4] },
+			wantErr: "too short",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := c.Encode([]*commonpb.Payload{newTestPayload(t)})
+			require.NoError(t, err)
+			tt.tamper(encoded[0])
+			_, err = c.Decode(encoded)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyfile string
+		wantErr string
+	}{
+		{name: "not json", keyfile: "keys", wantErr: "parse keyfile"},
+		{name: "missing active key", keyfile: `{"active": "b", "keys": {"a": "` + base64.StdEncoding.EncodeToString(oldKey) + `"}}`, wantErr: `active key "b"`},
+		{name: "not base64", keyfile: `{"active": "a", "keys": {"a": "!!"}}`, wantErr: `key "a"`},
+		{name: "wrong key size", keyfile: `{"active": "a", "keys": {"a": "` + base64.StdEncoding.EncodeToString([]byte("short")) + `"}}`, wantErr: "invalid key size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.keyfile))
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(KeyfileEnv, "")
+	c, err := FromEnv()
+	require.NoError(t, err)
+	require.Nil(t, c)
+
+	t.Setenv(KeyfileEnv, writeKeyfile(t, "2025-03", map[string][]byte{"2025-03": newKey}))
+	c, err = FromEnv()
+	require.NoError(t, err)
+	require.Equal(t, "2025-03", c.ActiveKey())
+
+	t.Setenv(KeyfileEnv, filepath.Join(t.TempDir(), "missing.json"))
+	_, err = FromEnv()
+	require.ErrorContains(t, err, "read keyfile")
+}
+
+func TestHandler(t *testing.T) {
+	c, err := New("2025-03", map[string][]byte{"2025-03": newKey})
+	require.NoError(t, err)
+	server := httptest.NewServer(c.Handler("http://localhost:8233"))
+	t.Cleanup(server.Close)
+
+	// post sends payloads to a codec endpoint as the CLI and Web UI do
+	post := func(path string, payloads []*commonpb.Payload) []*commonpb.Payload {
+		var body bytes.Buffer
+		require.NoError(t, (&jsonpb.Marshaler{}).Marshal(&body, &commonpb.Payloads{Payloads: payloads}))
+		resp, err := server.Client().Post(server.URL+path, "application/json", &body)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "http://localhost:8233", resp.Header.Get("Access-Control-Allow-Origin"))
+		var result commonpb.Payloads
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result.Payloads
+	}
+
+	plain := newTestPayload(t)
+	encoded := post("/encode", []*commonpb.Payload{plain})
+	require.Equal(t, "2025-03", string(encoded[0].Metadata[MetadataKeyID]))
+	decoded := post("/decode", encoded)
+	require.True(t, plain.Equal(decoded[0]))
+
+	req, err := http.NewRequest(http.MethodOptions, server.URL+"/decode", nil)
+	require.NoError(t, err)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "X-Namespace")
+}
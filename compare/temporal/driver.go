@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// WithScenario lets the API drive script, usually the one the worker injects
+// faults from: POST /scenario starts a booking and sends it the script's
+// events, cancelling with providers before telling the workflow
+func (a *BookingAPI) WithScenario(script *scenario.Script, providers *simulator.Client) *BookingAPI {
+	a.script = script
+	a.providers = providers
+	return a
+}
+
+// runScenario starts the booking in the request body and plays the script's
+// events against it in the background. The trip is moved to start StartsIn
+// from now, keeping its length, so every event lands where the script puts
+// it. Events are sent in real time, as a user or provider would send them.
+func (a *BookingAPI) runScenario(w http.ResponseWriter, r *http.Request) {
+	if a.script == nil {
+		a.writeError(w, http.StatusNotFound, fmt.Errorf("no scenario loaded; set %s on the worker", scenario.FileEnv))
+		return
+	}
+	var booking types.TravelBooking
+	if err := json.NewDecoder(r.Body).Decode(&booking); err != nil {
+		a.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid booking: %w", err))
+		return
+	}
+	start := time.Now()
+	length := booking.EndDate.Sub(booking.StartDate)
+	booking.StartDate = start.Add(time.Duration(a.script.StartsIn))
+	booking.EndDate = booking.StartDate.Add(length)
+	if err := booking.Validate(); err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	workflowID, err := a.startBooking(r.Context(), booking)
+	if err != nil {
+		a.writeTemporalError(w, err)
+		return
+	}
+	go a.playEvents(context.WithoutCancel(r.Context()), workflowID, start, a.script.Events)
+	a.writeJSON(w, http.StatusCreated, map[string]string{
+		"booking_id":  booking.BookingID,
+		"workflow_id": workflowID,
+		"scenario":    a.script.Name,
+	})
+}
+
+// playEvents sends each event to the workflow once its After has passed
+// since start. An event that cannot be sent is logged and the rest are
+// still sent, as they would be by their senders.
+func (a *BookingAPI) playEvents(ctx context.Context, workflowID string, start time.Time, events []scenario.Event) {
+	for _, event := range events {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(start.Add(time.Duration(event.After)))):
+		}
+		if err := a.sendEvent(ctx, workflowID, event); err != nil {
+			a.logger.Error("Failed to send scenario event",
+				slog.String("workflow_id", workflowID),
+				slog.String("signal", event.Signal),
+				slog.String("error", err.Error()))
+			continue
+		}
+		a.logger.Info("Sent scenario event",
+			slog.String("workflow_id", workflowID),
+			slog.String("signal", event.Signal))
+	}
+}
+
+// sendEvent signals the workflow with event. A provider cancels on its side
+// before telling the workflow, so the booking it cancels is looked up first.
+func (a *BookingAPI) sendEvent(ctx context.Context, workflowID string, event scenario.Event) error {
+	if event.Signal != SignalProviderCancellation {
+		return a.client.SignalWorkflow(ctx, workflowID, "", event.Signal, nil)
+	}
+
+	result, err := a.client.QueryWorkflow(ctx, workflowID, "", QueryBookingStatus)
+	if err != nil {
+		return err
+	}
+	var booking types.TravelBooking
+	if err := result.Get(&booking); err != nil {
+		return err
+	}
+	ref, err := componentRef(booking, event.Component)
+	if err != nil {
+		return err
+	}
+	// The simulator has one provider per kind; every leg is booked with it
+	provider, _ := types.ComponentKind(event.Component)
+	if err := a.providers.Cancel(ctx, provider, "", ref); err != nil {
+		return err
+	}
+	return a.client.SignalWorkflow(ctx, workflowID, "", SignalProviderCancellation, types.ProviderCancellation{
+		Component:  event.Component,
+		BookingRef: ref,
+		Reason:     event.Reason,
+	})
+}
+
+// componentRef returns the provider's booking reference for component, such
+// as "hotel-2"
+func componentRef(booking types.TravelBooking, component string) (string, error) {
+	kind, _ := types.ComponentKind(component)
+	switch kind {
+	case types.ComponentHotel:
+		if hotel := booking.Hotel(component); hotel != nil {
+			return hotel.BookingRef, nil
+		}
+	case types.ComponentFlight:
+		if flight := booking.Flight(component); flight != nil {
+			return flight.BookingRef, nil
+		}
+	case types.ComponentCar:
+		if car := booking.Car(component); car != nil {
+			return car.BookingRef, nil
+		}
+	}
+	return "", fmt.Errorf("booking %s has no %s", booking.BookingID, component)
+}
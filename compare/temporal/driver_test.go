@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leowmjw/go-durable-x/temporal/duration"
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+func TestBookingAPI_RunScenario(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	body := bookingJSON(t, newTestBooking("TEST-300"))
+
+	t.Run("no scenario loaded", func(t *testing.T) {
+		c := &fakeClient{}
+		api := NewBookingAPI(logger, c)
+
+		rec := httptest.NewRecorder()
+		api.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scenario", strings.NewReader(body)))
+
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		require.Contains(t, rec.Body.String(), scenario.FileEnv)
+		require.Empty(t, c.calls)
+	})
+
+	t.Run("starts the booking", func(t *testing.T) {
+		c := &fakeClient{}
+		script := &scenario.Script{Name: "quiet", StartsIn: duration.Duration(48 * time.Hour)}
+		api := NewBookingAPI(logger, c).WithScenario(script, newTestSimulator(t))
+
+		rec := httptest.NewRecorder()
+		api.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scenario", strings.NewReader(body)))
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		require.Contains(t, rec.Body.String(), `"scenario":"quiet"`)
+		require.Equal(t, []string{"start travel-booking-TEST-300 TEST-300 on " + TaskQueueName}, c.calls)
+	})
+}
+
+func TestBookingAPI_PlayEvents(t *testing.T) {
+	providers := newTestSimulator(t)
+	ctx := context.Background()
+	hotel, err := providers.Book(ctx, types.ComponentHotel, "book-hotel", simulator.BookingRequest{Item: "Test Hotel", Price: 300})
+	require.NoError(t, err)
+
+	booking := newTestBooking("TEST-300")
+	booking.HotelBooking.BookingRef = hotel.Ref
+	c := &fakeClient{booking: booking}
+	api := NewBookingAPI(slog.New(slog.NewTextHandler(io.Discard, nil)), c).WithScenario(&scenario.Script{}, providers)
+
+	api.playEvents(ctx, "travel-booking-TEST-300", time.Now(), []scenario.Event{
+		{Signal: SignalRejectPartialBooking},
+		{Signal: SignalProviderCancellation, Component: types.ComponentHotel, Reason: "overbooked"},
+	})
+
+	require.Equal(t, []string{
+		"signal travel-booking-TEST-300 " + SignalRejectPartialBooking,
+		"query travel-booking-TEST-300 " + QueryBookingStatus,
+		"signal travel-booking-TEST-300 " + SignalProviderCancellation,
+	}, c.calls)
+
+	// The provider cancelled before telling the workflow
+	cancelled, err := providers.Booking(ctx, types.ComponentHotel, hotel.Ref)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusCancelled, cancelled.Status)
+}
+
+func TestBookingAPI_PlayEventsForItinerary(t *testing.T) {
+	providers := newTestSimulator(t)
+	ctx := context.Background()
+	hotel, err := providers.Book(ctx, types.ComponentHotel, "book-hotel-2", simulator.BookingRequest{Item: "hotel-2", Price: 150})
+	require.NoError(t, err)
+
+	booking := newTestItinerary("TEST-301", time.Now().Add(14*24*time.Hour))
+	booking.Hotels[1].BookingRef = hotel.Ref
+	c := &fakeClient{booking: booking}
+	api := NewBookingAPI(slog.New(slog.NewTextHandler(io.Discard, nil)), c).WithScenario(&scenario.Script{}, providers)
+
+	api.playEvents(ctx, "travel-booking-TEST-301", time.Now(), []scenario.Event{
+		{Signal: SignalProviderCancellation, Component: "hotel-2", Reason: "overbooked"},
+		// A leg the itinerary does not have is not sent
+		{Signal: SignalProviderCancellation, Component: "hotel-3", Reason: "overbooked"},
+	})
+
+	require.Equal(t, []string{
+		"query travel-booking-TEST-301 " + QueryBookingStatus,
+		"signal travel-booking-TEST-301 " + SignalProviderCancellation,
+		"query travel-booking-TEST-301 " + QueryBookingStatus,
+	}, c.calls)
+
+	// The second hotel was cancelled with the hotel provider
+	cancelled, err := providers.Booking(ctx, types.ComponentHotel, hotel.Ref)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusCancelled, cancelled.Status)
+}
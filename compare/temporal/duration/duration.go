@@ -0,0 +1,35 @@
+// Package duration holds the Duration used by the JSON and YAML files the
+// worker reads, such as activity policies and scenario scripts.
+package duration
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as a string such as "90s" or "24h"
+type Duration time.Duration
+
+func (d *Duration) set(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %w", err)
+	}
+	return d.set(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.set(value.Value)
+}
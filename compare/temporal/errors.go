@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// providerFailure classifies an activity error for component. Errors raised
+// by the activities carry a types.BookingError; anything else, such as a
+// timeout, is left without a kind and treated as retryable.
+func providerFailure(component string, err error) types.BookingError {
+	failure := types.BookingError{Component: component, Message: err.Error()}
+
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		failure.Kind = types.ErrorKind(appErr.Type())
+		var details types.BookingError
+		if appErr.HasDetails() && appErr.Details(&details) == nil {
+			failure.Message, failure.RetryCount = details.Message, details.RetryCount
+		}
+	}
+	return failure
+}
+
+// recordFailure marks component failed and captures the classified error on
+// the booking
+func recordFailure(ctx workflow.Context, booking *types.TravelBooking, component string, err error) types.BookingError {
+	failure := providerFailure(component, err)
+	booking.Errors = append(booking.Errors, failure)
+
+	reason := failure.Message
+	if failure.Kind != "" {
+		reason = fmt.Sprintf("%s: %s", failure.Kind, failure.Message)
+	}
+	setStatus(ctx, booking, component, types.StatusFailed, reason)
+	return failure
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// Signals an operator sends to a booking parked in StatusNeedsIntervention.
+// SignalResolveCompensation carries a note for the audit log.
+const (
+	SignalRetryCompensation   = "retry-compensation"
+	SignalResolveCompensation = "resolve-compensation"
+)
+
+// compensationRetryPolicy is used for every compensation activity
+var compensationRetryPolicy = temporal.RetryPolicy{
+	InitialInterval:    RetryInitialInterval,
+	BackoffCoefficient: 2.0,
+	MaximumInterval:    CompensationRetryMaxInterval,
+	MaximumAttempts:    CompensationRetryMaxAttempts,
+}
+
+// compensate unwinds every booking recorded in compensations and returns the
+// error that caused it. It runs on a disconnected context, so the bookings
+// are unwound even if the workflow is being cancelled. Compensations that
+// still fail after compensationRetryPolicy are captured as fatal errors on
+// the booking, which is then parked until an operator retries them or marks
+// them resolved. Any that the operator resolved by hand are joined to the
+// returned error.
+func compensate(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga, cause error) error {
+	logger := workflow.GetLogger(ctx)
+	ctx = withOwnRetry(disconnected(ctx), compensationRetryPolicy)
+
+	retryCh := workflow.GetSignalChannel(ctx, SignalRetryCompensation)
+	resolveCh := workflow.GetSignalChannel(ctx, SignalResolveCompensation)
+
+	failures := compensations.Compensate(ctx)
+	for len(failures) > 0 {
+		from := booking.Status
+		for _, failure := range failures {
+			failure.Fatal = true
+			booking.Errors = append(booking.Errors, failure)
+			logger.Error("Compensation needs manual intervention",
+				slog.String("booking_id", booking.BookingID),
+				slog.String("component", failure.Component),
+				slog.String("error", failure.Message))
+		}
+		setStatus(ctx, booking, types.ComponentBooking, types.StatusNeedsIntervention,
+			fmt.Sprintf("%d compensation(s) failed", len(failures)))
+
+		var resolved bool
+		var note string
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(retryCh, func(c workflow.ReceiveChannel, _ bool) {
+			c.Receive(ctx, nil)
+		})
+		selector.AddReceive(resolveCh, func(c workflow.ReceiveChannel, _ bool) {
+			c.Receive(ctx, &note)
+			resolved = true
+		})
+		selector.Select(ctx)
+
+		if resolved {
+			compensations.Clear()
+			setStatus(ctx, booking, types.ComponentBooking, from, "resolved by operator: "+note)
+			return errors.Join(append([]error{cause}, asErrors(failures)...)...)
+		}
+
+		setStatus(ctx, booking, types.ComponentBooking, from, "compensation retried by operator")
+		failures = compensations.Compensate(ctx)
+	}
+
+	return cause
+}
+
+// asErrors converts booking errors for use with errors.Join
+func asErrors(failures []types.BookingError) []error {
+	errs := make([]error, 0, len(failures))
+	for _, failure := range failures {
+		errs = append(errs, failure)
+	}
+	return errs
+}
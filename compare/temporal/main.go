@@ -2,17 +2,30 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 
+	"github.com/leowmjw/go-durable-x/telemetry"
 	"github.com/leowmjw/go-durable-x/temporal/activities"
+	"github.com/leowmjw/go-durable-x/temporal/breaker"
+	"github.com/leowmjw/go-durable-x/temporal/codec"
+	"github.com/leowmjw/go-durable-x/temporal/notification"
+	"github.com/leowmjw/go-durable-x/temporal/policy"
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
 	"github.com/leowmjw/go-durable-x/temporal/types"
 )
 
@@ -22,10 +35,40 @@ const (
 	RetryMaxAttempts     = 3
 	RetryInitialInterval = time.Second
 	RetryMaxInterval     = time.Hour * 24
+
+	// Compensations retry harder than bookings before needing an operator
+	CompensationRetryMaxAttempts = 10
+	CompensationRetryMaxInterval = time.Hour
+
+	// Hotel bookings follow a progressive schedule instead of the global
+	// retry policy: a few retries spread over the first day, then one a day.
+	HotelFirstDayRetries = 2
+	HotelDailyRetryDays  = 7
+
+	// HotelBookingActivityID is the activity ID of every hotel booking
+	// attempt; attempts at its nth alternative add "-n"
+	HotelBookingActivityID = "book-hotel"
+
+	// DefaultHoldTimeout is how long providers hold each component of a
+	// ModeTCC booking unless the booking says otherwise
+	DefaultHoldTimeout = 15 * time.Minute
 )
 
+// HotelRetryDelays returns the durable delays to wait after each failed hotel
+// booking attempt before trying again. Once exhausted the booking fails.
+func HotelRetryDelays() []time.Duration {
+	delays := make([]time.Duration, 0, HotelFirstDayRetries+HotelDailyRetryDays)
+	for range HotelFirstDayRetries {
+		delays = append(delays, 24*time.Hour/(HotelFirstDayRetries+1))
+	}
+	for range HotelDailyRetryDays {
+		delays = append(delays, 24*time.Hour)
+	}
+	return delays
+}
+
 // Activity functions
-func BookHotelActivity(ctx context.Context, booking *types.HotelBooking) error {
+func BookHotelActivity(ctx context.Context, booking *types.HotelBooking) (types.BookingConfirmation, error) {
 	activities := activities.NewActivities(slog.Default())
 	return activities.BookHotel(ctx, booking)
 }
@@ -35,7 +78,7 @@ func CancelHotelActivity(ctx context.Context, bookingRef string) error {
 	return activities.CancelHotel(ctx, bookingRef)
 }
 
-func BookFlightActivity(ctx context.Context, booking *types.FlightBooking) error {
+func BookFlightActivity(ctx context.Context, booking *types.FlightBooking) (types.BookingConfirmation, error) {
 	activities := activities.NewActivities(slog.Default())
 	return activities.BookFlight(ctx, booking)
 }
@@ -45,7 +88,7 @@ func CancelFlightActivity(ctx context.Context, bookingRef string) error {
 	return activities.CancelFlight(ctx, bookingRef)
 }
 
-func BookCarActivity(ctx context.Context, booking *types.CarBooking) error {
+func BookCarActivity(ctx context.Context, booking *types.CarBooking) (types.BookingConfirmation, error) {
 	activities := activities.NewActivities(slog.Default())
 	return activities.BookCar(ctx, booking)
 }
@@ -55,36 +98,154 @@ func CancelCarActivity(ctx context.Context, bookingRef string) error {
 	return activities.CancelCar(ctx, bookingRef)
 }
 
+func HoldHotelActivity(ctx context.Context, booking *types.HotelBooking, holdFor time.Duration) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.HoldHotel(ctx, booking, holdFor)
+}
+
+func ConfirmHotelActivity(ctx context.Context, holdRef string) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.ConfirmHotel(ctx, holdRef)
+}
+
+func ReleaseHotelActivity(ctx context.Context, holdRef string) error {
+	activities := activities.NewActivities(slog.Default())
+	return activities.ReleaseHotel(ctx, holdRef)
+}
+
+func HoldFlightActivity(ctx context.Context, booking *types.FlightBooking, holdFor time.Duration) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.HoldFlight(ctx, booking, holdFor)
+}
+
+func ConfirmFlightActivity(ctx context.Context, holdRef string) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.ConfirmFlight(ctx, holdRef)
+}
+
+func ReleaseFlightActivity(ctx context.Context, holdRef string) error {
+	activities := activities.NewActivities(slog.Default())
+	return activities.ReleaseFlight(ctx, holdRef)
+}
+
+func HoldCarActivity(ctx context.Context, booking *types.CarBooking, holdFor time.Duration) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.HoldCar(ctx, booking, holdFor)
+}
+
+func ConfirmCarActivity(ctx context.Context, holdRef string) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.ConfirmCar(ctx, holdRef)
+}
+
+func ReleaseCarActivity(ctx context.Context, holdRef string) error {
+	activities := activities.NewActivities(slog.Default())
+	return activities.ReleaseCar(ctx, holdRef)
+}
+
+func AuthorizePaymentActivity(ctx context.Context, idempotencyKey string, amount float64) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.AuthorizePayment(ctx, idempotencyKey, amount)
+}
+
+func CapturePaymentActivity(ctx context.Context, idempotencyKey string, authorizationRef string, amount float64) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.CapturePayment(ctx, idempotencyKey, authorizationRef, amount)
+}
+
+func VoidPaymentActivity(ctx context.Context, idempotencyKey string, authorizationRef string) error {
+	activities := activities.NewActivities(slog.Default())
+	return activities.VoidPayment(ctx, idempotencyKey, authorizationRef)
+}
+
+func RefundPaymentActivity(ctx context.Context, idempotencyKey string, authorizationRef string, amount float64) error {
+	activities := activities.NewActivities(slog.Default())
+	return activities.RefundPayment(ctx, idempotencyKey, authorizationRef, amount)
+}
+
+func VerifyHotelActivity(ctx context.Context, bookingRef string) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.VerifyHotel(ctx, bookingRef)
+}
+
+func VerifyFlightActivity(ctx context.Context, bookingRef string) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.VerifyFlight(ctx, bookingRef)
+}
+
+func VerifyCarActivity(ctx context.Context, bookingRef string) (types.BookingConfirmation, error) {
+	activities := activities.NewActivities(slog.Default())
+	return activities.VerifyCar(ctx, bookingRef)
+}
+
+func SendNotificationActivity(ctx context.Context, msg notification.Message) error {
+	activities := activities.NewActivities(slog.Default())
+	return activities.SendNotification(ctx, msg)
+}
+
+// SendEmailActivity is how runs started before changeNotifications tell the
+// user; it stays registered until they have all finished
 func SendEmailActivity(ctx context.Context, to string, subject string, body string) error {
 	activities := activities.NewActivities(slog.Default())
-	return activities.SendEmail(ctx, to, subject, body)
+	return activities.SendNotification(ctx, notification.Message{
+		Event:     notification.EventUpdated,
+		BookingID: strings.TrimPrefix(activity.GetInfo(ctx).WorkflowExecution.ID, "travel-booking-"),
+		Contact:   types.Contact{Email: to},
+		Subject:   subject,
+		Detail:    body,
+	})
 }
 
 // Activities interfaces for better testability
 type (
 	HotelBookingActivities interface {
-		BookHotel(ctx context.Context, booking *types.HotelBooking) error
+		BookHotel(ctx context.Context, booking *types.HotelBooking) (types.BookingConfirmation, error)
 		CancelHotel(ctx context.Context, bookingRef string) error
+		VerifyHotel(ctx context.Context, bookingRef string) (types.BookingConfirmation, error)
+		HoldHotel(ctx context.Context, booking *types.HotelBooking, holdFor time.Duration) (types.BookingConfirmation, error)
+		ConfirmHotel(ctx context.Context, holdRef string) (types.BookingConfirmation, error)
+		ReleaseHotel(ctx context.Context, holdRef string) error
 	}
 
 	FlightBookingActivities interface {
-		BookFlight(ctx context.Context, booking *types.FlightBooking) error
+		BookFlight(ctx context.Context, booking *types.FlightBooking) (types.BookingConfirmation, error)
 		CancelFlight(ctx context.Context, bookingRef string) error
+		VerifyFlight(ctx context.Context, bookingRef string) (types.BookingConfirmation, error)
+		HoldFlight(ctx context.Context, booking *types.FlightBooking, holdFor time.Duration) (types.BookingConfirmation, error)
+		ConfirmFlight(ctx context.Context, holdRef string) (types.BookingConfirmation, error)
+		ReleaseFlight(ctx context.Context, holdRef string) error
 	}
 
 	CarBookingActivities interface {
-		BookCar(ctx context.Context, booking *types.CarBooking) error
+		BookCar(ctx context.Context, booking *types.CarBooking) (types.BookingConfirmation, error)
 		CancelCar(ctx context.Context, bookingRef string) error
+		VerifyCar(ctx context.Context, bookingRef string) (types.BookingConfirmation, error)
+		HoldCar(ctx context.Context, booking *types.CarBooking, holdFor time.Duration) (types.BookingConfirmation, error)
+		ConfirmCar(ctx context.Context, holdRef string) (types.BookingConfirmation, error)
+		ReleaseCar(ctx context.Context, holdRef string) error
+	}
+
+	PaymentActivities interface {
+		AuthorizePayment(ctx context.Context, idempotencyKey string, amount float64) (types.BookingConfirmation, error)
+		CapturePayment(ctx context.Context, idempotencyKey string, authorizationRef string, amount float64) (types.BookingConfirmation, error)
+		VoidPayment(ctx context.Context, idempotencyKey string, authorizationRef string) error
+		RefundPayment(ctx context.Context, idempotencyKey string, authorizationRef string, amount float64) error
 	}
 
 	NotificationActivities interface {
-		SendEmail(ctx context.Context, to string, subject string, body string) error
+		SendNotification(ctx context.Context, msg notification.Message) error
 	}
 )
 
 // TravelBookingWorkflow orchestrates the entire booking process
 func TravelBookingWorkflow(ctx workflow.Context, booking types.TravelBooking) error {
-	logger := workflow.GetLogger(ctx)
+	// The API validates bookings, but not every run is started through it;
+	// runs started before this check took their booking as sent
+	if changed(ctx, changeBookingValidation) {
+		if err := booking.Validate(); err != nil {
+			return temporal.NewNonRetryableApplicationError(fmt.Sprintf("invalid booking: %v", err), string(types.ErrInvalidRequest), err)
+		}
+	}
 
 	// Setup retry policy for activities
 	retryPolicy := &temporal.RetryPolicy{
@@ -98,81 +259,283 @@ func TravelBookingWorkflow(ctx workflow.Context, booking types.TravelBooking) er
 		StartToCloseTimeout: time.Minute * 5,
 		RetryPolicy:         retryPolicy,
 	}
-	ctx = workflow.WithActivityOptions(ctx, activityOpts)
+	ctx = workflow.WithActivityOptions(ctx, activityPolicies.ApplyDefault(activityOpts))
 
-	// Step 1: Book Hotel
-	var hotelBookingErr error
-	err := workflow.ExecuteActivity(ctx, BookHotelActivity, booking.HotelBooking).Get(ctx, &hotelBookingErr)
+	err := workflow.SetQueryHandler(ctx, QueryBookingStatus, func() (types.TravelBooking, error) {
+		return booking, nil
+	})
 	if err != nil {
-		logger.Error("Failed to book hotel", slog.String("error", err.Error()))
 		return err
 	}
-
-	// Step 2: Book Flight
-	var flightBookingErr error
-	err = workflow.ExecuteActivity(ctx, BookFlightActivity, booking.FlightBooking).Get(ctx, &flightBookingErr)
+	canceller, err := setCancelTripHandler(ctx, &booking)
 	if err != nil {
-		// Compensate: Cancel Hotel
-		_ = workflow.ExecuteActivity(ctx, CancelHotelActivity, booking.HotelBooking.BookingRef).Get(ctx, nil)
-		logger.Error("Failed to book flight", slog.String("error", err.Error()))
 		return err
 	}
+	// However the workflow ends, a cancel-trip update still waiting is answered
+	defer canceller.close(ctx)
+	// Runs started before payments were taken are not charged
+	if booking.TotalAmount > 0 && changed(ctx, changePayment) {
+		booking.Payment = &types.Payment{Amount: booking.TotalAmount}
+	}
+	for _, component := range append([]string{types.ComponentBooking, types.ComponentPayment}, booking.Components()...) {
+		setStatus(ctx, &booking, component, types.StatusPending, "booking started")
+	}
 
-	// Step 3: Book Car
-	var carBookingErr error
-	err = workflow.ExecuteActivity(ctx, BookCarActivity, booking.CarBooking).Get(ctx, &carBookingErr)
+	// Compensations are recorded as each booking succeeds; bookings made in
+	// parallel are also unwound in parallel
+	compensations := saga.New(saga.Options{Parallel: booking.Mode == types.ModeParallel || booking.Mode == types.ModeTCC})
+
+	// Hold the money before booking anything, so a declined card costs nothing
+	if err := authorizePayment(ctx, &booking, compensations); err != nil {
+		return err
+	}
+
+	switch booking.Mode {
+	case types.ModeParallel:
+		err = bookInParallel(ctx, &booking, compensations)
+	case types.ModeTCC:
+		err = bookWithHolds(ctx, &booking, compensations)
+	default:
+		err = bookInSequence(ctx, &booking, compensations)
+	}
 	if err != nil {
-		// Compensate: Cancel Flight and Hotel
-		_ = workflow.ExecuteActivity(ctx, CancelFlightActivity, booking.FlightBooking.BookingRef).Get(ctx, nil)
-		_ = workflow.ExecuteActivity(ctx, CancelHotelActivity, booking.HotelBooking.BookingRef).Get(ctx, nil)
-		logger.Error("Failed to book car", slog.String("error", err.Error()))
 		return err
 	}
 
-	// All bookings successful
-	booking.Status = types.StatusConfirmed
+	// Take the money only now the trip is settled
+	if err := capturePayment(ctx, &booking, compensations); err != nil {
+		return err
+	}
 
-	// Send confirmation email
-	err = workflow.ExecuteActivity(ctx, SendEmailActivity,
-		"user@example.com",
-		"Travel Booking Confirmed",
-		fmt.Sprintf("Your travel booking %s has been confirmed", booking.BookingID)).Get(ctx, nil)
-	if err != nil {
-		logger.Error("Failed to send confirmation email", slog.String("error", err.Error()))
-		// Non-critical error, don't fail the workflow
+	// All required bookings successful
+	if cars := failedCars(booking); len(cars) > 0 {
+		setStatus(ctx, &booking, types.ComponentBooking, types.StatusPartiallyConfirmed, "user accepted trip without car")
+		msg := newMessage(booking, notification.EventPartial, "Travel Booking Confirmed Without Car",
+			fmt.Sprintf("Your travel booking %s has been confirmed without a car", booking.BookingID))
+		msg.Component = strings.Join(cars, ", ")
+		notifyUser(ctx, msg)
+	} else {
+		setStatus(ctx, &booking, types.ComponentBooking, types.StatusConfirmed, "all components booked")
+		notifyUser(ctx, newMessage(booking, notification.EventConfirmed, "Travel Booking Confirmed",
+			fmt.Sprintf("Your travel booking %s has been confirmed", booking.BookingID)+alternativeBooked(booking)))
+	}
+
+	// Stay alive for the trip to verify it, remind the user and handle
+	// provider cancellations
+	return monitorTrip(ctx, &booking, compensations, canceller)
+}
+
+// cancelComponent returns the compensation that cancels the named component
+// of booking. The booking reference is read when the compensation runs.
+func cancelComponent(booking *types.TravelBooking, component string) saga.Compensation {
+	return func(ctx workflow.Context) error {
+		var cancel any
+		var bookingRef string
+		switch kind, _ := types.ComponentKind(component); kind {
+		case types.ComponentHotel:
+			cancel, bookingRef = CancelHotelActivity, booking.Hotel(component).BookingRef
+		case types.ComponentFlight:
+			cancel, bookingRef = CancelFlightActivity, booking.Flight(component).BookingRef
+		case types.ComponentCar:
+			cancel, bookingRef = CancelCarActivity, booking.Car(component).BookingRef
+		default:
+			return fmt.Errorf("unknown component %q", component)
+		}
+
+		if err := executeActivity(ctx, cancel, bookingRef).Get(ctx, nil); err != nil {
+			return err
+		}
+		setStatus(ctx, booking, component, types.StatusCancelled, "compensated")
+		return nil
 	}
+}
+
+// bookHotelWithRetries books the ranked choice for the hotel named component
+// following HotelRetryDelays, sleeping on durable timers between attempts. Errors that
+// cannot succeed on retry, such as no availability, fail straight away. If
+// the booking only succeeds after a retry the user is emailed so they know
+// the rest of the trip is being booked.
+func bookHotelWithRetries(ctx workflow.Context, booking types.TravelBooking, component string, choice int) (types.BookingConfirmation, error) {
+	logger := workflow.GetLogger(ctx)
 
-	return nil
+	// Each attempt runs once; the schedule below is the retry policy. All
+	// attempts at a hotel share one activity ID, and so one idempotency key,
+	// so an attempt that timed out after the provider booked is not booked
+	// twice. Each later hotel of the itinerary, and each alternative, gets
+	// its own.
+	activityID := HotelBookingActivityID
+	_, leg := types.ComponentKind(component)
+	if leg > 0 {
+		activityID = fmt.Sprintf("%s-leg-%d", HotelBookingActivityID, leg+1)
+	}
+	opts := workflow.GetActivityOptions(ctx)
+	if choice > 0 {
+		opts.ActivityID = fmt.Sprintf("%s-%d", activityID, choice)
+	} else if leg > 0 || changed(ctx, changeHotelActivityID) {
+		opts.ActivityID = activityID
+	}
+	attemptCtx := withOwnRetry(workflow.WithActivityOptions(ctx, opts), temporal.RetryPolicy{MaximumAttempts: 1})
+
+	delays := HotelRetryDelays()
+	for attempt := 0; ; attempt++ {
+		var confirmation types.BookingConfirmation
+		err := executeActivity(attemptCtx, BookHotelActivity, booking.Hotel(component).Choice(choice)).Get(ctx, &confirmation)
+		if err == nil {
+			if attempt > 0 {
+				notifyUser(ctx, newMessage(booking, notification.EventUpdated, "Hotel Booking Succeeded",
+					fmt.Sprintf("Your %s for travel booking %s is booked after %d attempts; continuing with the rest of your trip",
+						component, booking.BookingID, attempt+1)))
+			}
+			return confirmation, nil
+		}
+		if attempt >= len(delays) {
+			return types.BookingConfirmation{}, err
+		}
+		// No point waiting a day to ask again for a room that does not exist
+		if failure := providerFailure(component, err); !failure.Kind.Retryable() {
+			logger.Error("Hotel booking failed permanently", slog.String("kind", string(failure.Kind)))
+			return types.BookingConfirmation{}, err
+		}
+
+		logger.Warn("Hotel booking attempt failed; retrying later",
+			slog.Int("attempt", attempt+1),
+			slog.Duration("delay", delays[attempt]),
+			slog.String("error", err.Error()))
+		if err := workflow.Sleep(ctx, delays[attempt]); err != nil {
+			return types.BookingConfirmation{}, err
+		}
+	}
 }
 
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if err := run(logger); err != nil {
+		logger.Error("Worker failed", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+// run sets up the worker and its booking API from the environment and runs
+// them until interrupted. It returns rather than exiting, so telemetry is
+// flushed and the client closed however the worker stops.
+func run(logger *slog.Logger) error {
+	// Load per-activity policies; a bad file stops the worker from starting
+	if path := os.Getenv(ActivityPolicyFileEnv); path != "" {
+		policies, err := policy.Load(path, activityNames()...)
+		if err != nil {
+			return fmt.Errorf("invalid activity policy file: %w", err)
+		}
+		activityPolicies = policies
+		logger.Info("Loaded activity policies", slog.String("path", path))
+	}
+
+	// Send notifications through the transports configured in the environment
+	notifier, err := notification.FromEnv(logger)
+	if err != nil {
+		return fmt.Errorf("invalid notification settings: %w", err)
+	}
+	activities.UseNotifier(notifier)
+
+	// Inject the faults of a scenario script or seed into provider calls
+	faults, err := scenario.FromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid fault scenario: %w", err)
+	}
+	if faults != nil {
+		activities.UseFaults(faults)
+		logger.Info("Injecting scenario faults",
+			slog.String("scenario", faults.Script().Name),
+			slog.Uint64("seed", faults.Script().Seed))
+	}
+
+	// Record metrics and traces of every workflow, activity and client call
+	tel, err := telemetry.FromEnv("travel-booking")
+	if err != nil {
+		return fmt.Errorf("invalid telemetry settings: %w", err)
+	}
+	defer tel.Shutdown(context.Background())
+
+	// Stop calling a failing provider from every booking at once
+	breakerSettings, err := breaker.FromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid circuit breaker settings: %w", err)
+	}
+	breakers := breaker.NewSet(breakerSettings)
+	breakers.OnChange = func(provider string, from, to breaker.State) {
+		logger.Warn("Provider circuit breaker changed state",
+			slog.String("provider", provider),
+			slog.String("from", from.String()),
+			slog.String("to", to.String()))
+	}
+	if err := tel.Register(breakers); err != nil {
+		return fmt.Errorf("register circuit breaker metrics: %w", err)
+	}
+	activities.UseBreakers(breakers)
+
+	// Encrypt payloads before they reach Temporal; the worker shares the
+	// client's data converter
+	clientOptions := client.Options{
+		Interceptors: []interceptor.ClientInterceptor{tel.ClientInterceptor()},
+	}
+	payloads, err := codec.FromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid payload keyfile: %w", err)
+	}
+	if payloads != nil {
+		clientOptions.DataConverter = payloads.DataConverter()
+		logger.Info("Encrypting payloads", slog.String("active_key", payloads.ActiveKey()))
+	}
 
 	// Create Temporal client
-	c, err := client.NewClient(client.Options{})
+	c, err := client.NewClient(clientOptions)
 	if err != nil {
-		logger.Error("Failed to create Temporal client", slog.String("error", err.Error()))
-		os.Exit(1)
+		return fmt.Errorf("create Temporal client: %w", err)
 	}
 	defer c.Close()
 
 	// Create worker
-	w := worker.New(c, TaskQueueName, worker.Options{})
+	w := worker.New(c, TaskQueueName, worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{tel.WorkerInterceptor()},
+	})
 
 	// Register workflow and activities
 	w.RegisterWorkflow(TravelBookingWorkflow)
-	w.RegisterActivity(BookHotelActivity)
-	w.RegisterActivity(CancelHotelActivity)
-	w.RegisterActivity(BookFlightActivity)
-	w.RegisterActivity(CancelFlightActivity)
-	w.RegisterActivity(BookCarActivity)
-	w.RegisterActivity(CancelCarActivity)
-	w.RegisterActivity(SendEmailActivity)
+	for _, activity := range registeredActivities {
+		w.RegisterActivity(activity)
+	}
+
+	// Serve the booking API and its metrics alongside the worker; the worker
+	// stops if the API fails
+	api := NewBookingAPI(logger, c)
+	if faults != nil {
+		api.WithScenario(faults.Script(), simulator.NewClient(simulator.URLFromEnv(), http.DefaultClient))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", tel.Handler())
+	mux.Handle("/", api.Handler())
+	server := &http.Server{Addr: HTTPAddr, Handler: mux}
+	stop := make(chan any, 2)
+	apiErr := make(chan error, 1)
+	go func() {
+		logger.Info("Booking API started", slog.String("address", HTTPAddr))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			apiErr <- err
+			stop <- struct{}{}
+		}
+	}()
+	defer server.Shutdown(context.Background())
+	go func() {
+		stop <- <-worker.InterruptCh()
+	}()
 
 	// Start worker
-	err = w.Run(worker.InterruptCh())
-	if err != nil {
-		logger.Error("Worker failed to start", slog.String("error", err.Error()))
-		os.Exit(1)
+	if err := w.Run(stop); err != nil {
+		return fmt.Errorf("run worker: %w", err)
+	}
+	select {
+	case err := <-apiErr:
+		return fmt.Errorf("booking API: %w", err)
+	default:
+		return nil
 	}
 }
@@ -0,0 +1,78 @@
+package notification
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// Environment variables configuring FromEnv
+const (
+	SenderEnv     = "NOTIFY_FROM"
+	OutboxDirEnv  = "NOTIFY_OUTBOX_DIR"
+	SMTPAddrEnv   = "NOTIFY_SMTP_ADDR"
+	WebhookURLEnv = "NOTIFY_WEBHOOK_URL"
+	RecordFileEnv = "NOTIFY_RECORD_FILE"
+	DefaultSender = "bookings@travel.example"
+)
+
+// Default returns a notifier that only logs emails and keeps its records
+// in memory
+func Default() *Notifier {
+	return NewNotifier(slog.Default(), DefaultSender, NewLog(nil), map[string]Transport{"log": LogTransport{}})
+}
+
+// FromEnv returns a notifier with a transport for each of OutboxDirEnv,
+// SMTPAddrEnv and WebhookURLEnv that is set, or only logging if none is.
+// With RecordFileEnv set, records are appended to that file as JSON lines
+// (see OpenLog).
+func FromEnv(logger *slog.Logger) (*Notifier, error) {
+	transports := make(map[string]Transport)
+	if dir := os.Getenv(OutboxDirEnv); dir != "" {
+		outbox, err := NewOutbox(dir)
+		if err != nil {
+			return nil, err
+		}
+		transports["outbox"] = outbox
+	}
+	if addr := os.Getenv(SMTPAddrEnv); addr != "" {
+		transports["smtp"] = NewSMTP(addr, nil)
+	}
+	if url := os.Getenv(WebhookURLEnv); url != "" {
+		transports["webhook"] = NewWebhook(url, http.DefaultClient)
+	}
+	if len(transports) == 0 {
+		transports["log"] = LogTransport{Logger: logger}
+	}
+
+	log := NewLog(nil)
+	if path := os.Getenv(RecordFileEnv); path != "" {
+		var err error
+		if log, err = OpenLog(path); err != nil {
+			return nil, err
+		}
+	}
+
+	from := os.Getenv(SenderEnv)
+	if from == "" {
+		from = DefaultSender
+	}
+	return NewNotifier(logger, from, log, transports), nil
+}
+
+// OpenLog returns a log appending to the JSON lines file at path, loaded
+// with the records already in it so a restarted worker does not send them
+// again. The file stays open for the life of the process.
+func OpenLog(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open notification records: %w", err)
+	}
+	log := NewLog(f)
+	if err := log.Load(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return log, nil
+}
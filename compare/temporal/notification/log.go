@@ -0,0 +1,119 @@
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of sending through one transport
+type Status string
+
+const (
+	StatusSent    Status = "sent"
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+// Record is one attempt to send a message through one transport
+type Record struct {
+	At        time.Time `json:"at"`
+	Key       string    `json:"key"`
+	MessageID string    `json:"message_id"`
+	BookingID string    `json:"booking_id"`
+	Event     Event     `json:"event"`
+	Transport string    `json:"transport"`
+	To        string    `json:"to,omitempty"`
+	Subject   string    `json:"subject"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// MaxRecent is how many records a Log keeps in memory for Records
+const MaxRecent = 1000
+
+// Log is an append-only record of sends. Only the most recent MaxRecent
+// records are kept in memory; if the log has a writer, every record is
+// written to it as JSON lines. Which sends went out is kept for every key.
+type Log struct {
+	mu     sync.Mutex
+	w      io.Writer
+	recent []Record
+	sent   map[sendKey]struct{}
+}
+
+// sendKey is one message through one transport
+type sendKey struct {
+	key       string
+	transport string
+}
+
+// NewLog returns a log writing records to w; w may be nil
+func NewLog(w io.Writer) *Log {
+	return &Log{w: w, sent: make(map[sendKey]struct{})}
+}
+
+// Load reads records written by an earlier log, so messages sent before a
+// restart are not sent again. It does not write them back out.
+func (l *Log) Load(r io.Reader) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	dec := json.NewDecoder(r)
+	for {
+		var record Record
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("load notification records: %w", err)
+		}
+		l.add(record)
+	}
+}
+
+// Add appends r to the log
+func (l *Log) Add(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(r)
+	if l.w == nil {
+		return nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = l.w.Write(append(data, '\n'))
+	return err
+}
+
+func (l *Log) add(r Record) {
+	if len(l.recent) == MaxRecent {
+		l.recent = slices.Delete(l.recent, 0, 1)
+	}
+	l.recent = append(l.recent, r)
+	if r.Status != StatusFailed {
+		l.sent[sendKey{r.Key, r.Transport}] = struct{}{}
+	}
+}
+
+// Records returns the most recent records, oldest first
+func (l *Log) Records() []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.recent)
+}
+
+// Sent reports whether the message with key has been sent or skipped by
+// transport, so it must not be sent again
+func (l *Log) Sent(key, transport string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.sent[sendKey{key, transport}]
+	return ok
+}
@@ -0,0 +1,219 @@
+// Package notification tells travellers what happened to their trip. Each
+// event has an html/template under templates/, rendered into an Email and
+// handed to every configured Transport: a maildir outbox, an SMTP server or a
+// webhook. Every send, skip and failure is appended to a Log for auditing.
+//
+// Sends are keyed by the caller's idempotency key, so a retried send only
+// goes out through the transports that failed the first time.
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"maps"
+	"mime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// Event says what happened to the trip and picks the template
+type Event string
+
+const (
+	// EventConfirmed: every component is booked
+	EventConfirmed Event = "confirmed"
+	// EventPartial: the trip goes ahead without a component
+	EventPartial Event = "partial"
+	// EventCancelled: the trip is cancelled and unwound
+	EventCancelled Event = "cancelled"
+	// EventNeedsApproval: the user must accept a trip without a component
+	// before ReplyBy
+	EventNeedsApproval Event = "needs_approval"
+	// EventReminder: the trip starts today
+	EventReminder Event = "reminder"
+	// EventUpdated: something changed that needs no answer, such as the
+	// hotel booking after retries or a provider cancelling during the trip
+	EventUpdated Event = "updated"
+)
+
+// ErrUnknownEvent is returned for a message whose event has no template;
+// sending it again cannot succeed
+var ErrUnknownEvent = errors.New("unknown notification event")
+
+// ErrInvalidHeader is returned for an email whose headers would break the
+// message, such as a subject with a line break; sending it again cannot
+// succeed
+var ErrInvalidHeader = errors.New("invalid email header")
+
+// Message is what the workflow asks to tell the user
+type Message struct {
+	Event     Event
+	BookingID string
+	Contact   types.Contact
+	Subject   string
+	// Detail is one or two sentences on what happened
+	Detail string
+	// Component is the part of the trip the message is about, if any
+	Component string
+	// ReplyBy is the deadline for an EventNeedsApproval answer
+	ReplyBy time.Time
+}
+
+// Email is a rendered message, as sent by every transport
+type Email struct {
+	MessageID string    `json:"message_id"`
+	Event     Event     `json:"event"`
+	BookingID string    `json:"booking_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Subject   string    `json:"subject"`
+	HTML      string    `json:"html"`
+	Date      time.Time `json:"date"`
+}
+
+// Bytes returns the email as an RFC 5322 message. To and Subject come from
+// the booking, so they are Q-encoded when not plain ASCII, and a header
+// holding a line break is rejected rather than let it add headers of its own.
+func (e Email) Bytes() ([]byte, error) {
+	for _, header := range [][2]string{{"Message-ID", e.MessageID}, {"From", e.From}, {"To", e.To}, {"Subject", e.Subject}} {
+		if strings.ContainsAny(header[1], "\r\n") {
+			return nil, fmt.Errorf("%w: %s contains a line break", ErrInvalidHeader, header[0])
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", e.MessageID)
+	fmt.Fprintf(&b, "Date: %s\r\n", e.Date.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", mime.QEncoding.Encode("utf-8", e.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", e.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(e.HTML)
+	return b.Bytes(), nil
+}
+
+//go:embed templates
+var templateFiles embed.FS
+
+// templates holds one template per event, each wrapped in the shared layout
+var templates = func() map[Event]*template.Template {
+	byEvent := make(map[Event]*template.Template)
+	for _, event := range []Event{EventConfirmed, EventPartial, EventCancelled, EventNeedsApproval, EventReminder, EventUpdated} {
+		byEvent[event] = template.Must(template.ParseFS(templateFiles,
+			"templates/layout.html", "templates/"+string(event)+".html"))
+	}
+	return byEvent
+}()
+
+// Render renders msg from sender as an email. The message ID is derived from
+// key so every attempt of one send carries the same ID.
+func Render(msg Message, from, key string, now time.Time) (Email, error) {
+	tmpl, ok := templates[msg.Event]
+	if !ok {
+		return Email{}, fmt.Errorf("%w %q", ErrUnknownEvent, msg.Event)
+	}
+	var html bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&html, "layout", msg); err != nil {
+		return Email{}, fmt.Errorf("render %s notification: %w", msg.Event, err)
+	}
+	return Email{
+		MessageID: messageID(key),
+		Event:     msg.Event,
+		BookingID: msg.BookingID,
+		From:      from,
+		To:        msg.Contact.Email,
+		Subject:   msg.Subject,
+		HTML:      html.String(),
+		Date:      now,
+	}, nil
+}
+
+func messageID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "<" + hex.EncodeToString(sum[:12]) + "@travel-booking>"
+}
+
+// Notifier renders messages and sends them through its transports
+type Notifier struct {
+	logger     *slog.Logger
+	from       string
+	log        *Log
+	transports map[string]Transport
+	now        func() time.Time
+}
+
+// NewNotifier returns a notifier sending from the given address through the
+// named transports and recording every attempt in log
+func NewNotifier(logger *slog.Logger, from string, log *Log, transports map[string]Transport) *Notifier {
+	return &Notifier{
+		logger:     logger,
+		from:       from,
+		log:        log,
+		transports: transports,
+		now:        time.Now,
+	}
+}
+
+// Log returns the record of every send
+func (n *Notifier) Log() *Log {
+	return n.log
+}
+
+// Send renders msg and sends it through every transport that has not
+// already sent it under key. Transports that need an email address skip
+// users without one. The error joins every transport failure.
+func (n *Notifier) Send(ctx context.Context, key string, msg Message) error {
+	email, err := Render(msg, n.from, key, n.now())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(n.transports)) {
+		if n.log.Sent(key, name) {
+			continue
+		}
+
+		record := Record{
+			Key:       key,
+			MessageID: email.MessageID,
+			BookingID: email.BookingID,
+			Event:     email.Event,
+			Transport: name,
+			To:        email.To,
+			Subject:   email.Subject,
+			Status:    StatusSent,
+		}
+		err := n.transports[name].Send(ctx, email)
+		switch {
+		case errors.Is(err, ErrNoAddress):
+			record.Status = StatusSkipped
+		case err != nil:
+			record.Status, record.Error = StatusFailed, err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		record.At = n.now()
+		if err := n.log.Add(record); err != nil {
+			n.logger.Error("Failed to record notification", slog.String("error", err.Error()))
+		}
+
+		n.logger.Info("Notification",
+			slog.String("booking_id", email.BookingID),
+			slog.String("event", string(email.Event)),
+			slog.String("transport", name),
+			slog.String("status", string(record.Status)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,213 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func testMessage(event Event) Message {
+	return Message{
+		Event:     event,
+		BookingID: "TRIP-1",
+		Contact:   types.Contact{Name: "Ada <Admin>", Email: "ada@example.com"},
+		Subject:   "Travel Booking Confirmed",
+		Detail:    "Your travel booking TRIP-1 has been confirmed",
+		Component: types.ComponentCar,
+	}
+}
+
+func TestRenderEveryEvent(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{event: EventConfirmed, want: "Have a good trip"},
+		{event: EventPartial, want: "does not include a car"},
+		{event: EventCancelled, want: "has been cancelled"},
+		{event: EventNeedsApproval, want: "go ahead without the car by Tue 2 Jun 2026 09:00 UTC"},
+		{event: EventReminder, want: "Safe travels"},
+		{event: EventUpdated, want: "has been confirmed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.event), func(t *testing.T) {
+			msg := testMessage(tt.event)
+			msg.ReplyBy = time.Date(2026, 6, 2, 9, 0, 0, 0, time.UTC)
+
+			email, err := Render(msg, DefaultSender, "key-1", time.Now())
+			require.NoError(t, err)
+			require.Contains(t, email.HTML, tt.want)
+			require.Contains(t, email.HTML, "Hello Ada &lt;Admin&gt;", "names are escaped")
+			require.Contains(t, email.HTML, "Travel booking TRIP-1")
+			require.Equal(t, "ada@example.com", email.To)
+			require.Equal(t, DefaultSender, email.From)
+		})
+	}
+}
+
+func TestRenderUnknownEvent(t *testing.T) {
+	_, err := Render(testMessage("lost"), DefaultSender, "key-1", time.Now())
+	require.ErrorIs(t, err, ErrUnknownEvent)
+}
+
+func TestMessageIDFollowsKey(t *testing.T) {
+	first, err := Render(testMessage(EventConfirmed), DefaultSender, "key-1", time.Now())
+	require.NoError(t, err)
+	again, err := Render(testMessage(EventConfirmed), DefaultSender, "key-1", time.Now())
+	require.NoError(t, err)
+	other, err := Render(testMessage(EventConfirmed), DefaultSender, "key-2", time.Now())
+	require.NoError(t, err)
+
+	require.Equal(t, first.MessageID, again.MessageID)
+	require.NotEqual(t, first.MessageID, other.MessageID)
+	data, err := first.Bytes()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), "Message-ID: "+first.MessageID+"\r\n"))
+}
+
+func TestEmailBytesEncodesHeaders(t *testing.T) {
+	msg := testMessage(EventConfirmed)
+	msg.Subject = "Réservation confirmée"
+	email, err := Render(msg, DefaultSender, "key-1", time.Now())
+	require.NoError(t, err)
+
+	data, err := email.Bytes()
+	require.NoError(t, err)
+	require.Contains(t, string(data), "\r\nSubject: =?utf-8?q?R=C3=A9servation_confirm=C3=A9e?=\r\n")
+	require.Contains(t, string(data), "\r\nTo: ada@example.com\r\n", "ASCII headers are left alone")
+}
+
+func TestEmailBytesRejectsLineBreaks(t *testing.T) {
+	for _, email := range []Email{
+		{To: "ada@example.com\r\nBcc: eve@example.com", Subject: "Travel Booking Confirmed"},
+		{To: "ada@example.com", Subject: "Travel Booking Confirmed\nBcc: eve@example.com"},
+	} {
+		_, err := email.Bytes()
+		require.ErrorIs(t, err, ErrInvalidHeader)
+	}
+}
+
+func TestNotifierRecordsEverySend(t *testing.T) {
+	var sent []string
+	failing := true
+	n := NewNotifier(discardLogger, DefaultSender, NewLog(nil), map[string]Transport{
+		"mail": TransportFunc(func(_ context.Context, email Email) error {
+			sent = append(sent, "mail:"+email.Subject)
+			return nil
+		}),
+		"webhook": TransportFunc(func(_ context.Context, email Email) error {
+			sent = append(sent, "webhook:"+email.Subject)
+			if failing {
+				return errors.New("connection refused")
+			}
+			return nil
+		}),
+	})
+	ctx := context.Background()
+	msg := testMessage(EventConfirmed)
+
+	err := n.Send(ctx, "key-1", msg)
+	require.ErrorContains(t, err, "webhook: connection refused")
+
+	// The retry only goes out through the transport that failed
+	failing = false
+	require.NoError(t, n.Send(ctx, "key-1", msg))
+	require.Equal(t, []string{
+		"mail:Travel Booking Confirmed",
+		"webhook:Travel Booking Confirmed",
+		"webhook:Travel Booking Confirmed",
+	}, sent)
+
+	records := n.Log().Records()
+	require.Len(t, records, 3)
+	require.Equal(t, []Status{StatusSent, StatusFailed, StatusSent},
+		[]Status{records[0].Status, records[1].Status, records[2].Status})
+	require.Equal(t, "connection refused", records[1].Error)
+	for _, r := range records {
+		require.Equal(t, "TRIP-1", r.BookingID)
+		require.Equal(t, EventConfirmed, r.Event)
+		require.False(t, r.At.IsZero())
+	}
+}
+
+func TestNotifierSkipsUsersWithoutAddress(t *testing.T) {
+	n := NewNotifier(discardLogger, DefaultSender, NewLog(nil), map[string]Transport{
+		"outbox": TransportFunc(func(_ context.Context, email Email) error {
+			if email.To == "" {
+				return ErrNoAddress
+			}
+			return nil
+		}),
+	})
+	msg := testMessage(EventCancelled)
+	msg.Contact.Email = ""
+
+	require.NoError(t, n.Send(context.Background(), "key-1", msg))
+	records := n.Log().Records()
+	require.Len(t, records, 1)
+	require.Equal(t, StatusSkipped, records[0].Status)
+	require.True(t, n.Log().Sent("key-1", "outbox"), "a skipped send is not retried")
+}
+
+func TestLogWritesJSONLines(t *testing.T) {
+	var out strings.Builder
+	log := NewLog(&out)
+	require.NoError(t, log.Add(Record{Key: "key-1", Transport: "smtp", Status: StatusSent}))
+	require.NoError(t, log.Add(Record{Key: "key-2", Transport: "smtp", Status: StatusFailed, Error: "timeout"}))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"status":"sent"`)
+	require.NotContains(t, lines[0], `"error"`)
+	require.Contains(t, lines[1], `"error":"timeout"`)
+
+	require.True(t, log.Sent("key-1", "smtp"))
+	require.False(t, log.Sent("key-2", "smtp"), "failed sends can be retried")
+	require.False(t, log.Sent("key-1", "webhook"))
+}
+
+func TestOpenLogLoadsEarlierSends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	log, err := OpenLog(path)
+	require.NoError(t, err)
+	require.NoError(t, log.Add(Record{Key: "key-1", Transport: "smtp", Status: StatusSent}))
+	require.NoError(t, log.Add(Record{Key: "key-2", Transport: "smtp", Status: StatusFailed}))
+
+	// A restarted worker knows what went out before
+	restarted, err := OpenLog(path)
+	require.NoError(t, err)
+	require.True(t, restarted.Sent("key-1", "smtp"))
+	require.False(t, restarted.Sent("key-2", "smtp"))
+	require.Len(t, restarted.Records(), 2)
+
+	require.NoError(t, restarted.Add(Record{Key: "key-2", Transport: "smtp", Status: StatusSent}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 3, "loaded records are not written again")
+}
+
+func TestLogKeepsRecentRecords(t *testing.T) {
+	log := NewLog(nil)
+	for i := range MaxRecent + 10 {
+		require.NoError(t, log.Add(Record{Key: fmt.Sprintf("key-%d", i), Transport: "smtp", Status: StatusSent}))
+	}
+
+	records := log.Records()
+	require.Len(t, records, MaxRecent)
+	require.Equal(t, "key-10", records[0].Key)
+	require.True(t, log.Sent("key-0", "smtp"), "sends dropped from memory are still known")
+}
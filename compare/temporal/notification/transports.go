@@ -0,0 +1,192 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoAddress is returned by transports that deliver to an email address
+// when the user has none; the notifier records the send as skipped
+var ErrNoAddress = errors.New("no email address")
+
+// Transport delivers a rendered email
+type Transport interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// TransportFunc adapts a function to a Transport
+type TransportFunc func(ctx context.Context, email Email) error
+
+func (f TransportFunc) Send(ctx context.Context, email Email) error {
+	return f(ctx, email)
+}
+
+// LogTransport only logs the email; it stands in when no other transport is
+// configured. A nil logger logs to slog.Default.
+type LogTransport struct {
+	Logger *slog.Logger
+}
+
+func (t LogTransport) Send(_ context.Context, email Email) error {
+	logger := t.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info("Email sent",
+		slog.String("to", email.To),
+		slog.String("subject", email.Subject))
+	return nil
+}
+
+// Outbox writes emails into a maildir, one file per message in new/. The
+// file is named after the message ID, so sending the same message twice
+// leaves one file.
+type Outbox struct {
+	dir string
+}
+
+// NewOutbox returns an outbox writing to the maildir at dir, creating it if
+// needed
+func NewOutbox(dir string) (*Outbox, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("create outbox: %w", err)
+		}
+	}
+	return &Outbox{dir: dir}, nil
+}
+
+// Path returns where an email is delivered
+func (o *Outbox) Path(email Email) string {
+	return filepath.Join(o.dir, "new", outboxName(email))
+}
+
+func (o *Outbox) Send(_ context.Context, email Email) error {
+	if email.To == "" {
+		return ErrNoAddress
+	}
+	data, err := email.Bytes()
+	if err != nil {
+		return err
+	}
+	// Write to tmp/ then move into new/ so readers never see half a message
+	tmp := filepath.Join(o.dir, "tmp", outboxName(email))
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write outbox: %w", err)
+	}
+	if err := os.Rename(tmp, o.Path(email)); err != nil {
+		return fmt.Errorf("deliver to outbox: %w", err)
+	}
+	return nil
+}
+
+func outboxName(email Email) string {
+	id, _, _ := strings.Cut(strings.Trim(email.MessageID, "<>"), "@")
+	return id + ".eml"
+}
+
+// SMTP sends emails to an SMTP server
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTP returns a transport for the server at addr ("host:port"); auth may
+// be nil
+func NewSMTP(addr string, auth smtp.Auth) *SMTP {
+	return &SMTP{addr: addr, auth: auth}
+}
+
+func (s *SMTP) Send(ctx context.Context, email Email) error {
+	if email.To == "" {
+		return ErrNoAddress
+	}
+	data, err := email.Bytes()
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(s.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+
+	if s.auth != nil {
+		if err := c.Auth(s.auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(email.From); err != nil {
+		return fmt.Errorf("smtp MAIL: %w", err)
+	}
+	if err := c.Rcpt(email.To); err != nil {
+		return fmt.Errorf("smtp RCPT: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return c.Quit()
+}
+
+// Webhook posts emails as JSON to a URL. The message ID is sent as the
+// Idempotency-Key header so the receiver can drop repeats.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook returns a transport posting to url
+func NewWebhook(url string, client *http.Client) *Webhook {
+	return &Webhook{url: url, client: client}
+}
+
+func (h *Webhook) Send(ctx context.Context, email Email) error {
+	body, err := json.Marshal(email)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", email.MessageID)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package notification
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testEmail(t *testing.T) Email {
+	email, err := Render(testMessage(EventConfirmed), DefaultSender, "key-1", time.Now())
+	require.NoError(t, err)
+	return email
+}
+
+func TestOutbox(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	outbox, err := NewOutbox(dir)
+	require.NoError(t, err)
+	email := testEmail(t)
+
+	require.NoError(t, outbox.Send(context.Background(), email))
+	require.NoError(t, outbox.Send(context.Background(), email), "resending replaces the message")
+
+	delivered, err := os.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	require.Len(t, delivered, 1)
+	pending, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	data, err := os.ReadFile(outbox.Path(email))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "To: ada@example.com\r\n")
+	require.Contains(t, string(data), "Subject: Travel Booking Confirmed\r\n")
+	require.Contains(t, string(data), "Have a good trip")
+
+	email.To = ""
+	require.ErrorIs(t, outbox.Send(context.Background(), email), ErrNoAddress)
+}
+
+// smtpMessage is one message accepted by the SMTP stand-in
+type smtpMessage struct {
+	From, To, Data string
+}
+
+// newSMTPStandIn starts a minimal SMTP server on localhost that accepts every
+// message, or rejects every recipient if rejectRcpt is set
+func newSMTPStandIn(t *testing.T, rejectRcpt bool) (string, func() []smtpMessage) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	var received []smtpMessage
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+				reply("220 localhost stand-in")
+
+				var msg smtpMessage
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					cmd := strings.TrimSpace(line)
+					switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); {
+					case verb == "EHLO" || verb == "HELO":
+						reply("250 localhost")
+					case strings.HasPrefix(strings.ToUpper(cmd), "MAIL FROM:"):
+						msg.From = strings.Trim(cmd[len("MAIL FROM:"):], "<> ")
+						reply("250 OK")
+					case strings.HasPrefix(strings.ToUpper(cmd), "RCPT TO:"):
+						if rejectRcpt {
+							reply("550 no such user")
+							continue
+						}
+						msg.To = strings.Trim(cmd[len("RCPT TO:"):], "<> ")
+						reply("250 OK")
+					case verb == "DATA":
+						reply("354 end with <CRLF>.<CRLF>")
+						var data strings.Builder
+						for {
+							line, err := r.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if line == ".\r\n" {
+								break
+							}
+							data.WriteString(line)
+						}
+						msg.Data = data.String()
+						mu.Lock()
+						received = append(received, msg)
+						mu.Unlock()
+						reply("250 queued")
+					case verb == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 OK")
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String(), func() []smtpMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]smtpMessage(nil), received...)
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	addr, received := newSMTPStandIn(t, false)
+	email := testEmail(t)
+
+	require.NoError(t, NewSMTP(addr, nil).Send(context.Background(), email))
+
+	messages := received()
+	require.Len(t, messages, 1)
+	require.Equal(t, DefaultSender, messages[0].From)
+	require.Equal(t, "ada@example.com", messages[0].To)
+	require.Contains(t, messages[0].Data, "Message-ID: "+email.MessageID)
+	require.Contains(t, messages[0].Data, "Content-Type: text/html; charset=utf-8")
+	require.Contains(t, messages[0].Data, "Have a good trip")
+}
+
+func TestSMTPFailures(t *testing.T) {
+	addr, received := newSMTPStandIn(t, true)
+	err := NewSMTP(addr, nil).Send(context.Background(), testEmail(t))
+	require.ErrorContains(t, err, "smtp RCPT")
+	require.Empty(t, received())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := ln.Addr().String()
+	ln.Close()
+	require.ErrorContains(t, NewSMTP(closed, nil).Send(context.Background(), testEmail(t)), "smtp dial")
+
+	email := testEmail(t)
+	email.To = ""
+	require.ErrorIs(t, NewSMTP(addr, nil).Send(context.Background(), email), ErrNoAddress)
+}
+
+func TestWebhook(t *testing.T) {
+	var got Email
+	var key string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("Idempotency-Key")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	email := testEmail(t)
+	hook := NewWebhook(server.URL, server.Client())
+
+	require.NoError(t, hook.Send(context.Background(), email))
+	require.Equal(t, email.MessageID, key)
+	require.Equal(t, email.Subject, got.Subject)
+	require.Equal(t, EventConfirmed, got.Event)
+
+	// The webhook needs no email address
+	email.To = ""
+	require.NoError(t, hook.Send(context.Background(), email))
+
+	status = http.StatusBadGateway
+	require.ErrorContains(t, hook.Send(context.Background(), email), "502")
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, env := range []string{OutboxDirEnv, SMTPAddrEnv, WebhookURLEnv, RecordFileEnv, SenderEnv} {
+		t.Setenv(env, "")
+	}
+	n, err := FromEnv(discardLogger)
+	require.NoError(t, err)
+	require.Contains(t, n.transports, "log")
+	require.Equal(t, DefaultSender, n.from)
+
+	dir := t.TempDir()
+	t.Setenv(OutboxDirEnv, filepath.Join(dir, "outbox"))
+	t.Setenv(WebhookURLEnv, "http://localhost:1/hook")
+	t.Setenv(RecordFileEnv, filepath.Join(dir, "records.jsonl"))
+	t.Setenv(SenderEnv, "trips@example.com")
+	n, err = FromEnv(discardLogger)
+	require.NoError(t, err)
+	require.Len(t, n.transports, 2)
+	require.Contains(t, n.transports, "outbox")
+	require.Contains(t, n.transports, "webhook")
+	require.Equal(t, "trips@example.com", n.from)
+
+	require.NoError(t, n.Log().Add(Record{Key: "key-1", Status: StatusSent}))
+	data, err := os.ReadFile(filepath.Join(dir, "records.jsonl"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"key":"key-1"`)
+}
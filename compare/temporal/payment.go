@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/activities"
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// paymentCall returns ctx for a payment operation of this workflow run, and
+// the idempotency key to pass the gateway. The operation runs as activity
+// payment-<operation>, and the key is the one activities.IdempotencyKey gives
+// that activity, so payments dedupe like every other provider call: retries
+// reuse the key, so the gateway returns the original result instead of moving
+// the money twice, and a booking submitted again starts a new run that is
+// never given the earlier run's results.
+func paymentCall(ctx workflow.Context, operation string) (workflow.Context, string) {
+	activityID := "payment-" + operation
+	opts := workflow.GetActivityOptions(ctx)
+	opts.ActivityID = activityID
+	ctx = workflow.WithActivityOptions(ctx, opts)
+	execution := workflow.GetInfo(ctx).WorkflowExecution
+	return ctx, activities.RunKey(execution.ID, execution.RunID, activityID)
+}
+
+// authorizePayment holds the booking's TotalAmount and records how to release
+// it. Bookings without a payment are not charged.
+func authorizePayment(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga) error {
+	if booking.Payment == nil {
+		return nil
+	}
+
+	var confirmation types.BookingConfirmation
+	authorizeCtx, key := paymentCall(ctx, "authorize")
+	err := executeActivity(authorizeCtx, AuthorizePaymentActivity, key, booking.Payment.Amount).Get(ctx, &confirmation)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to authorize payment", slog.String("error", err.Error()))
+		recordFailure(ctx, booking, types.ComponentPayment, err)
+		setStatus(ctx, booking, types.ComponentBooking, types.StatusFailed, "payment authorization failed")
+		return err
+	}
+
+	booking.Payment.AuthorizationRef = confirmation.BookingRef
+	booking.Payment.AuthorizedAt = confirmation.ConfirmedAt
+	setStatus(ctx, booking, types.ComponentPayment, types.StatusAuthorized, "authorized as "+confirmation.BookingRef)
+	compensations.AddCompensation(types.ComponentPayment, releasePayment(booking))
+	return nil
+}
+
+// capturePayment takes the authorized payment once the bookings are settled.
+// A trip accepted without a car is charged without that car's price. If the
+// capture fails the trip is unwound, releasing the authorization.
+func capturePayment(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga) error {
+	if booking.Payment == nil {
+		return nil
+	}
+
+	amount := booking.Payment.Amount
+	for _, component := range failedCars(*booking) {
+		amount = max(amount-booking.Car(component).Price, 0)
+	}
+
+	var confirmation types.BookingConfirmation
+	captureCtx, key := paymentCall(ctx, "capture")
+	err := executeActivity(captureCtx, CapturePaymentActivity, key, booking.Payment.AuthorizationRef, amount).Get(ctx, &confirmation)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to capture payment", slog.String("error", err.Error()))
+		recordFailure(ctx, booking, types.ComponentPayment, err)
+		err = compensate(ctx, booking, compensations, err)
+		setStatus(ctx, booking, types.ComponentBooking, types.StatusFailed, "payment capture failed")
+		return err
+	}
+
+	booking.Payment.CaptureRef = confirmation.BookingRef
+	booking.Payment.CapturedAmount = confirmation.Price
+	setStatus(ctx, booking, types.ComponentPayment, types.StatusCaptured,
+		fmt.Sprintf("captured %.2f as %s", confirmation.Price, confirmation.BookingRef))
+	return nil
+}
+
+// releasePayment returns the compensation for a payment: an authorization
+// that was never captured is voided, a captured one is refunded in full
+func releasePayment(booking *types.TravelBooking) saga.Compensation {
+	return func(ctx workflow.Context) error {
+		payment := booking.Payment
+		if payment.Status == types.StatusCaptured {
+			refundCtx, key := paymentCall(ctx, "refund")
+			err := executeActivity(refundCtx, RefundPaymentActivity, key, payment.AuthorizationRef, payment.CapturedAmount).Get(ctx, nil)
+			if err != nil {
+				return err
+			}
+			payment.RefundedAmount = payment.CapturedAmount
+			setStatus(ctx, booking, types.ComponentPayment, types.StatusRefunded, fmt.Sprintf("refunded %.2f", payment.CapturedAmount))
+			return nil
+		}
+
+		voidCtx, key := paymentCall(ctx, "void")
+		if err := executeActivity(voidCtx, VoidPaymentActivity, key, payment.AuthorizationRef).Get(ctx, nil); err != nil {
+			return err
+		}
+		setStatus(ctx, booking, types.ComponentPayment, types.StatusVoided, "authorization released")
+		return nil
+	}
+}
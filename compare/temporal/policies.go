@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/policy"
+)
+
+// ActivityPolicyFileEnv names the environment variable pointing at an
+// optional JSON or YAML policy file; see package policy for the format
+const ActivityPolicyFileEnv = "ACTIVITY_POLICY_FILE"
+
+// activityPolicies is loaded once at worker startup, before any workflow
+// runs; nil keeps the options hard-coded in the workflow. Activity options
+// are not checked on replay, so changing the file between deploys is safe.
+var activityPolicies *policy.Config
+
+// registeredActivities lists every activity the worker registers
+var registeredActivities = []any{
+	BookHotelActivity,
+	CancelHotelActivity,
+	BookFlightActivity,
+	CancelFlightActivity,
+	BookCarActivity,
+	CancelCarActivity,
+	VerifyHotelActivity,
+	VerifyFlightActivity,
+	VerifyCarActivity,
+	HoldHotelActivity,
+	ConfirmHotelActivity,
+	ReleaseHotelActivity,
+	HoldFlightActivity,
+	ConfirmFlightActivity,
+	ReleaseFlightActivity,
+	HoldCarActivity,
+	ConfirmCarActivity,
+	ReleaseCarActivity,
+	AuthorizePaymentActivity,
+	CapturePaymentActivity,
+	VoidPaymentActivity,
+	RefundPaymentActivity,
+	SendNotificationActivity,
+	SendEmailActivity,
+}
+
+// activityNames returns the names activities are registered under
+func activityNames() []string {
+	names := make([]string, 0, len(registeredActivities))
+	for _, activity := range registeredActivities {
+		names = append(names, activityName(activity))
+	}
+	return names
+}
+
+// activityName returns the name Temporal registers an activity function under
+func activityName(activity any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(activity).Pointer()).Name()
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// executeActivity runs activity with the options from ctx, overridden by its
+// entry in the policy file if it has one. Calls on a context from
+// withOwnRetry keep their retry policy; the file only sets their timeouts.
+func executeActivity(ctx workflow.Context, activity any, args ...any) workflow.Future {
+	base := workflow.GetActivityOptions(ctx)
+	opts := activityPolicies.Apply(activityName(activity), base)
+	if ctx.Value(ownRetryKey{}) != nil {
+		opts.RetryPolicy = base.RetryPolicy
+	}
+	return workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, opts), activity, args...)
+}
+
+// ownRetryKey marks a context whose retry policy the workflow relies on
+type ownRetryKey struct{}
+
+// withOwnRetry returns ctx running activities under retry whatever the
+// policy file says, for callers that count attempts themselves: the hotel's
+// progressive schedule, compensations that report how often they were tried
+// before escalating, and hold releases
+func withOwnRetry(ctx workflow.Context, retry temporal.RetryPolicy) workflow.Context {
+	return workflow.WithValue(workflow.WithRetryPolicy(ctx, retry), ownRetryKey{}, true)
+}
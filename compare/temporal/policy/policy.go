@@ -0,0 +1,200 @@
+// Package policy loads per-activity retry and timeout policies from a JSON or
+// YAML file so they can be tuned without rebuilding the worker.
+//
+// A file has a default policy, applied to the workflow's base activity
+// options, and one entry per activity name that overrides whatever options
+// that call would otherwise use. Any field left out keeps its existing value:
+//
+//	default:
+//	  start_to_close_timeout: 5m
+//	activities:
+//	  BookFlightActivity:
+//	    start_to_close_timeout: 30s
+//	    retry:
+//	      initial_interval: 2s
+//	      maximum_attempts: 5
+//	      non_retryable_error_types: [NotAvailableError]
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"gopkg.in/yaml.v3"
+
+	"github.com/leowmjw/go-durable-x/temporal/duration"
+)
+
+// RetryPolicy mirrors temporal.RetryPolicy
+type RetryPolicy struct {
+	InitialInterval        duration.Duration `json:"initial_interval" yaml:"initial_interval"`
+	BackoffCoefficient     float64           `json:"backoff_coefficient" yaml:"backoff_coefficient"`
+	MaximumInterval        duration.Duration `json:"maximum_interval" yaml:"maximum_interval"`
+	MaximumAttempts        int32             `json:"maximum_attempts" yaml:"maximum_attempts"`
+	NonRetryableErrorTypes []string          `json:"non_retryable_error_types" yaml:"non_retryable_error_types"`
+}
+
+// ActivityPolicy holds the timeouts and retry policy for one activity
+type ActivityPolicy struct {
+	StartToCloseTimeout    duration.Duration `json:"start_to_close_timeout" yaml:"start_to_close_timeout"`
+	ScheduleToCloseTimeout duration.Duration `json:"schedule_to_close_timeout" yaml:"schedule_to_close_timeout"`
+	HeartbeatTimeout       duration.Duration `json:"heartbeat_timeout" yaml:"heartbeat_timeout"`
+	Retry                  *RetryPolicy      `json:"retry" yaml:"retry"`
+}
+
+// Config is the policy file
+type Config struct {
+	Default    ActivityPolicy            `json:"default" yaml:"default"`
+	Activities map[string]ActivityPolicy `json:"activities" yaml:"activities"`
+}
+
+// Load reads a policy file, picking the format from its extension (.yaml,
+// .yml or .json), and validates it against the known activity names
+func Load(path string, activities ...string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy file: %w", err)
+	}
+
+	var cfg *Config
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		cfg, err = ParseYAML(data)
+	case ".json":
+		cfg, err = ParseJSON(data)
+	default:
+		return nil, fmt.Errorf("policy file %s: unsupported extension %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("policy file %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(activities...); err != nil {
+		return nil, fmt.Errorf("policy file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseJSON decodes a policy file, rejecting unknown fields
+func ParseJSON(data []byte) (*Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ParseYAML decodes a policy file, rejecting unknown fields
+func ParseYAML(data []byte) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks every policy is usable. If activities are given, policies
+// for any other activity name are rejected as typos.
+func (c *Config) Validate(activities ...string) error {
+	errs := []error{c.Default.validate("default")}
+	for name, p := range c.Activities {
+		if len(activities) > 0 && !slices.Contains(activities, name) {
+			errs = append(errs, fmt.Errorf("activities.%s: unknown activity", name))
+		}
+		errs = append(errs, p.validate("activities."+name))
+	}
+	return errors.Join(errs...)
+}
+
+func (p ActivityPolicy) validate(path string) error {
+	var errs []error
+	if p.StartToCloseTimeout < 0 || p.ScheduleToCloseTimeout < 0 || p.HeartbeatTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s: timeouts must not be negative", path))
+	}
+	if r := p.Retry; r != nil {
+		if r.InitialInterval < 0 || r.MaximumInterval < 0 {
+			errs = append(errs, fmt.Errorf("%s.retry: intervals must not be negative", path))
+		}
+		if r.MaximumInterval > 0 && r.MaximumInterval < r.InitialInterval {
+			errs = append(errs, fmt.Errorf("%s.retry: maximum_interval is less than initial_interval", path))
+		}
+		if r.BackoffCoefficient != 0 && r.BackoffCoefficient < 1 {
+			errs = append(errs, fmt.Errorf("%s.retry: backoff_coefficient must be at least 1", path))
+		}
+		if r.MaximumAttempts < 0 {
+			errs = append(errs, fmt.Errorf("%s.retry: maximum_attempts must not be negative", path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyDefault overlays the default policy on the workflow's base activity
+// options. Fields left unset in the file keep their value from opts. A nil
+// Config returns opts unchanged.
+func (c *Config) ApplyDefault(opts workflow.ActivityOptions) workflow.ActivityOptions {
+	if c == nil {
+		return opts
+	}
+	return c.Default.apply(opts)
+}
+
+// Apply overlays the policy for a single activity on the options it would
+// otherwise run with. Fields left unset in the file keep their value from
+// opts. A nil Config, or an activity without a policy, returns opts unchanged.
+func (c *Config) Apply(activity string, opts workflow.ActivityOptions) workflow.ActivityOptions {
+	if c == nil {
+		return opts
+	}
+	if p, ok := c.Activities[activity]; ok {
+		opts = p.apply(opts)
+	}
+	return opts
+}
+
+func (p ActivityPolicy) apply(opts workflow.ActivityOptions) workflow.ActivityOptions {
+	if p.StartToCloseTimeout > 0 {
+		opts.StartToCloseTimeout = time.Duration(p.StartToCloseTimeout)
+	}
+	if p.ScheduleToCloseTimeout > 0 {
+		opts.ScheduleToCloseTimeout = time.Duration(p.ScheduleToCloseTimeout)
+	}
+	if p.HeartbeatTimeout > 0 {
+		opts.HeartbeatTimeout = time.Duration(p.HeartbeatTimeout)
+	}
+	if r := p.Retry; r != nil {
+		retry := &temporal.RetryPolicy{}
+		if opts.RetryPolicy != nil {
+			*retry = *opts.RetryPolicy
+		}
+		if r.InitialInterval > 0 {
+			retry.InitialInterval = time.Duration(r.InitialInterval)
+		}
+		if r.BackoffCoefficient > 0 {
+			retry.BackoffCoefficient = r.BackoffCoefficient
+		}
+		if r.MaximumInterval > 0 {
+			retry.MaximumInterval = time.Duration(r.MaximumInterval)
+		}
+		if r.MaximumAttempts > 0 {
+			retry.MaximumAttempts = r.MaximumAttempts
+		}
+		if r.NonRetryableErrorTypes != nil {
+			retry.NonRetryableErrorTypes = slices.Clone(r.NonRetryableErrorTypes)
+		}
+		opts.RetryPolicy = retry
+	}
+	return opts
+}
@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/duration"
+)
+
+const yamlPolicies = `
+default:
+  start_to_close_timeout: 2m
+activities:
+  BookFlightActivity:
+    start_to_close_timeout: 30s
+    retry:
+      initial_interval: 2s
+      maximum_attempts: 5
+      non_retryable_error_types: [NotAvailableError]
+`
+
+const jsonPolicies = `{
+  "default": {"start_to_close_timeout": "2m"},
+  "activities": {
+    "BookFlightActivity": {
+      "start_to_close_timeout": "30s",
+      "retry": {
+        "initial_interval": "2s",
+        "maximum_attempts": 5,
+        "non_retryable_error_types": ["NotAvailableError"]
+      }
+    }
+  }
+}`
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "yaml", file: "policies.yaml", content: yamlPolicies},
+		{name: "yml", file: "policies.yml", content: yamlPolicies},
+		{name: "json", file: "policies.json", content: jsonPolicies},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeFile(t, tt.file, tt.content), "BookFlightActivity", "BookCarActivity")
+			require.NoError(t, err)
+
+			require.Equal(t, duration.Duration(2*time.Minute), cfg.Default.StartToCloseTimeout)
+			flight := cfg.Activities["BookFlightActivity"]
+			require.Equal(t, duration.Duration(30*time.Second), flight.StartToCloseTimeout)
+			require.NotNil(t, flight.Retry)
+			require.Equal(t, duration.Duration(2*time.Second), flight.Retry.InitialInterval)
+			require.Equal(t, int32(5), flight.Retry.MaximumAttempts)
+			require.Equal(t, []string{"NotAvailableError"}, flight.Retry.NonRetryableErrorTypes)
+		})
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown extension", file: "policies.toml", content: "", wantErr: "unsupported extension"},
+		{name: "bad duration", file: "p.yaml", content: "default:\n  start_to_close_timeout: soon\n", wantErr: "invalid duration"},
+		{name: "number duration", file: "p.json", content: `{"default": {"start_to_close_timeout": 30}}`, wantErr: "duration must be a string"},
+		{name: "unknown field yaml", file: "p.yaml", content: "default:\n  timeout: 1m\n", wantErr: "timeout"},
+		{name: "unknown field json", file: "p.json", content: `{"defaults": {}}`, wantErr: "defaults"},
+		{name: "unknown activity", file: "p.yaml", content: "activities:\n  BookBoatActivity: {}\n", wantErr: "BookBoatActivity: unknown activity"},
+		{name: "negative timeout", file: "p.yaml", content: "default:\n  heartbeat_timeout: -1s\n", wantErr: "must not be negative"},
+		{
+			name:    "interval order",
+			file:    "p.yaml",
+			content: "activities:\n  BookCarActivity:\n    retry:\n      initial_interval: 1m\n      maximum_interval: 1s\n",
+			wantErr: "maximum_interval is less than initial_interval",
+		},
+		{
+			name:    "backoff",
+			file:    "p.yaml",
+			content: "activities:\n  BookCarActivity:\n    retry:\n      backoff_coefficient: 0.5\n",
+			wantErr: "backoff_coefficient must be at least 1",
+		},
+		{
+			name:    "attempts",
+			file:    "p.yaml",
+			content: "activities:\n  BookCarActivity:\n    retry:\n      maximum_attempts: -2\n",
+			wantErr: "maximum_attempts must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeFile(t, tt.file, tt.content), "BookFlightActivity", "BookCarActivity")
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorContains(t, err, "read policy file")
+}
+
+func TestConfig_Apply(t *testing.T) {
+	cfg, err := ParseYAML([]byte(yamlPolicies))
+	require.NoError(t, err)
+
+	base := workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumAttempts:    3,
+		},
+	}
+
+	defaults := cfg.ApplyDefault(base)
+	require.Equal(t, 2*time.Minute, defaults.StartToCloseTimeout)
+	require.Equal(t, base.RetryPolicy, defaults.RetryPolicy)
+
+	flight := cfg.Apply("BookFlightActivity", defaults)
+	require.Equal(t, 30*time.Second, flight.StartToCloseTimeout)
+	require.Equal(t, 2*time.Second, flight.RetryPolicy.InitialInterval)
+	require.Equal(t, 2.0, flight.RetryPolicy.BackoffCoefficient)
+	require.Equal(t, int32(5), flight.RetryPolicy.MaximumAttempts)
+	require.Equal(t, []string{"NotAvailableError"}, flight.RetryPolicy.NonRetryableErrorTypes)
+
+	// The base retry policy is copied, not modified
+	require.Equal(t, int32(3), base.RetryPolicy.MaximumAttempts)
+
+	require.Equal(t, defaults, cfg.Apply("BookCarActivity", defaults))
+
+	var nilConfig *Config
+	require.Equal(t, base, nilConfig.ApplyDefault(base))
+	require.Equal(t, base, nilConfig.Apply("BookFlightActivity", base))
+}
@@ -0,0 +1,284 @@
+//go:build record
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/worker"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// HistorySetEnv names the directory under testdata/histories that
+// TestRecordHistories writes to
+const HistorySetEnv = "HISTORY_SET"
+
+// recordStubs stand in for every activity the workflow has ever called, by
+// name, so that one recorder serves old and new workflow code. A hotel
+// "DOWN" fails retryably, a flight "FULL" or car "NONE" is not available.
+var recordStubs = map[string]any{
+	"BookHotelActivity": func(_ context.Context, b *types.HotelBooking) (types.BookingConfirmation, error) {
+		if b.HotelID == "DOWN" {
+			return types.BookingConfirmation{}, temporal.NewApplicationError("hotel provider down", string(types.ErrProviderDown))
+		}
+		return types.BookingConfirmation{BookingRef: "HTL-" + b.HotelID, Status: types.StatusConfirmed, Price: b.Price, ConfirmedAt: time.Now()}, nil
+	},
+	"BookFlightActivity": func(_ context.Context, b *types.FlightBooking) (types.BookingConfirmation, error) {
+		if b.FlightNumber == "FULL" {
+			return types.BookingConfirmation{}, temporal.NewNonRetryableApplicationError("no seats", string(types.ErrNotAvailable), nil)
+		}
+		return types.BookingConfirmation{BookingRef: "FLT-" + b.FlightNumber, Status: types.StatusConfirmed, Price: b.Price, ConfirmedAt: time.Now()}, nil
+	},
+	"BookCarActivity": func(_ context.Context, b *types.CarBooking) (types.BookingConfirmation, error) {
+		if b.CarType == "NONE" {
+			return types.BookingConfirmation{}, temporal.NewNonRetryableApplicationError("no cars", string(types.ErrNotAvailable), nil)
+		}
+		return types.BookingConfirmation{BookingRef: "CAR-" + b.CarType, Status: types.StatusConfirmed, Price: b.Price, ConfirmedAt: time.Now()}, nil
+	},
+	"CancelHotelActivity":  func(context.Context, string) error { return nil },
+	"CancelFlightActivity": func(context.Context, string) error { return nil },
+	"CancelCarActivity":    func(context.Context, string) error { return nil },
+	"VerifyHotelActivity":  verifyStub,
+	"VerifyFlightActivity": verifyStub,
+	"VerifyCarActivity":    verifyStub,
+	"HoldHotelActivity": func(_ context.Context, b *types.HotelBooking, _ time.Duration) (types.BookingConfirmation, error) {
+		return types.BookingConfirmation{BookingRef: "HTL-" + b.HotelID, Status: types.StatusHeld, Price: b.Price, ConfirmedAt: time.Now()}, nil
+	},
+	"HoldFlightActivity": func(_ context.Context, b *types.FlightBooking, _ time.Duration) (types.BookingConfirmation, error) {
+		return types.BookingConfirmation{BookingRef: "FLT-" + b.FlightNumber, Status: types.StatusHeld, Price: b.Price, ConfirmedAt: time.Now()}, nil
+	},
+	"HoldCarActivity": func(_ context.Context, b *types.CarBooking, _ time.Duration) (types.BookingConfirmation, error) {
+		return types.BookingConfirmation{BookingRef: "CAR-" + b.CarType, Status: types.StatusHeld, Price: b.Price, ConfirmedAt: time.Now()}, nil
+	},
+	"ConfirmHotelActivity":  confirmStub,
+	"ConfirmFlightActivity": confirmStub,
+	"ConfirmCarActivity":    confirmStub,
+	"ReleaseHotelActivity":  func(context.Context, string) error { return nil },
+	"ReleaseFlightActivity": func(context.Context, string) error { return nil },
+	"ReleaseCarActivity":    func(context.Context, string) error { return nil },
+	"AuthorizePaymentActivity": func(_ context.Context, _ string, amount float64) (types.BookingConfirmation, error) {
+		return types.BookingConfirmation{BookingRef: "AUTH-1", Status: "AUTHORIZED", Price: amount, ConfirmedAt: time.Now()}, nil
+	},
+	"CapturePaymentActivity": func(_ context.Context, _, _ string, amount float64) (types.BookingConfirmation, error) {
+		return types.BookingConfirmation{BookingRef: "CAP-1", Status: "CAPTURED", Price: amount, ConfirmedAt: time.Now()}, nil
+	},
+	"VoidPaymentActivity":      func(context.Context, string, string) error { return nil },
+	"RefundPaymentActivity":    func(context.Context, string, string, float64) error { return nil },
+	"SendNotificationActivity": func(context.Context, map[string]any) error { return nil },
+	"SendEmailActivity":        func(context.Context, string, string, string) error { return nil },
+}
+
+func verifyStub(_ context.Context, bookingRef string) (types.BookingConfirmation, error) {
+	return types.BookingConfirmation{BookingRef: bookingRef, Status: types.StatusConfirmed}, nil
+}
+
+func confirmStub(_ context.Context, holdRef string) (types.BookingConfirmation, error) {
+	return types.BookingConfirmation{BookingRef: holdRef, Status: types.StatusConfirmed, ConfirmedAt: time.Now()}, nil
+}
+
+// recording is one workflow run to record, once wait returns and, unless
+// inFlight, the run has closed
+type recording struct {
+	name     string
+	booking  func(now time.Time) types.TravelBooking
+	wait     func(t *testing.T, r *recorder, id string)
+	inFlight bool
+}
+
+func recordBooking(now time.Time, start, end time.Duration) types.TravelBooking {
+	return types.TravelBooking{
+		BookingID:     "REPLAY",
+		UserID:        "user-1",
+		StartDate:     now.Add(start),
+		EndDate:       now.Add(end),
+		TotalAmount:   1000,
+		HotelBooking:  &types.HotelBooking{HotelID: "H1", RoomType: "double", Price: 500},
+		FlightBooking: &types.FlightBooking{FlightNumber: "F1", SeatClass: "economy", Price: 400},
+		CarBooking:    &types.CarBooking{CarType: "compact", Price: 100},
+	}
+}
+
+var recordings = []recording{
+	{name: "confirmed", booking: func(now time.Time) types.TravelBooking {
+		return recordBooking(now, 2*time.Second, 10*time.Second)
+	}},
+	{name: "parallel", booking: func(now time.Time) types.TravelBooking {
+		b := recordBooking(now, 2*time.Second, 10*time.Second)
+		b.Mode = types.ModeParallel
+		return b
+	}},
+	{name: "flight-unavailable", booking: func(now time.Time) types.TravelBooking {
+		b := recordBooking(now, 2*time.Second, 10*time.Second)
+		b.FlightBooking.FlightNumber = "FULL"
+		return b
+	}},
+	{name: "car-rejected", booking: func(now time.Time) types.TravelBooking {
+		b := recordBooking(now, 2*time.Second, 10*time.Second)
+		b.CarBooking.CarType = "NONE"
+		return b
+	}, wait: signalWhenCarFails(SignalRejectPartialBooking)},
+	{name: "car-approved", booking: func(now time.Time) types.TravelBooking {
+		b := recordBooking(now, 2*time.Second, 15*time.Second)
+		b.CarBooking.CarType = "NONE"
+		return b
+	}, wait: signalWhenCarFails(SignalApprovePartialBooking)},
+	{name: "hotel-retrying", booking: func(now time.Time) types.TravelBooking {
+		b := recordBooking(now, 30*24*time.Hour, 37*24*time.Hour)
+		b.HotelBooking.HotelID = "DOWN"
+		return b
+	}, wait: func(t *testing.T, r *recorder, id string) {
+		r.waitFor(t, id, func(b types.TravelBooking) bool { return true }, enums.EVENT_TYPE_TIMER_STARTED)
+	}, inFlight: true},
+	{name: "provider-cancelled", booking: func(now time.Time) types.TravelBooking {
+		return recordBooking(now, 30*24*time.Hour, 37*24*time.Hour)
+	}, wait: func(t *testing.T, r *recorder, id string) {
+		r.waitFor(t, id, func(b types.TravelBooking) bool { return b.Status == types.StatusConfirmed }, enums.EVENT_TYPE_TIMER_STARTED)
+		require.NoError(t, r.client.SignalWorkflow(context.Background(), id, "", SignalProviderCancellation,
+			types.ProviderCancellation{Component: types.ComponentHotel, BookingRef: "HTL-H1", Reason: "hotel closed"}))
+	}},
+	{name: "awaiting-trip", booking: func(now time.Time) types.TravelBooking {
+		// The pre-trip verification, where there is one, is a few seconds away
+		return recordBooking(now, 48*time.Hour+5*time.Second, 72*time.Hour)
+	}, wait: func(t *testing.T, r *recorder, id string) {
+		r.waitFor(t, id, func(b types.TravelBooking) bool { return b.Status == types.StatusConfirmed }, enums.EVENT_TYPE_TIMER_STARTED)
+		time.Sleep(10 * time.Second)
+	}, inFlight: true},
+	{name: "tcc", booking: func(now time.Time) types.TravelBooking {
+		b := recordBooking(now, 2*time.Second, 10*time.Second)
+		b.Mode = types.ModeTCC
+		return b
+	}},
+	{name: "multi-leg", booking: func(now time.Time) types.TravelBooking {
+		b := recordBooking(now, 2*time.Second, 10*time.Second)
+		b.HotelBooking, b.FlightBooking = nil, nil
+		b.Hotels = []*types.HotelBooking{
+			{HotelID: "H1", RoomType: "double", Price: 300},
+			{HotelID: "H2", RoomType: "single", Price: 200},
+		}
+		b.Flights = []*types.FlightBooking{
+			{FlightNumber: "F1", SeatClass: "economy", Price: 200},
+			{FlightNumber: "F2", SeatClass: "economy", Price: 200},
+		}
+		return b
+	}},
+	{name: "cancel-trip", booking: func(now time.Time) types.TravelBooking {
+		return recordBooking(now, 30*24*time.Hour, 37*24*time.Hour)
+	}, wait: func(t *testing.T, r *recorder, id string) {
+		r.waitFor(t, id, func(b types.TravelBooking) bool { return b.Status == types.StatusConfirmed }, enums.EVENT_TYPE_TIMER_STARTED)
+		handle, err := r.client.UpdateWorkflow(context.Background(), id, "", UpdateCancelTrip, types.TripCancellation{Reason: "plans changed"})
+		require.NoError(t, err)
+		require.NoError(t, handle.Get(context.Background(), nil))
+	}},
+}
+
+func signalWhenCarFails(signal string) func(t *testing.T, r *recorder, id string) {
+	return func(t *testing.T, r *recorder, id string) {
+		r.waitFor(t, id, func(b types.TravelBooking) bool { return b.CarBooking.Status == types.StatusFailed }, enums.EVENT_TYPE_TIMER_STARTED)
+		require.NoError(t, r.client.SignalWorkflow(context.Background(), id, "", signal, nil))
+	}
+}
+
+type recorder struct {
+	client client.Client
+}
+
+// waitFor waits until the booking status matches and the history of id has
+// an event of type want
+func (r *recorder) waitFor(t *testing.T, id string, match func(types.TravelBooking) bool, want enums.EventType) {
+	require.Eventually(t, func() bool {
+		value, err := r.client.QueryWorkflow(context.Background(), id, "", QueryBookingStatus)
+		if err != nil {
+			return false
+		}
+		var booking types.TravelBooking
+		if value.Get(&booking) != nil || !match(booking) {
+			return false
+		}
+		for _, event := range r.history(t, id).Events {
+			if event.EventType == want {
+				return true
+			}
+		}
+		return false
+	}, time.Minute, 200*time.Millisecond)
+}
+
+func (r *recorder) history(t *testing.T, id string) *historypb.History {
+	var history historypb.History
+	iter := r.client.GetWorkflowHistory(context.Background(), id, "", false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		require.NoError(t, err)
+		history.Events = append(history.Events, event)
+	}
+	return &history
+}
+
+// TestRecordHistories runs each recording against a Temporal server with
+// stubbed activities and writes its history to
+// testdata/histories/$HISTORY_SET/<name>.json for TestReplayHistories:
+//
+//	temporal server start-dev
+//	HISTORY_SET=v4 go test -tags record -run TestRecordHistories .
+//
+// Record a new set before deploying a change that needs a new version, so
+// the runs it must stay compatible with are kept.
+func TestRecordHistories(t *testing.T) {
+	set := os.Getenv(HistorySetEnv)
+	require.NotEmpty(t, set, HistorySetEnv+" must name the history set")
+	dir := filepath.Join("testdata", "histories", set)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+
+	c, err := client.Dial(client.Options{})
+	require.NoError(t, err)
+	defer c.Close()
+	r := &recorder{client: c}
+
+	taskQueue := "record-" + set
+	w := worker.New(c, taskQueue, worker.Options{})
+	w.RegisterWorkflow(TravelBookingWorkflow)
+	for name, stub := range recordStubs {
+		w.RegisterActivityWithOptions(stub, activity.RegisterOptions{Name: name})
+	}
+	require.NoError(t, w.Start())
+	defer w.Stop()
+
+	for _, rec := range recordings {
+		t.Run(rec.name, func(t *testing.T) {
+			id := BookingWorkflowID(fmt.Sprintf("%s-%s-%d", set, rec.name, time.Now().Unix()))
+			run, err := c.ExecuteWorkflow(context.Background(), client.StartWorkflowOptions{
+				ID:        id,
+				TaskQueue: taskQueue,
+			}, TravelBookingWorkflow, rec.booking(time.Now()))
+			require.NoError(t, err)
+
+			if rec.wait != nil {
+				rec.wait(t, r, id)
+			}
+			if !rec.inFlight {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				_ = run.Get(ctx, nil)
+			}
+
+			f, err := os.Create(filepath.Join(dir, rec.name+".json"))
+			require.NoError(t, err)
+			defer f.Close()
+			m := jsonpb.Marshaler{Indent: "  "}
+			require.NoError(t, m.Marshal(f, r.history(t, id)))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/worker"
+)
+
+// TestReplayHistories replays every history recorded from earlier versions of
+// TravelBookingWorkflow against the current code. A change that issues
+// different commands for a history without guarding it with
+// workflow.GetVersion fails here; see versions.go and record_test.go.
+func TestReplayHistories(t *testing.T) {
+	histories, err := filepath.Glob(filepath.Join("testdata", "histories", "*", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, histories)
+
+	for _, path := range histories {
+		name := strings.TrimSuffix(filepath.ToSlash(strings.TrimPrefix(path, filepath.Join("testdata", "histories")+string(filepath.Separator))), ".json")
+		t.Run(name, func(t *testing.T) {
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflow(TravelBookingWorkflow)
+			require.NoError(t, replayer.ReplayWorkflowHistoryFromJSONFile(log.NewStructuredLogger(discardLogger), path))
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package saga records compensations for workflow steps as they succeed and
+// runs them in reverse order when the workflow needs to unwind.
+package saga
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// Compensation undoes a completed step
+type Compensation func(ctx workflow.Context) error
+
+// Options controls how compensations are run
+type Options struct {
+	// Parallel runs every compensation at once instead of one at a time
+	Parallel bool
+}
+
+type step struct {
+	component    string
+	compensation Compensation
+}
+
+// Saga is a stack of compensations. It is not safe to share between
+// workflow coroutines without external coordination.
+type Saga struct {
+	options Options
+	steps   []step
+}
+
+// New returns an empty saga
+func New(options Options) *Saga {
+	return &Saga{options: options}
+}
+
+// AddCompensation records how to undo the step that just completed for
+// component. Compensations run in the reverse order they were added.
+func (s *Saga) AddCompensation(component string, compensation Compensation) {
+	s.steps = append(s.steps, step{component: component, compensation: compensation})
+}
+
+// Remove drops the compensations recorded for component, for steps that have
+// already been undone some other way
+func (s *Saga) Remove(component string) {
+	s.steps = slices.DeleteFunc(s.steps, func(st step) bool {
+		return st.component == component
+	})
+}
+
+// Clear drops every recorded compensation without running it
+func (s *Saga) Clear() {
+	s.steps = nil
+}
+
+// Len returns the number of compensations waiting to run
+func (s *Saga) Len() int {
+	return len(s.steps)
+}
+
+// Compensate runs the recorded compensations, newest first. Every
+// compensation is attempted even if an earlier one fails; each failure is
+// returned as a types.BookingError in the order it was run, with RetryCount
+// set to the number of attempts the failing activity recorded. Compensations
+// that fail stay in the saga so a later Compensate retries only those.
+func (s *Saga) Compensate(ctx workflow.Context) []types.BookingError {
+	steps := slices.Clone(s.steps)
+	slices.Reverse(steps)
+	s.steps = nil
+
+	errs := make([]error, len(steps))
+	if s.options.Parallel {
+		wg := workflow.NewWaitGroup(ctx)
+		for i, st := range steps {
+			wg.Add(1)
+			workflow.Go(ctx, func(ctx workflow.Context) {
+				defer wg.Done()
+				errs[i] = st.compensation(ctx)
+			})
+		}
+		wg.Wait(ctx)
+	} else {
+		for i, st := range steps {
+			errs[i] = st.compensation(ctx)
+		}
+	}
+
+	var failures []types.BookingError
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		// Keep the original, oldest first, order for the next attempt
+		s.steps = slices.Insert(s.steps, 0, steps[i])
+		workflow.GetLogger(ctx).Error("Compensation failed",
+			slog.String("component", steps[i].component),
+			slog.String("error", err.Error()))
+		failures = append(failures, types.BookingError{
+			Component:  steps[i].component,
+			Message:    err.Error(),
+			RetryCount: attempts(err),
+		})
+	}
+	return failures
+}
+
+// attempts returns how many times the activity behind err was tried, as
+// the activity recorded it in the types.BookingError it failed with. It is
+// zero when the activity recorded none, such as when every attempt timed out.
+func attempts(err error) int {
+	var appErr *temporal.ApplicationError
+	var failure types.BookingError
+	if !errors.As(err, &appErr) || !appErr.HasDetails() || appErr.Details(&failure) != nil {
+		return 0
+	}
+	return failure.RetryCount
+}
@@ -0,0 +1,163 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+func UndoActivity(ctx context.Context, component string) error {
+	return nil
+}
+
+// undo returns the compensation running UndoActivity for component
+func undo(component string) Compensation {
+	return func(ctx workflow.Context) error {
+		return workflow.ExecuteActivity(ctx, UndoActivity, component).Get(ctx, nil)
+	}
+}
+
+// sagaWorkflow records an UndoActivity compensation for each component, drops
+// the removed ones and then unwinds
+func sagaWorkflow(ctx workflow.Context, options Options, components []string, removed []string) ([]types.BookingError, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 1},
+	})
+
+	s := New(options)
+	for _, component := range components {
+		s.AddCompensation(component, undo(component))
+	}
+	for _, component := range removed {
+		s.Remove(component)
+	}
+	failures := s.Compensate(ctx)
+	if s.Len() != len(failures) {
+		return nil, fmt.Errorf("saga kept %d compensations for %d failures", s.Len(), len(failures))
+	}
+	return failures, nil
+}
+
+// retryWorkflow compensates hotel, flight and car, then retries whatever failed
+func retryWorkflow(ctx workflow.Context) ([]types.BookingError, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 1},
+	})
+
+	s := New(Options{})
+	for _, component := range []string{"hotel", "flight", "car"} {
+		s.AddCompensation(component, undo(component))
+	}
+	if failures := s.Compensate(ctx); len(failures) != 2 {
+		return nil, fmt.Errorf("expected 2 failures on the first pass, got %d", len(failures))
+	}
+	failures := s.Compensate(ctx)
+	s.Clear()
+	return failures, nil
+}
+
+func runSaga(t *testing.T, options Options, components, removed []string, failing ...string) ([]string, []types.BookingError) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(sagaWorkflow)
+
+	var order []string
+	for _, component := range components {
+		var err error
+		for _, f := range failing {
+			if f == component {
+				err = fmt.Errorf("cannot undo %s", component)
+			}
+		}
+		env.OnActivity(UndoActivity, mock.Anything, component).Return(err).Run(func(args mock.Arguments) {
+			order = append(order, args.String(1))
+		}).Maybe()
+	}
+
+	env.ExecuteWorkflow(sagaWorkflow, options, components, removed)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	var failures []types.BookingError
+	require.NoError(t, env.GetWorkflowResult(&failures))
+	return order, failures
+}
+
+func TestSaga_CompensatesInReverseOrder(t *testing.T) {
+	order, failures := runSaga(t, Options{}, []string{"hotel", "flight", "car"}, nil)
+
+	require.Equal(t, []string{"car", "flight", "hotel"}, order)
+	require.Empty(t, failures)
+}
+
+func TestSaga_CollectsFailuresAndContinues(t *testing.T) {
+	order, failures := runSaga(t, Options{}, []string{"hotel", "flight", "car"}, nil, "flight", "hotel")
+
+	require.Equal(t, []string{"car", "flight", "hotel"}, order)
+	require.Len(t, failures, 2)
+	require.Equal(t, "flight", failures[0].Component)
+	require.Equal(t, "hotel", failures[1].Component)
+	require.Contains(t, failures[0].Message, "cannot undo flight")
+}
+
+func TestSaga_Parallel(t *testing.T) {
+	order, failures := runSaga(t, Options{Parallel: true}, []string{"hotel", "flight", "car"}, nil, "hotel")
+
+	require.ElementsMatch(t, []string{"car", "flight", "hotel"}, order)
+	require.Len(t, failures, 1)
+	require.Equal(t, "hotel", failures[0].Component)
+}
+
+func TestSaga_Remove(t *testing.T) {
+	order, failures := runSaga(t, Options{}, []string{"hotel", "flight", "car"}, []string{"flight"})
+
+	require.Equal(t, []string{"car", "hotel"}, order)
+	require.Empty(t, failures)
+}
+
+func TestSaga_Empty(t *testing.T) {
+	order, failures := runSaga(t, Options{Parallel: true}, nil, nil)
+
+	require.Empty(t, order)
+	require.Empty(t, failures)
+}
+
+func TestSaga_RetriesOnlyFailedCompensations(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(retryWorkflow)
+
+	var order []string
+	record := func(args mock.Arguments) {
+		order = append(order, args.String(1))
+	}
+	env.OnActivity(UndoActivity, mock.Anything, "car").Return(nil).Run(record).Once()
+	env.OnActivity(UndoActivity, mock.Anything, "flight").Return(fmt.Errorf("cannot undo flight")).Run(record).Once()
+	env.OnActivity(UndoActivity, mock.Anything, "flight").Return(nil).Run(record).Once()
+	// The activity records the attempt it failed on
+	env.OnActivity(UndoActivity, mock.Anything, "hotel").Return(temporal.NewApplicationError("cannot undo hotel",
+		string(types.ErrProviderDown), types.BookingError{Component: "hotel", RetryCount: 1})).Run(record).Twice()
+
+	env.ExecuteWorkflow(retryWorkflow)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	var failures []types.BookingError
+	require.NoError(t, env.GetWorkflowResult(&failures))
+	require.Len(t, failures, 1)
+	require.Equal(t, "hotel", failures[0].Component)
+	require.Equal(t, 1, failures[0].RetryCount)
+	require.Equal(t, []string{"car", "flight", "hotel", "flight", "hotel"}, order)
+	env.AssertExpectations(t)
+}
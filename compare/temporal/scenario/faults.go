@@ -0,0 +1,146 @@
+package scenario
+
+import (
+	"container/list"
+	"math/rand/v2"
+	"sync"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// Faults injects a script's faults into provider calls. Every workflow run
+// replays the script on its own: attempts are counted per run, provider and
+// operation from the run's first call, and random faults draw from a
+// generator seeded by the script for that run, so the same calls in the same
+// order fail the same way on every run. A nil *Faults injects nothing.
+//
+// Activities cannot tell when a run closes, so only the MaxRuns most recently
+// used runs are kept; a run forgotten while still going replays the script
+// from the beginning again.
+type Faults struct {
+	script *Script
+
+	mu   sync.Mutex
+	runs map[string]*list.Element
+	// lru holds every run in runs, most recently used first
+	lru *list.List
+}
+
+// MaxRuns is how many runs Faults keeps track of
+const MaxRuns = 10000
+
+// faultRun is how far one run has got through the script
+type faultRun struct {
+	id    string
+	rng   *rand.Rand
+	calls map[string]int
+}
+
+// NewFaults returns the faults of script, counting from the first attempt
+func NewFaults(script *Script) *Faults {
+	f := &Faults{script: script}
+	f.Reset()
+	return f
+}
+
+// Script returns the script the faults come from
+func (f *Faults) Script() *Script {
+	if f == nil {
+		return nil
+	}
+	return f.script
+}
+
+// Reset forgets every run, so runs already seen replay the script from the
+// beginning again
+func (f *Faults) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.runs = make(map[string]*list.Element)
+	f.lru = list.New()
+}
+
+// run returns the progress of run through the script, forgetting the least
+// recently used run once there are more than MaxRuns; f.mu must be held
+func (f *Faults) run(run string) *faultRun {
+	if e, ok := f.runs[run]; ok {
+		f.lru.MoveToFront(e)
+		return e.Value.(*faultRun)
+	}
+
+	r := &faultRun{
+		id:    run,
+		rng:   rand.New(rand.NewPCG(f.script.Seed, f.script.Seed)),
+		calls: make(map[string]int),
+	}
+	f.runs[run] = f.lru.PushFront(r)
+	if f.lru.Len() > MaxRuns {
+		oldest := f.lru.Remove(f.lru.Back()).(*faultRun)
+		delete(f.runs, oldest.id)
+	}
+	return r
+}
+
+// Attempts returns how many calls run has made to the provider operation
+func (f *Faults) Attempts(run, provider, operation string) int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.runs[run]
+	if !ok {
+		return 0
+	}
+	return e.Value.(*faultRun).calls[provider+"/"+operation]
+}
+
+// Check counts a call run makes to the provider operation and returns the
+// types.BookingError the script injects into it, or nil if the call should go
+// ahead. run identifies the workflow run, such as its workflow and run ID.
+func (f *Faults) Check(run, provider, operation string) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := f.run(run)
+	r.calls[provider+"/"+operation]++
+	attempt := r.calls[provider+"/"+operation]
+
+	for _, fault := range f.script.Faults {
+		if fault.Provider != provider || fault.operation() != operation {
+			continue
+		}
+
+		var fail bool
+		switch {
+		case fault.Always:
+			fail = true
+		case len(fault.Attempts) > 0:
+			fail = fault.Attempts.Contains(attempt)
+		case fault.ErrorRate > 0:
+			fail = r.rng.Float64() < fault.ErrorRate
+		}
+		if fail {
+			return types.NewBookingError(provider, fault.kind(),
+				"%s %s attempt %d failed by scenario %q", provider, operation, attempt, f.script.Name)
+		}
+	}
+	return nil
+}
+
+func (f Fault) operation() string {
+	if f.Operation == "" {
+		return OperationBook
+	}
+	return f.Operation
+}
+
+func (f Fault) kind() types.ErrorKind {
+	if f.Kind == "" {
+		return types.ErrProviderDown
+	}
+	return f.Kind
+}
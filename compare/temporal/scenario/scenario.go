@@ -0,0 +1,334 @@
+// Package scenario loads scripts that replay a booking run on demand: which
+// provider calls fail and how, plus the user and provider events that arrive
+// while the workflow runs. The Temporal and Restate activities share the
+// faults, so the same script drives either implementation.
+//
+// A script is JSON or YAML. Faults are matched by provider and operation and
+// fail on given attempts, on every attempt or at a seeded random rate:
+//
+//	name: car-rejected
+//	seed: 7
+//	faults:
+//	  - provider: hotel
+//	    attempts: 1-3
+//	    kind: ProviderDownError
+//	  - provider: car
+//	    always: true
+//	    kind: NotAvailableError
+//	events:
+//	  - after: 1h
+//	    signal: reject-partial-booking
+//	expect:
+//	  status: FAILED
+//	  error: true
+//
+// The scripts for every SCENARIO.md flow are embedded; see Scripts.
+package scenario
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/leowmjw/go-durable-x/temporal/duration"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// FileEnv names the environment variable pointing at a scenario script
+const FileEnv = "SCENARIO_FILE"
+
+// SeedEnv names the environment variable holding the seed for random faults.
+// On its own it turns on DefaultFaults; with FileEnv it overrides the
+// script's seed.
+const SeedEnv = "FAULT_SEED"
+
+// Operations a fault can target
+const (
+	OperationBook   = "book"
+	OperationCancel = "cancel"
+	// Operations of ModeTCC bookings
+	OperationHold    = "hold"
+	OperationConfirm = "confirm"
+	OperationRelease = "release"
+)
+
+// providers are the components faults can be injected into
+var providers = []string{types.ComponentHotel, types.ComponentFlight, types.ComponentCar}
+
+// Attempts is a set of 1-based attempt numbers written as "2", "1-3" or
+// "1-2,5"
+type Attempts []AttemptRange
+
+// AttemptRange is an inclusive range of attempt numbers
+type AttemptRange struct {
+	From, To int
+}
+
+// Contains reports whether attempt is in the set
+func (a Attempts) Contains(attempt int) bool {
+	return slices.ContainsFunc(a, func(r AttemptRange) bool {
+		return attempt >= r.From && attempt <= r.To
+	})
+}
+
+func (a *Attempts) set(s string) error {
+	var parsed Attempts
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		r, err := parseAttemptRange(from, to, isRange)
+		if err != nil {
+			return fmt.Errorf("attempts %q: %w", s, err)
+		}
+		parsed = append(parsed, r)
+	}
+	*a = parsed
+	return nil
+}
+
+func parseAttemptRange(from, to string, isRange bool) (AttemptRange, error) {
+	var r AttemptRange
+	var err error
+	if r.From, err = strconv.Atoi(strings.TrimSpace(from)); err != nil {
+		return r, err
+	}
+	r.To = r.From
+	if isRange {
+		if r.To, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return r, err
+		}
+	}
+	if r.From < 1 || r.To < r.From {
+		return r, fmt.Errorf("%d-%d is not a range of attempts starting at 1", r.From, r.To)
+	}
+	return r, nil
+}
+
+func (a *Attempts) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("attempts must be a string like \"1-3\": %w", err)
+	}
+	return a.set(s)
+}
+
+func (a *Attempts) UnmarshalYAML(value *yaml.Node) error {
+	return a.set(value.Value)
+}
+
+// Fault makes calls to one provider operation fail. Exactly one of Attempts,
+// Always and ErrorRate says when.
+type Fault struct {
+	Provider  string          `json:"provider" yaml:"provider"`
+	Operation string          `json:"operation" yaml:"operation"` // book (default), cancel, hold, confirm or release
+	Attempts  Attempts        `json:"attempts" yaml:"attempts"`
+	Always    bool            `json:"always" yaml:"always"`
+	ErrorRate float64         `json:"error_rate" yaml:"error_rate"`
+	Kind      types.ErrorKind `json:"kind" yaml:"kind"` // defaults to ProviderDownError
+}
+
+// Event is something the user or a provider does while the workflow runs,
+// delivered as a workflow signal After the run starts
+type Event struct {
+	After     duration.Duration `json:"after" yaml:"after"`
+	Signal    string            `json:"signal" yaml:"signal"`
+	Component string            `json:"component" yaml:"component"` // for provider cancellations
+	Reason    string            `json:"reason" yaml:"reason"`
+}
+
+// Expect is how the run should end
+type Expect struct {
+	Status types.BookingStatus `json:"status" yaml:"status"`
+	Error  bool                `json:"error" yaml:"error"`
+}
+
+// Script is a scenario file
+type Script struct {
+	Name        string            `json:"name" yaml:"name"`
+	Description string            `json:"description" yaml:"description"`
+	Seed        uint64            `json:"seed" yaml:"seed"`
+	StartsIn    duration.Duration `json:"starts_in" yaml:"starts_in"` // how far ahead the trip starts
+	Faults      []Fault           `json:"faults" yaml:"faults"`
+	Events      []Event           `json:"events" yaml:"events"`
+	Expect      Expect            `json:"expect" yaml:"expect"`
+}
+
+// Load reads a scenario script, picking the format from its extension
+// (.yaml, .yml or .json), and validates it
+func Load(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario file: %w", err)
+	}
+	s, err := parse(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("scenario file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func parse(name string, data []byte) (*Script, error) {
+	var s *Script
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".yaml", ".yml":
+		s, err = ParseYAML(data)
+	case ".json":
+		s, err = ParseJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported extension %q", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseJSON decodes a scenario script, rejecting unknown fields
+func ParseJSON(data []byte) (*Script, error) {
+	var s Script
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ParseYAML decodes a scenario script, rejecting unknown fields
+func ParseYAML(data []byte) (*Script, error) {
+	var s Script
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks every fault and event is usable
+func (s *Script) Validate() error {
+	var errs []error
+	if s.StartsIn < 0 {
+		errs = append(errs, errors.New("starts_in must not be negative"))
+	}
+	for i, f := range s.Faults {
+		errs = append(errs, f.validate(fmt.Sprintf("faults[%d]", i)))
+	}
+	for i, e := range s.Events {
+		if e.After < 0 {
+			errs = append(errs, fmt.Errorf("events[%d]: after must not be negative", i))
+		}
+		if e.Signal == "" {
+			errs = append(errs, fmt.Errorf("events[%d]: missing signal", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f Fault) validate(path string) error {
+	var errs []error
+	if !slices.Contains(providers, f.Provider) {
+		errs = append(errs, fmt.Errorf("%s: unknown provider %q", path, f.Provider))
+	}
+	switch f.Operation {
+	case "", OperationBook, OperationCancel, OperationHold, OperationConfirm, OperationRelease:
+	default:
+		errs = append(errs, fmt.Errorf("%s: unknown operation %q", path, f.Operation))
+	}
+	switch f.Kind {
+	case "", types.ErrNotAvailable, types.ErrInvalidRequest, types.ErrProviderDown, types.ErrRateLimited:
+	default:
+		errs = append(errs, fmt.Errorf("%s: unknown error kind %q", path, f.Kind))
+	}
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		errs = append(errs, fmt.Errorf("%s: error_rate must be between 0 and 1", path))
+	}
+	triggers := 0
+	for _, set := range []bool{len(f.Attempts) > 0, f.Always, f.ErrorRate > 0} {
+		if set {
+			triggers++
+		}
+	}
+	if triggers != 1 {
+		errs = append(errs, fmt.Errorf("%s: set exactly one of attempts, always or error_rate", path))
+	}
+	return errors.Join(errs...)
+}
+
+// DefaultFaults fail one in five bookings, as the fake providers used to do
+// at random: the hotel looks down while flights and cars are sold out
+func DefaultFaults() []Fault {
+	return []Fault{
+		{Provider: types.ComponentHotel, ErrorRate: 0.2, Kind: types.ErrProviderDown},
+		{Provider: types.ComponentFlight, ErrorRate: 0.2, Kind: types.ErrNotAvailable},
+		{Provider: types.ComponentCar, ErrorRate: 0.2, Kind: types.ErrNotAvailable},
+	}
+}
+
+// FromEnv returns the faults configured by FileEnv and SeedEnv, or nil if
+// neither is set
+func FromEnv() (*Faults, error) {
+	var s *Script
+	if path := os.Getenv(FileEnv); path != "" {
+		loaded, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		s = loaded
+	}
+
+	if seed := os.Getenv(SeedEnv); seed != "" {
+		parsed, err := strconv.ParseUint(seed, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", SeedEnv, err)
+		}
+		if s == nil {
+			s = &Script{Name: "random", Faults: DefaultFaults()}
+		}
+		s.Seed = parsed
+	}
+
+	if s == nil {
+		return nil, nil
+	}
+	return NewFaults(s), nil
+}
+
+//go:embed scripts
+var scripts embed.FS
+
+// Scripts returns the embedded scripts, one per SCENARIO.md flow, by name
+func Scripts() (map[string]*Script, error) {
+	entries, err := fs.ReadDir(scripts, "scripts")
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*Script, len(entries))
+	for _, entry := range entries {
+		name := path.Join("scripts", entry.Name())
+		data, err := scripts.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		s, err := parse(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		byName[s.Name] = s
+	}
+	return byName, nil
+}
@@ -0,0 +1,192 @@
+package scenario
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// requireKind checks err is a booking error of kind
+func requireKind(t *testing.T, err error, kind types.ErrorKind) {
+	t.Helper()
+	var failure types.BookingError
+	require.ErrorAs(t, err, &failure)
+	require.Equal(t, kind, failure.Kind, failure.Message)
+}
+
+func TestParseYAML(t *testing.T) {
+	s, err := ParseYAML([]byte(`
+name: example
+seed: 7
+starts_in: 72h
+faults:
+  - provider: hotel
+    attempts: 1-3,5
+  - provider: car
+    operation: cancel
+    always: true
+    kind: NotAvailableError
+events:
+  - after: 24h
+    signal: provider-cancellation
+    component: flight
+`))
+	require.NoError(t, err)
+	require.NoError(t, s.Validate())
+	require.Equal(t, uint64(7), s.Seed)
+	require.Equal(t, Attempts{{From: 1, To: 3}, {From: 5, To: 5}}, s.Faults[0].Attempts)
+	require.Equal(t, OperationCancel, s.Faults[1].Operation)
+	require.Equal(t, types.ComponentFlight, s.Events[0].Component)
+}
+
+func TestParseJSON(t *testing.T) {
+	s, err := ParseJSON([]byte(`{"name": "example", "faults": [{"provider": "flight", "error_rate": 0.5}]}`))
+	require.NoError(t, err)
+	require.NoError(t, s.Validate())
+	require.Equal(t, 0.5, s.Faults[0].ErrorRate)
+
+	_, err = ParseJSON([]byte(`{"name": "example", "fault": []}`))
+	require.Error(t, err, "unknown fields are rejected")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "unknown provider", yaml: "faults: [{provider: train, always: true}]", wantErr: `unknown provider "train"`},
+		{name: "unknown operation", yaml: "faults: [{provider: hotel, operation: modify, always: true}]", wantErr: `unknown operation "modify"`},
+		{name: "unknown kind", yaml: "faults: [{provider: hotel, always: true, kind: GremlinError}]", wantErr: `unknown error kind "GremlinError"`},
+		{name: "no trigger", yaml: "faults: [{provider: hotel}]", wantErr: "set exactly one of"},
+		{name: "two triggers", yaml: "faults: [{provider: hotel, always: true, attempts: '1'}]", wantErr: "set exactly one of"},
+		{name: "rate above one", yaml: "faults: [{provider: hotel, error_rate: 2}]", wantErr: "error_rate must be between 0 and 1"},
+		{name: "event without signal", yaml: "events: [{after: 1h}]", wantErr: "missing signal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseYAML([]byte(tt.yaml))
+			require.NoError(t, err)
+			require.ErrorContains(t, s.Validate(), tt.wantErr)
+		})
+	}
+
+	_, err := ParseYAML([]byte("faults: [{provider: hotel, attempts: 3-1}]"))
+	require.ErrorContains(t, err, "not a range of attempts")
+}
+
+func TestFaultsFailScriptedAttempts(t *testing.T) {
+	f := NewFaults(&Script{Name: "example", Faults: []Fault{
+		{Provider: types.ComponentHotel, Attempts: Attempts{{From: 1, To: 3}}},
+		{Provider: types.ComponentCar, Always: true, Kind: types.ErrNotAvailable},
+	}})
+
+	for range 3 {
+		requireKind(t, f.Check("run-1", types.ComponentHotel, OperationBook), types.ErrProviderDown)
+	}
+	require.NoError(t, f.Check("run-1", types.ComponentHotel, OperationBook))
+	require.NoError(t, f.Check("run-1", types.ComponentHotel, OperationCancel), "operations are counted apart")
+	require.Equal(t, 4, f.Attempts("run-1", types.ComponentHotel, OperationBook))
+
+	for range 5 {
+		requireKind(t, f.Check("run-1", types.ComponentCar, OperationBook), types.ErrNotAvailable)
+	}
+	require.NoError(t, f.Check("run-1", types.ComponentFlight, OperationBook))
+
+	// Every run starts the script from its first attempt
+	requireKind(t, f.Check("run-2", types.ComponentHotel, OperationBook), types.ErrProviderDown)
+	require.Equal(t, 1, f.Attempts("run-2", types.ComponentHotel, OperationBook))
+	require.Equal(t, 4, f.Attempts("run-1", types.ComponentHotel, OperationBook))
+
+	f.Reset()
+	requireKind(t, f.Check("run-1", types.ComponentHotel, OperationBook), types.ErrProviderDown)
+}
+
+func TestFaultsForgetLeastRecentlyUsedRuns(t *testing.T) {
+	f := NewFaults(&Script{Name: "example"})
+	require.NoError(t, f.Check("run-0", types.ComponentHotel, OperationBook))
+	require.NoError(t, f.Check("run-1", types.ComponentHotel, OperationBook))
+	for i := 2; i < MaxRuns; i++ {
+		require.NoError(t, f.Check(fmt.Sprintf("run-%d", i), types.ComponentHotel, OperationBook))
+	}
+	// run-0 is used again, so run-1 is the one forgotten
+	require.NoError(t, f.Check("run-0", types.ComponentHotel, OperationBook))
+	require.NoError(t, f.Check("run-new", types.ComponentHotel, OperationBook))
+
+	require.Len(t, f.runs, MaxRuns)
+	require.Equal(t, 2, f.Attempts("run-0", types.ComponentHotel, OperationBook))
+	require.Zero(t, f.Attempts("run-1", types.ComponentHotel, OperationBook))
+	require.Equal(t, 1, f.Attempts("run-new", types.ComponentHotel, OperationBook))
+}
+
+func TestFaultsAreReproducibleFromSeed(t *testing.T) {
+	draw := func(f *Faults, run string) []bool {
+		var failed []bool
+		for range 50 {
+			failed = append(failed, f.Check(run, types.ComponentFlight, OperationBook) != nil)
+		}
+		return failed
+	}
+	faults := func(seed uint64) *Faults {
+		return NewFaults(&Script{Seed: seed, Faults: DefaultFaults()})
+	}
+
+	first := draw(faults(42), "run-1")
+	require.Equal(t, first, draw(faults(42), "run-1"))
+	require.NotEqual(t, first, draw(faults(43), "run-1"))
+	require.Contains(t, first, true)
+	require.Contains(t, first, false)
+
+	// Runs sharing the faults each draw the seeded sequence
+	f := faults(42)
+	require.Equal(t, first, draw(f, "run-1"))
+	require.Equal(t, first, draw(f, "run-2"))
+}
+
+func TestNilFaultsInjectNothing(t *testing.T) {
+	var f *Faults
+	require.NoError(t, f.Check("run-1", types.ComponentHotel, OperationBook))
+	require.Nil(t, f.Script())
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(FileEnv, "")
+	t.Setenv(SeedEnv, "")
+	f, err := FromEnv()
+	require.NoError(t, err)
+	require.Nil(t, f)
+
+	t.Setenv(SeedEnv, "42")
+	f, err = FromEnv()
+	require.NoError(t, err)
+	require.Equal(t, DefaultFaults(), f.Script().Faults)
+	require.Equal(t, uint64(42), f.Script().Seed)
+
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: from-file\nseed: 1\n"), 0o600))
+	t.Setenv(FileEnv, path)
+	f, err = FromEnv()
+	require.NoError(t, err)
+	require.Equal(t, "from-file", f.Script().Name)
+	require.Equal(t, uint64(42), f.Script().Seed, "the seed variable overrides the file")
+
+	t.Setenv(SeedEnv, "forty-two")
+	_, err = FromEnv()
+	require.Error(t, err)
+}
+
+func TestEmbeddedScripts(t *testing.T) {
+	scripts, err := Scripts()
+	require.NoError(t, err)
+	require.Len(t, scripts, 9)
+	for name, s := range scripts {
+		require.NotEmpty(t, s.Description, name)
+		require.NotEmpty(t, s.Expect.Status, name)
+	}
+}
@@ -0,0 +1,151 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// DefaultURL is where the activities expect the simulator unless URLEnv
+// says otherwise
+const DefaultURL = "http://localhost:8082"
+
+// URLEnv names the environment variable holding the simulator's base URL
+const URLEnv = "PROVIDER_SIMULATOR_URL"
+
+// URLFromEnv returns the simulator URL from URLEnv, or DefaultURL
+func URLFromEnv() string {
+	if u := os.Getenv(URLEnv); u != "" {
+		return u
+	}
+	return DefaultURL
+}
+
+// Client calls the simulated providers. Every error it returns is a
+// types.BookingError classified by the provider, or types.ErrProviderDown if
+// the provider could not be reached.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the simulator at baseURL
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	return &Client{baseURL: baseURL, httpClient: httpClient}
+}
+
+// Book books an item with provider. Repeating a call with the same
+// idempotency key returns the original booking.
+func (c *Client) Book(ctx context.Context, provider, idempotencyKey string, req BookingRequest) (Booking, error) {
+	var booking Booking
+	err := c.do(ctx, provider, http.MethodPost, "/bookings", idempotencyKey, req, &booking)
+	return booking, err
+}
+
+// Cancel cancels a booking with provider; cancelling it again is not an
+// error
+func (c *Client) Cancel(ctx context.Context, provider, idempotencyKey, bookingRef string) error {
+	return c.do(ctx, provider, http.MethodDelete, "/bookings/"+url.PathEscape(bookingRef), idempotencyKey, nil, nil)
+}
+
+// Hold sets an item aside with provider until it is confirmed or released,
+// or expires. Repeating a call with the same idempotency key returns the
+// original hold.
+func (c *Client) Hold(ctx context.Context, provider, idempotencyKey string, req HoldRequest) (Booking, error) {
+	var booking Booking
+	err := c.do(ctx, provider, http.MethodPost, "/holds", idempotencyKey, req, &booking)
+	return booking, err
+}
+
+// Confirm turns a hold with provider into a booking. A hold that has expired
+// or been released fails with types.ErrHoldExpired.
+func (c *Client) Confirm(ctx context.Context, provider, idempotencyKey, holdRef string) (Booking, error) {
+	var booking Booking
+	err := c.do(ctx, provider, http.MethodPost, "/holds/"+url.PathEscape(holdRef)+"/confirm", idempotencyKey, nil, &booking)
+	return booking, err
+}
+
+// Release gives a hold back to provider; releasing it again, or after it
+// expired, is not an error
+func (c *Client) Release(ctx context.Context, provider, idempotencyKey, holdRef string) error {
+	return c.do(ctx, provider, http.MethodDelete, "/holds/"+url.PathEscape(holdRef), idempotencyKey, nil, nil)
+}
+
+// Booking looks up a booking with provider
+func (c *Client) Booking(ctx context.Context, provider, bookingRef string) (Booking, error) {
+	var booking Booking
+	err := c.do(ctx, provider, http.MethodGet, "/bookings/"+url.PathEscape(bookingRef), "", nil, &booking)
+	return booking, err
+}
+
+// Inventory returns how many of each item provider has left, for the items
+// whose inventory has changed
+func (c *Client) Inventory(ctx context.Context, provider string) (map[string]int, error) {
+	var inventory map[string]int
+	err := c.do(ctx, provider, http.MethodGet, "/inventory", "", nil, &inventory)
+	return inventory, err
+}
+
+// SetInventory sets how many of item provider has left
+func (c *Client) SetInventory(ctx context.Context, provider, item string, available int) error {
+	return c.do(ctx, provider, http.MethodPut, "/admin/inventory/"+url.PathEscape(item), "", InventoryUpdate{Available: available}, nil)
+}
+
+// SetFault injects fault into provider
+func (c *Client) SetFault(ctx context.Context, provider string, fault Fault) error {
+	return c.do(ctx, provider, http.MethodPut, "/admin/faults", "", fault, nil)
+}
+
+// ClearFault stops injecting faults into provider
+func (c *Client) ClearFault(ctx context.Context, provider string) error {
+	return c.do(ctx, provider, http.MethodDelete, "/admin/faults", "", nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, provider, method, path, idempotencyKey string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return types.NewBookingError(provider, types.ErrInvalidRequest, "encode request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/"+url.PathEscape(provider)+path, body)
+	if err != nil {
+		return types.NewBookingError(provider, types.ErrInvalidRequest, "build request: %v", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return types.NewBookingError(provider, types.ErrProviderDown, "%s unreachable: %v", provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil || failure.Kind == "" {
+			return types.NewBookingError(provider, types.ErrProviderDown, "%s answered %s", provider, resp.Status)
+		}
+		return types.NewBookingError(provider, failure.Kind, "%s", failure.Message)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return types.NewBookingError(provider, types.ErrProviderDown, "decode %s response: %v", provider, err)
+	}
+	return nil
+}
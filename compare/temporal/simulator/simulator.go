@@ -0,0 +1,522 @@
+// Package simulator stands in for the hotel, flight and car providers. Each
+// provider has an HTTP API for booking against a limited inventory, looking
+// up and cancelling bookings, and an admin API to inject latency and errors,
+// so the Temporal and Restate implementations can be run end to end against
+// the same backends.
+//
+// Provider routes, where {provider} is hotel, flight or car:
+//
+//	POST   /{provider}/bookings        book an item; honours Idempotency-Key
+//	GET    /{provider}/bookings/{ref}  look up a booking
+//	DELETE /{provider}/bookings/{ref}  cancel a booking; cancelling twice is fine
+//	GET    /{provider}/inventory       items left, by item
+//
+// Hold routes, for try-confirm-cancel bookings. A hold sets an item aside
+// until it expires, when it is released by itself:
+//
+//	POST   /{provider}/holds                    hold an item; honours Idempotency-Key
+//	POST   /{provider}/holds/{ref}/confirm      turn a hold into a booking; confirming twice is fine
+//	DELETE /{provider}/holds/{ref}              release a hold; releasing twice, or after it expired, is fine
+//
+// Admin routes:
+//
+//	PUT    /{provider}/admin/inventory/{item}  set how many of an item are left
+//	GET    /{provider}/admin/faults            the faults being injected
+//	PUT    /{provider}/admin/faults            inject latency and errors
+//	DELETE /{provider}/admin/faults            stop injecting faults
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+	"github.com/leowmjw/go-durable-x/temporal/web"
+)
+
+// DefaultCapacity is how many of each item a provider has until an admin
+// sets the inventory
+const DefaultCapacity = 10
+
+// IdempotencyKeyHeader carries the key that identifies a repeated request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// BookingRequest asks a provider for one item: a hotel ID, flight number or
+// car type
+type BookingRequest struct {
+	Item  string  `json:"item"`
+	Price float64 `json:"price"`
+}
+
+// HoldRequest asks a provider to set an item aside for HoldFor, a Go
+// duration
+type HoldRequest struct {
+	Item    string  `json:"item"`
+	Price   float64 `json:"price"`
+	HoldFor string  `json:"hold_for"`
+}
+
+// Booking is a provider's record of a booking, or of a hold while its
+// status is types.StatusHeld
+type Booking struct {
+	Ref         string              `json:"ref"`
+	Provider    string              `json:"provider"`
+	Item        string              `json:"item"`
+	Price       float64             `json:"price"`
+	Status      types.BookingStatus `json:"status"`
+	BookedAt    time.Time           `json:"booked_at"`
+	CancelledAt time.Time           `json:"cancelled_at"`
+	// ExpiresAt is when a hold is released unless confirmed
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Confirmation returns the booking as the workflows record it
+func (b Booking) Confirmation() types.BookingConfirmation {
+	confirmation := types.BookingConfirmation{
+		BookingRef:  b.Ref,
+		Status:      b.Status,
+		Price:       b.Price,
+		ConfirmedAt: b.BookedAt,
+	}
+	if b.Status == types.StatusHeld {
+		confirmation.ExpiresAt = b.ExpiresAt
+	}
+	return confirmation
+}
+
+// Fault is injected into every booking, hold and cancellation a provider handles.
+// Latency is added first, then FailNext requests fail, then each request
+// fails with probability ErrorRate. Failures are of ErrorKind, which
+// defaults to types.ErrProviderDown.
+type Fault struct {
+	Latency   string          `json:"latency,omitempty"`
+	ErrorRate float64         `json:"error_rate,omitempty"`
+	ErrorKind types.ErrorKind `json:"error_kind,omitempty"`
+	FailNext  int             `json:"fail_next,omitempty"`
+
+	latency time.Duration
+}
+
+func (f *Fault) validate() error {
+	var errs []error
+	if f.Latency != "" {
+		latency, err := time.ParseDuration(f.Latency)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("latency: %w", err))
+		} else if latency < 0 {
+			errs = append(errs, errors.New("latency must not be negative"))
+		}
+		f.latency = latency
+	}
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		errs = append(errs, errors.New("error_rate must be between 0 and 1"))
+	}
+	if f.FailNext < 0 {
+		errs = append(errs, errors.New("fail_next must not be negative"))
+	}
+	switch f.ErrorKind {
+	case "", types.ErrNotAvailable, types.ErrInvalidRequest, types.ErrProviderDown, types.ErrRateLimited:
+	default:
+		errs = append(errs, fmt.Errorf("unknown error_kind %q", f.ErrorKind))
+	}
+	return errors.Join(errs...)
+}
+
+// ErrorResponse is the body of every failed request
+type ErrorResponse struct {
+	Kind    types.ErrorKind `json:"kind"`
+	Message string          `json:"message"`
+}
+
+// InventoryUpdate sets how many of an item are left
+type InventoryUpdate struct {
+	Available int `json:"available"`
+}
+
+// provider is the state of one simulated provider
+type provider struct {
+	prefix    string
+	inventory map[string]int
+	bookings  map[string]*Booking
+	byKey     map[string]string
+	fault     Fault
+}
+
+func (p *provider) available(item string) int {
+	if n, ok := p.inventory[item]; ok {
+		return n
+	}
+	return DefaultCapacity
+}
+
+// expireHolds releases every hold that expired before now, giving its item
+// back
+func (p *provider) expireHolds(now time.Time) {
+	for _, booking := range p.bookings {
+		if booking.Status == types.StatusHeld && !now.Before(booking.ExpiresAt) {
+			p.release(booking, booking.ExpiresAt)
+		}
+	}
+}
+
+func (p *provider) release(booking *Booking, at time.Time) {
+	booking.Status = types.StatusReleased
+	booking.CancelledAt = at
+	p.inventory[booking.Item] = p.available(booking.Item) + 1
+}
+
+// Server simulates the hotel, flight and car providers
+type Server struct {
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	now       func() time.Time
+	seq       int
+	providers map[string]*provider
+}
+
+// NewServer returns providers with DefaultCapacity of every item and no
+// faults
+func NewServer(logger *slog.Logger) *Server {
+	s := &Server{logger: logger, now: time.Now, providers: make(map[string]*provider)}
+	for name, prefix := range map[string]string{
+		types.ComponentHotel:  "HTL",
+		types.ComponentFlight: "FLT",
+		types.ComponentCar:    "CAR",
+	} {
+		s.providers[name] = &provider{
+			prefix:    prefix,
+			inventory: make(map[string]int),
+			bookings:  make(map[string]*Booking),
+			byKey:     make(map[string]string),
+		}
+	}
+	return s
+}
+
+// Handler returns the provider and admin routes wrapped in request logging
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{provider}/bookings", s.book)
+	mux.HandleFunc("GET /{provider}/bookings/{ref}", s.getBooking)
+	mux.HandleFunc("DELETE /{provider}/bookings/{ref}", s.cancel)
+	mux.HandleFunc("GET /{provider}/inventory", s.getInventory)
+	mux.HandleFunc("POST /{provider}/holds", s.hold)
+	mux.HandleFunc("POST /{provider}/holds/{ref}/confirm", s.confirmHold)
+	mux.HandleFunc("DELETE /{provider}/holds/{ref}", s.releaseHold)
+	mux.HandleFunc("PUT /{provider}/admin/inventory/{item}", s.setInventory)
+	mux.HandleFunc("GET /{provider}/admin/faults", s.getFault)
+	mux.HandleFunc("PUT /{provider}/admin/faults", s.setFault)
+	mux.HandleFunc("DELETE /{provider}/admin/faults", s.clearFault)
+	return web.LogRequests(s.logger, "Provider request", mux)
+}
+
+func (s *Server) book(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	if !s.injectFault(w, r, name) {
+		return
+	}
+
+	var req BookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, types.ErrInvalidRequest, "invalid booking request: %v", err)
+		return
+	}
+	if req.Item == "" || req.Price < 0 {
+		s.writeError(w, http.StatusBadRequest, types.ErrInvalidRequest, "%s booking needs an item and a price", name)
+		return
+	}
+
+	s.take(w, r, name, &Booking{Item: req.Item, Price: req.Price, Status: types.StatusConfirmed})
+}
+
+func (s *Server) hold(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	if !s.injectFault(w, r, name) {
+		return
+	}
+
+	var req HoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, types.ErrInvalidRequest, "invalid hold request: %v", err)
+		return
+	}
+	holdFor, err := time.ParseDuration(req.HoldFor)
+	if req.Item == "" || req.Price < 0 || err != nil || holdFor <= 0 {
+		s.writeError(w, http.StatusBadRequest, types.ErrInvalidRequest, "%s hold needs an item, a price and how long to hold it", name)
+		return
+	}
+
+	s.take(w, r, name, &Booking{Item: req.Item, Price: req.Price, Status: types.StatusHeld, ExpiresAt: s.now().Add(holdFor)})
+}
+
+// take sets aside one of the item of booking, booked or held, unless the
+// request's idempotency key has already taken one
+func (s *Server) take(w http.ResponseWriter, r *http.Request, name string, booking *Booking) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.providers[name]
+	now := s.now()
+	p.expireHolds(now)
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if ref, ok := p.byKey[key]; ok && key != "" {
+		s.writeJSON(w, http.StatusOK, p.bookings[ref])
+		return
+	}
+	if p.available(booking.Item) <= 0 {
+		s.writeError(w, http.StatusConflict, types.ErrNotAvailable, "no %s available for %s", name, booking.Item)
+		return
+	}
+
+	s.seq++
+	booking.Ref = fmt.Sprintf("%s-%d", p.prefix, s.seq)
+	booking.Provider = name
+	if booking.Status == types.StatusConfirmed {
+		booking.BookedAt = now
+	}
+	p.inventory[booking.Item] = p.available(booking.Item) - 1
+	p.bookings[booking.Ref] = booking
+	if key != "" {
+		p.byKey[key] = booking.Ref
+	}
+	s.writeJSON(w, http.StatusCreated, booking)
+}
+
+func (s *Server) confirmHold(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	if !s.injectFault(w, r, name) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.providers[name]
+	now := s.now()
+	p.expireHolds(now)
+	booking, ok := p.bookings[r.PathValue("ref")]
+	switch {
+	case !ok:
+		s.writeError(w, http.StatusNotFound, types.ErrInvalidRequest, "unknown %s hold %q", name, r.PathValue("ref"))
+		return
+	case booking.Status == types.StatusReleased:
+		s.writeError(w, http.StatusConflict, types.ErrHoldExpired, "%s hold %s was released at %s", name, booking.Ref, booking.CancelledAt.Format(time.RFC3339))
+		return
+	case booking.Status == types.StatusHeld:
+		booking.Status = types.StatusConfirmed
+		booking.BookedAt = now
+	}
+	s.writeJSON(w, http.StatusOK, booking)
+}
+
+func (s *Server) releaseHold(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	if !s.injectFault(w, r, name) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.providers[name]
+	now := s.now()
+	p.expireHolds(now)
+	booking, ok := p.bookings[r.PathValue("ref")]
+	switch {
+	case !ok:
+		s.writeError(w, http.StatusNotFound, types.ErrInvalidRequest, "unknown %s hold %q", name, r.PathValue("ref"))
+		return
+	case booking.Status == types.StatusHeld:
+		p.release(booking, now)
+	case booking.Status != types.StatusReleased:
+		s.writeError(w, http.StatusConflict, types.ErrInvalidRequest, "%s %s is %s; only holds can be released", name, booking.Ref, booking.Status)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, booking)
+}
+
+func (s *Server) getBooking(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.providers[name]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, types.ErrInvalidRequest, "unknown provider %q", name)
+		return
+	}
+	p.expireHolds(s.now())
+	booking, ok := p.bookings[r.PathValue("ref")]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, types.ErrInvalidRequest, "unknown %s booking %q", name, r.PathValue("ref"))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, booking)
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	if !s.injectFault(w, r, name) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.providers[name]
+	p.expireHolds(s.now())
+	booking, ok := p.bookings[r.PathValue("ref")]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, types.ErrInvalidRequest, "unknown %s booking %q", name, r.PathValue("ref"))
+		return
+	}
+	if booking.Status == types.StatusHeld || booking.Status == types.StatusReleased {
+		s.writeError(w, http.StatusConflict, types.ErrInvalidRequest, "%s %s is a hold; release it instead", name, booking.Ref)
+		return
+	}
+	if booking.Status != types.StatusCancelled {
+		booking.Status = types.StatusCancelled
+		booking.CancelledAt = s.now()
+		p.inventory[booking.Item] = p.available(booking.Item) + 1
+	}
+	s.writeJSON(w, http.StatusOK, booking)
+}
+
+func (s *Server) getInventory(w http.ResponseWriter, r *http.Request) {
+	s.withProvider(w, r, func(p *provider) {
+		p.expireHolds(s.now())
+		s.writeJSON(w, http.StatusOK, maps.Clone(p.inventory))
+	})
+}
+
+func (s *Server) setInventory(w http.ResponseWriter, r *http.Request) {
+	var update InventoryUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil || update.Available < 0 {
+		s.writeError(w, http.StatusBadRequest, types.ErrInvalidRequest, "available must be a count of at least 0")
+		return
+	}
+	s.withProvider(w, r, func(p *provider) {
+		p.inventory[r.PathValue("item")] = update.Available
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (s *Server) getFault(w http.ResponseWriter, r *http.Request) {
+	s.withProvider(w, r, func(p *provider) {
+		s.writeJSON(w, http.StatusOK, p.fault)
+	})
+}
+
+func (s *Server) setFault(w http.ResponseWriter, r *http.Request) {
+	var fault Fault
+	if err := json.NewDecoder(r.Body).Decode(&fault); err != nil {
+		s.writeError(w, http.StatusBadRequest, types.ErrInvalidRequest, "invalid fault: %v", err)
+		return
+	}
+	if err := fault.validate(); err != nil {
+		s.writeError(w, http.StatusBadRequest, types.ErrInvalidRequest, "invalid fault: %v", err)
+		return
+	}
+	s.withProvider(w, r, func(p *provider) {
+		p.fault = fault
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (s *Server) clearFault(w http.ResponseWriter, r *http.Request) {
+	s.withProvider(w, r, func(p *provider) {
+		p.fault = Fault{}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// withProvider runs fn with the provider named in the path and the server
+// locked
+func (s *Server) withProvider(w http.ResponseWriter, r *http.Request, fn func(p *provider)) {
+	name := r.PathValue("provider")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.providers[name]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, types.ErrInvalidRequest, "unknown provider %q", name)
+		return
+	}
+	fn(p)
+}
+
+// injectFault applies the provider's fault to a request. It reports false if
+// the request has already been answered, with an error or because the
+// provider does not exist.
+func (s *Server) injectFault(w http.ResponseWriter, r *http.Request, name string) bool {
+	s.mu.Lock()
+	p, ok := s.providers[name]
+	if !ok {
+		s.mu.Unlock()
+		s.writeError(w, http.StatusNotFound, types.ErrInvalidRequest, "unknown provider %q", name)
+		return false
+	}
+	fault := p.fault
+	fail := fault.FailNext > 0
+	if fail {
+		p.fault.FailNext--
+	} else {
+		fail = fault.ErrorRate > 0 && rand.Float64() < fault.ErrorRate
+	}
+	s.mu.Unlock()
+
+	if err := sleep(r.Context(), fault.latency); err != nil {
+		return false
+	}
+	if !fail {
+		return true
+	}
+
+	kind := fault.ErrorKind
+	if kind == "" {
+		kind = types.ErrProviderDown
+	}
+	s.writeError(w, statusFor(kind), kind, "%s failure injected", name)
+	return false
+}
+
+// statusFor returns the HTTP status a provider answers with for kind
+func statusFor(kind types.ErrorKind) int {
+	switch kind {
+	case types.ErrNotAvailable:
+		return http.StatusConflict
+	case types.ErrInvalidRequest:
+		return http.StatusBadRequest
+	case types.ErrRateLimited:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// sleep waits for d unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, kind types.ErrorKind, format string, args ...any) {
+	s.writeJSON(w, status, ErrorResponse{Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	web.WriteJSON(w, s.logger, status, v)
+}
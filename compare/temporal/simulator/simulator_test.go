@@ -0,0 +1,279 @@
+package simulator
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+func newTestClient(t *testing.T) (*Client, *httptest.Server) {
+	server := httptest.NewServer(NewServer(slog.New(slog.NewTextHandler(io.Discard, nil))).Handler())
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, server.Client()), server
+}
+
+// requireKind checks err is a booking error of kind
+func requireKind(t *testing.T, err error, kind types.ErrorKind) {
+	t.Helper()
+	var failure types.BookingError
+	require.ErrorAs(t, err, &failure)
+	require.Equal(t, kind, failure.Kind, failure.Message)
+}
+
+func TestBookAndCancel(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	booking, err := c.Book(ctx, types.ComponentHotel, "key-1", BookingRequest{Item: "hotel-1", Price: 200})
+	require.NoError(t, err)
+	require.Equal(t, "HTL-1", booking.Ref)
+	require.Equal(t, types.StatusConfirmed, booking.Status)
+	require.Equal(t, 200.0, booking.Confirmation().Price)
+
+	inventory, err := c.Inventory(ctx, types.ComponentHotel)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"hotel-1": DefaultCapacity - 1}, inventory)
+
+	require.NoError(t, c.Cancel(ctx, types.ComponentHotel, "key-2", booking.Ref))
+	require.NoError(t, c.Cancel(ctx, types.ComponentHotel, "key-3", booking.Ref), "cancelling twice is fine")
+
+	cancelled, err := c.Booking(ctx, types.ComponentHotel, booking.Ref)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusCancelled, cancelled.Status)
+	require.False(t, cancelled.CancelledAt.IsZero())
+
+	inventory, err = c.Inventory(ctx, types.ComponentHotel)
+	require.NoError(t, err)
+	require.Equal(t, DefaultCapacity, inventory["hotel-1"], "inventory is restored once")
+}
+
+// newTestClientAt returns a client for a simulator whose clock the test moves
+// by hand
+func newTestClientAt(t *testing.T, now *time.Time) *Client {
+	sim := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	sim.now = func() time.Time { return *now }
+	server := httptest.NewServer(sim.Handler())
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, server.Client())
+}
+
+func TestHoldConfirmAndRelease(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := newTestClientAt(t, &now)
+
+	hold, err := c.Hold(ctx, types.ComponentHotel, "key-1", HoldRequest{Item: "hotel-1", Price: 200, HoldFor: "15m"})
+	require.NoError(t, err)
+	require.Equal(t, types.StatusHeld, hold.Status)
+	require.Equal(t, now.Add(15*time.Minute), hold.Confirmation().ExpiresAt)
+	again, err := c.Hold(ctx, types.ComponentHotel, "key-1", HoldRequest{Item: "hotel-1", Price: 200, HoldFor: "15m"})
+	require.NoError(t, err)
+	require.Equal(t, hold.Ref, again.Ref)
+
+	other, err := c.Hold(ctx, types.ComponentHotel, "key-2", HoldRequest{Item: "hotel-1", Price: 200, HoldFor: "15m"})
+	require.NoError(t, err)
+	inventory, err := c.Inventory(ctx, types.ComponentHotel)
+	require.NoError(t, err)
+	require.Equal(t, DefaultCapacity-2, inventory["hotel-1"], "holds take inventory")
+
+	booking, err := c.Confirm(ctx, types.ComponentHotel, "key-3", hold.Ref)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusConfirmed, booking.Status)
+	require.True(t, booking.Confirmation().ExpiresAt.IsZero())
+	_, err = c.Confirm(ctx, types.ComponentHotel, "key-3", hold.Ref)
+	require.NoError(t, err, "confirming twice is fine")
+	requireKind(t, c.Release(ctx, types.ComponentHotel, "key-4", hold.Ref), types.ErrInvalidRequest)
+
+	require.NoError(t, c.Release(ctx, types.ComponentHotel, "key-5", other.Ref))
+	require.NoError(t, c.Release(ctx, types.ComponentHotel, "key-6", other.Ref), "releasing twice is fine")
+	requireKind(t, c.Cancel(ctx, types.ComponentHotel, "key-7", other.Ref), types.ErrInvalidRequest)
+	_, err = c.Confirm(ctx, types.ComponentHotel, "key-8", other.Ref)
+	requireKind(t, err, types.ErrHoldExpired)
+
+	inventory, err = c.Inventory(ctx, types.ComponentHotel)
+	require.NoError(t, err)
+	require.Equal(t, DefaultCapacity-1, inventory["hotel-1"], "only the confirmed hold keeps its room")
+}
+
+func TestHoldsExpire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := newTestClientAt(t, &now)
+
+	require.NoError(t, c.SetInventory(ctx, types.ComponentCar, "SUV", 1))
+	hold, err := c.Hold(ctx, types.ComponentCar, "key-1", HoldRequest{Item: "SUV", Price: 100, HoldFor: "10m"})
+	require.NoError(t, err)
+	_, err = c.Book(ctx, types.ComponentCar, "key-2", BookingRequest{Item: "SUV", Price: 100})
+	requireKind(t, err, types.ErrNotAvailable)
+
+	now = now.Add(10 * time.Minute)
+	_, err = c.Confirm(ctx, types.ComponentCar, "key-3", hold.Ref)
+	requireKind(t, err, types.ErrHoldExpired)
+	expired, err := c.Booking(ctx, types.ComponentCar, hold.Ref)
+	require.NoError(t, err)
+	require.Equal(t, types.StatusReleased, expired.Status)
+	require.NoError(t, c.Release(ctx, types.ComponentCar, "key-4", hold.Ref), "releasing an expired hold is fine")
+
+	_, err = c.Book(ctx, types.ComponentCar, "key-5", BookingRequest{Item: "SUV", Price: 100})
+	require.NoError(t, err, "the expired hold gave the car back")
+
+	_, err = c.Hold(ctx, types.ComponentCar, "key-6", HoldRequest{Item: "SUV", Price: 100})
+	requireKind(t, err, types.ErrInvalidRequest)
+}
+
+func TestBookIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	first, err := c.Book(ctx, types.ComponentFlight, "key-1", BookingRequest{Item: "FL123", Price: 500})
+	require.NoError(t, err)
+	again, err := c.Book(ctx, types.ComponentFlight, "key-1", BookingRequest{Item: "FL123", Price: 500})
+	require.NoError(t, err)
+	require.Equal(t, first.Ref, again.Ref)
+
+	// Without a key every request is a new booking
+	other, err := c.Book(ctx, types.ComponentFlight, "", BookingRequest{Item: "FL123", Price: 500})
+	require.NoError(t, err)
+	require.NotEqual(t, first.Ref, other.Ref)
+
+	inventory, err := c.Inventory(ctx, types.ComponentFlight)
+	require.NoError(t, err)
+	require.Equal(t, DefaultCapacity-2, inventory["FL123"])
+}
+
+func TestInventoryRunsOut(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	require.NoError(t, c.SetInventory(ctx, types.ComponentCar, "SUV", 1))
+	_, err := c.Book(ctx, types.ComponentCar, "key-1", BookingRequest{Item: "SUV", Price: 100})
+	require.NoError(t, err)
+
+	_, err = c.Book(ctx, types.ComponentCar, "key-2", BookingRequest{Item: "SUV", Price: 100})
+	requireKind(t, err, types.ErrNotAvailable)
+
+	// Other items are unaffected
+	_, err = c.Book(ctx, types.ComponentCar, "key-3", BookingRequest{Item: "compact", Price: 60})
+	require.NoError(t, err)
+}
+
+func TestRejectedRequests(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	_, err := c.Book(ctx, types.ComponentHotel, "key-1", BookingRequest{Price: 200})
+	requireKind(t, err, types.ErrInvalidRequest)
+
+	_, err = c.Book(ctx, "train", "key-2", BookingRequest{Item: "ICE-1", Price: 80})
+	requireKind(t, err, types.ErrInvalidRequest)
+
+	err = c.Cancel(ctx, types.ComponentHotel, "key-3", "HTL-404")
+	requireKind(t, err, types.ErrInvalidRequest)
+
+	err = c.SetFault(ctx, types.ComponentHotel, Fault{ErrorRate: 2})
+	requireKind(t, err, types.ErrInvalidRequest)
+
+	err = c.SetFault(ctx, types.ComponentHotel, Fault{ErrorKind: "GremlinError"})
+	requireKind(t, err, types.ErrInvalidRequest)
+
+	err = c.SetInventory(ctx, types.ComponentHotel, "hotel-1", -1)
+	requireKind(t, err, types.ErrInvalidRequest)
+}
+
+func TestInjectedFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    Fault
+		wantKind types.ErrorKind
+	}{
+		{name: "provider down by default", fault: Fault{FailNext: 1}, wantKind: types.ErrProviderDown},
+		{name: "rate limited", fault: Fault{ErrorRate: 1, ErrorKind: types.ErrRateLimited}, wantKind: types.ErrRateLimited},
+		{name: "not available", fault: Fault{FailNext: 1, ErrorKind: types.ErrNotAvailable}, wantKind: types.ErrNotAvailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, _ := newTestClient(t)
+			require.NoError(t, c.SetFault(ctx, types.ComponentHotel, tt.fault))
+
+			_, err := c.Book(ctx, types.ComponentHotel, "key-1", BookingRequest{Item: "hotel-1", Price: 200})
+			requireKind(t, err, tt.wantKind)
+
+			// Faults are per provider
+			_, err = c.Book(ctx, types.ComponentFlight, "key-2", BookingRequest{Item: "FL123", Price: 500})
+			require.NoError(t, err)
+
+			require.NoError(t, c.ClearFault(ctx, types.ComponentHotel))
+			_, err = c.Book(ctx, types.ComponentHotel, "key-1", BookingRequest{Item: "hotel-1", Price: 200})
+			require.NoError(t, err, "the failed request can be retried")
+		})
+	}
+}
+
+func TestFailNextCountsDown(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	require.NoError(t, c.SetFault(ctx, types.ComponentCar, Fault{FailNext: 2}))
+
+	for range 2 {
+		_, err := c.Book(ctx, types.ComponentCar, "key-1", BookingRequest{Item: "SUV", Price: 100})
+		requireKind(t, err, types.ErrProviderDown)
+	}
+	_, err := c.Book(ctx, types.ComponentCar, "key-1", BookingRequest{Item: "SUV", Price: 100})
+	require.NoError(t, err)
+}
+
+func TestInjectedLatency(t *testing.T) {
+	ctx := context.Background()
+	c, server := newTestClient(t)
+	require.NoError(t, c.SetFault(ctx, types.ComponentFlight, Fault{Latency: "100ms"}))
+
+	start := time.Now()
+	_, err := c.Book(ctx, types.ComponentFlight, "key-1", BookingRequest{Item: "FL123", Price: 500})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	// A caller that gives up first is not booked
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Book(short, types.ComponentFlight, "key-2", BookingRequest{Item: "FL123", Price: 500})
+	requireKind(t, err, types.ErrProviderDown)
+
+	resp, err := server.Client().Get(server.URL + "/flight/admin/faults")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `"latency":"100ms"`)
+}
+
+func TestUnreachableProvider(t *testing.T) {
+	c, server := newTestClient(t)
+	server.Close()
+
+	_, err := c.Book(context.Background(), types.ComponentHotel, "key-1", BookingRequest{Item: "hotel-1", Price: 200})
+	requireKind(t, err, types.ErrProviderDown)
+}
+
+func TestOneLogLinePerRequest(t *testing.T) {
+	var logs strings.Builder
+	handler := NewServer(slog.New(slog.NewTextHandler(&logs, nil))).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/hotel/bookings/HTL-404", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], "Provider request")
+	require.Contains(t, lines[0], "status=404")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// bookWithHolds books hotel, flight and car try-confirm-cancel style. All
+// three are held at once, and only once every hold is in place are they
+// confirmed. If a hold fails, or is not in place before the others would
+// expire, the holds are released instead of bookings being cancelled, so a
+// failed trip costs no cancellation fees. Unlike the other modes a failed
+// car is not offered to the user to accept, as the holds would run out while
+// they decided.
+func bookWithHolds(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga) error {
+	logger := workflow.GetLogger(ctx)
+
+	holdFor := booking.HoldTimeout
+	if holdFor == 0 {
+		holdFor = DefaultHoldTimeout
+	}
+	components := booking.Components()
+
+	// Try: hold everything, giving up on holds still not in place when the
+	// first ones would expire
+	holds, errs, expired := holdAll(ctx, *booking, components, holdFor)
+	var failed []error
+	var held []string
+	for i, component := range components {
+		if errs[i] != nil {
+			if expired && temporal.IsCanceledError(errs[i]) {
+				errs[i] = fmt.Errorf("%s hold not in place within %s", component, holdFor)
+			}
+			logger.Error("Failed to hold "+component, slog.String("error", errs[i].Error()))
+			recordFailure(ctx, booking, component, errs[i])
+			failed = append(failed, errs[i])
+			continue
+		}
+		confirm(ctx, booking, component, holds[i])
+		held = append(held, component)
+	}
+	if len(failed) > 0 {
+		releaseHolds(ctx, booking, held)
+		err := compensate(ctx, booking, compensations, errors.Join(failed...))
+		setStatus(ctx, booking, types.ComponentBooking, types.StatusFailed, "hold failed; holds released")
+		return err
+	}
+
+	// Confirm: every hold is in place
+	confirmations := make([]types.BookingConfirmation, len(components))
+	wg := workflow.NewWaitGroup(ctx)
+	for i, component := range components {
+		wg.Add(1)
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer wg.Done()
+			confirmations[i], errs[i] = confirmHold(ctx, *booking, component)
+			confirmations[i].Alternative = holds[i].Alternative
+		})
+	}
+	wg.Wait(ctx)
+
+	var unconfirmed []string
+	for i, component := range components {
+		if errs[i] != nil {
+			logger.Error("Failed to confirm "+component, slog.String("error", errs[i].Error()))
+			recordFailure(ctx, booking, component, errs[i])
+			failed = append(failed, errs[i])
+			unconfirmed = append(unconfirmed, component)
+			continue
+		}
+		booked(ctx, booking, compensations, component, confirmations[i])
+	}
+	if len(failed) > 0 {
+		// Holds that could not be confirmed are released, in case the
+		// provider still has them, and whatever was confirmed is cancelled
+		releaseHolds(ctx, booking, unconfirmed)
+		err := compensate(ctx, booking, compensations, errors.Join(failed...))
+		setStatus(ctx, booking, types.ComponentBooking, types.StatusFailed, "confirmation failed")
+		return err
+	}
+	return nil
+}
+
+// holdAll holds every component at once. It waits for them all, or until
+// holdFor has passed, when the holds still being placed are cancelled and
+// expired is set.
+func holdAll(ctx workflow.Context, booking types.TravelBooking, components []string, holdFor time.Duration) (holds []types.BookingConfirmation, errs []error, expired bool) {
+	holds = make([]types.BookingConfirmation, len(components))
+	errs = make([]error, len(components))
+
+	holdCtx, stopHolding := workflow.WithCancel(ctx)
+	defer stopHolding()
+
+	wg := workflow.NewWaitGroup(ctx)
+	for i, component := range components {
+		wg.Add(1)
+		workflow.Go(holdCtx, func(ctx workflow.Context) {
+			defer wg.Done()
+			holds[i], errs[i] = holdComponent(ctx, booking, component, holdFor)
+		})
+	}
+	allPlaced := workflow.NewChannel(ctx)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		wg.Wait(ctx)
+		allPlaced.Close()
+	})
+
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(allPlaced, func(workflow.ReceiveChannel, bool) {})
+	selector.AddFuture(workflow.NewTimer(holdCtx, holdFor), func(workflow.Future) {
+		expired = true
+	})
+	selector.Select(ctx)
+	if expired {
+		stopHolding()
+		allPlaced.Receive(ctx, nil)
+	}
+	return holds, errs, expired
+}
+
+// holdComponent asks the provider for component to hold it for holdFor,
+// falling back on its alternatives when the first choice is not available
+func holdComponent(ctx workflow.Context, booking types.TravelBooking, component string, holdFor time.Duration) (types.BookingConfirmation, error) {
+	return withAlternatives(ctx, booking, component, func(ctx workflow.Context, n int) (types.BookingConfirmation, error) {
+		var hold any
+		var details any
+		switch kind, _ := types.ComponentKind(component); kind {
+		case types.ComponentHotel:
+			hold, details = HoldHotelActivity, booking.Hotel(component).Choice(n)
+		case types.ComponentFlight:
+			hold, details = HoldFlightActivity, booking.Flight(component).Choice(n)
+		case types.ComponentCar:
+			hold, details = HoldCarActivity, booking.Car(component).Choice(n)
+		default:
+			return types.BookingConfirmation{}, fmt.Errorf("unknown component %q", component)
+		}
+
+		var confirmation types.BookingConfirmation
+		err := executeActivity(ctx, hold, details, holdFor).Get(ctx, &confirmation)
+		return confirmation, err
+	})
+}
+
+// confirmHold turns the hold on component into a booking
+func confirmHold(ctx workflow.Context, booking types.TravelBooking, component string) (types.BookingConfirmation, error) {
+	var confirmHold any
+	var holdRef string
+	switch kind, _ := types.ComponentKind(component); kind {
+	case types.ComponentHotel:
+		confirmHold, holdRef = ConfirmHotelActivity, booking.Hotel(component).BookingRef
+	case types.ComponentFlight:
+		confirmHold, holdRef = ConfirmFlightActivity, booking.Flight(component).BookingRef
+	case types.ComponentCar:
+		confirmHold, holdRef = ConfirmCarActivity, booking.Car(component).BookingRef
+	default:
+		return types.BookingConfirmation{}, fmt.Errorf("unknown component %q", component)
+	}
+
+	var confirmation types.BookingConfirmation
+	err := executeActivity(ctx, confirmHold, holdRef).Get(ctx, &confirmation)
+	return confirmation, err
+}
+
+// releaseHolds releases the holds on components in parallel, marking the
+// ones still held as released. Releasing a hold that already expired is
+// fine. A hold that cannot be released is left to expire, so unlike a
+// cancellation it never needs an operator.
+func releaseHolds(ctx workflow.Context, booking *types.TravelBooking, components []string) {
+	ctx = withOwnRetry(disconnected(ctx), compensationRetryPolicy)
+
+	futures := make([]workflow.Future, len(components))
+	holdRefs := make([]string, len(components))
+	for i, component := range components {
+		var release any
+		switch kind, _ := types.ComponentKind(component); kind {
+		case types.ComponentHotel:
+			release, holdRefs[i] = ReleaseHotelActivity, booking.Hotel(component).BookingRef
+		case types.ComponentFlight:
+			release, holdRefs[i] = ReleaseFlightActivity, booking.Flight(component).BookingRef
+		case types.ComponentCar:
+			release, holdRefs[i] = ReleaseCarActivity, booking.Car(component).BookingRef
+		}
+		futures[i] = executeActivity(ctx, release, holdRefs[i])
+	}
+
+	for i, component := range components {
+		reason := "hold released"
+		if err := futures[i].Get(ctx, nil); err != nil {
+			workflow.GetLogger(ctx).Warn("Hold not released; leaving it to expire",
+				slog.String("component", component),
+				slog.String("hold_ref", holdRefs[i]),
+				slog.String("error", err.Error()))
+			reason = "release failed; left to expire"
+		}
+		if *componentStatus(booking, component) == types.StatusHeld {
+			setStatus(ctx, booking, component, types.StatusReleased, reason)
+		}
+	}
+}
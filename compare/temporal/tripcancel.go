@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/leowmjw/go-durable-x/temporal/notification"
+	"github.com/leowmjw/go-durable-x/temporal/saga"
+	"github.com/leowmjw/go-durable-x/temporal/types"
+)
+
+// UpdateCancelTrip cancels a confirmed trip for the user. It takes a
+// types.TripCancellation and returns the types.CancellationBreakdown once
+// every component is cancelled and the refund made. It is rejected unless
+// the trip is confirmed and has not started.
+const UpdateCancelTrip = "cancel-trip"
+
+// RefundTier refunds Refund, a share of each component's price, for a trip
+// cancelled at least Notice before it starts
+type RefundTier struct {
+	Notice time.Duration
+	Refund float64
+}
+
+// RefundPolicy is applied to trips the user cancels, most notice first. The
+// first tier the cancellation gives enough notice for sets the refund; with
+// less notice than every tier nothing is refunded.
+var RefundPolicy = []RefundTier{
+	{Notice: 14 * 24 * time.Hour, Refund: 1},
+	{Notice: 7 * 24 * time.Hour, Refund: 0.5},
+	{Notice: VerifyBeforeStart, Refund: 0.25},
+}
+
+// refundRate returns the share of the price refunded with notice left
+// before the trip
+func refundRate(notice time.Duration) float64 {
+	for _, tier := range RefundPolicy {
+		if notice >= tier.Notice {
+			return tier.Refund
+		}
+	}
+	return 0
+}
+
+// tripCanceller hands cancel-trip updates to monitorTrip, which owns the
+// booking while the trip is monitored, and the outcome back to the update.
+// The request is buffered, so an update never waits on monitorTrip to take
+// it, and one monitorTrip never takes is answered when the workflow ends.
+type tripCanceller struct {
+	requests  workflow.Channel
+	requested bool
+	// done is set once the trip is cancelled, closed once the workflow stops
+	// taking requests, and answered once the update has its outcome, so the
+	// workflow does not complete before answering
+	done, closed, answered bool
+	breakdown              types.CancellationBreakdown
+	err                    error
+}
+
+// cancel cancels the trip for request and waits for the update that asked
+// for it to be answered
+func (c *tripCanceller) cancel(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga, request types.TripCancellation) {
+	c.breakdown, c.err = cancelTrip(ctx, booking, compensations, request)
+	c.done = true
+	_ = workflow.Await(ctx, func() bool { return c.answered })
+}
+
+// close stops taking requests and waits for any update still pending to be
+// answered, even when the workflow is being cancelled
+func (c *tripCanceller) close(ctx workflow.Context) {
+	c.closed = true
+	if c.requested {
+		ctx, _ = workflow.NewDisconnectedContext(ctx)
+		_ = workflow.Await(ctx, func() bool { return c.answered })
+	}
+}
+
+// stateConflict is the error for a cancellation the booking is in no state
+// for; the API reports it as a conflict
+func stateConflict(format string, args ...any) error {
+	return temporal.NewNonRetryableApplicationError(fmt.Sprintf(format, args...), string(types.ErrStateConflict), nil)
+}
+
+// setCancelTripHandler registers the cancel-trip update for booking
+func setCancelTripHandler(ctx workflow.Context, booking *types.TravelBooking) (*tripCanceller, error) {
+	canceller := &tripCanceller{requests: workflow.NewBufferedChannel(ctx, 1)}
+	err := workflow.SetUpdateHandlerWithOptions(ctx, UpdateCancelTrip,
+		func(ctx workflow.Context, request types.TripCancellation) (types.CancellationBreakdown, error) {
+			defer func() { canceller.answered = true }()
+			canceller.requested = true
+			canceller.requests.SendAsync(request)
+			if err := workflow.Await(ctx, func() bool { return canceller.done || canceller.closed }); err != nil {
+				return types.CancellationBreakdown{}, err
+			}
+			if !canceller.done {
+				return types.CancellationBreakdown{}, stateConflict("trip was not cancelled; booking is %s", booking.Status)
+			}
+			return canceller.breakdown, canceller.err
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, request types.TripCancellation) error {
+				switch {
+				case canceller.requested:
+					return stateConflict("trip is already being cancelled")
+				case canceller.closed:
+					return stateConflict("trip can no longer be cancelled; booking is %s", booking.Status)
+				case booking.Status != types.StatusConfirmed && booking.Status != types.StatusPartiallyConfirmed:
+					return stateConflict("only a confirmed trip can be cancelled; booking is %s", booking.Status)
+				case !workflow.Now(ctx).Before(booking.StartDate):
+					return stateConflict("trip has already started")
+				}
+				return nil
+			},
+		})
+	return canceller, err
+}
+
+// cancelTrip cancels every confirmed component of booking, in the reverse of
+// the order they were booked, and refunds the payment less the fees
+// RefundPolicy keeps. It runs on a disconnected context, so a trip cancelled
+// because the workflow is being cancelled is still unwound.
+func cancelTrip(ctx workflow.Context, booking *types.TravelBooking, compensations *saga.Saga, request types.TripCancellation) (types.CancellationBreakdown, error) {
+	ctx = disconnected(ctx)
+
+	notice := max(booking.StartDate.Sub(workflow.Now(ctx)), 0)
+	breakdown := types.CancellationBreakdown{Notice: notice, RefundRate: refundRate(notice)}
+
+	// Unwound last to first: the payment was taken before anything was booked
+	unwind := saga.New(saga.Options{})
+	payment := booking.Payment
+	paid := payment != nil && payment.Status == types.StatusCaptured
+	if paid {
+		unwind.AddCompensation(types.ComponentPayment, refundPayment(booking, &breakdown))
+	}
+	for _, component := range booking.Components() {
+		if *componentStatus(booking, component) != types.StatusConfirmed {
+			continue
+		}
+		price, bookingRef := componentPrice(booking, component)
+		refund := cents(price * breakdown.RefundRate)
+		breakdown.Components = append(breakdown.Components, types.ComponentRefund{
+			Component:  component,
+			BookingRef: bookingRef,
+			Price:      price,
+			Fee:        cents(price - refund),
+			Refund:     refund,
+		})
+		breakdown.Fees = cents(breakdown.Fees + price - refund)
+		unwind.AddCompensation(component, cancelComponent(booking, component))
+	}
+	// Only money that was taken can be given back
+	if paid {
+		breakdown.Refund = cents(max(payment.CapturedAmount-breakdown.Fees, 0))
+	}
+
+	workflow.GetLogger(ctx).Info("Cancelling trip",
+		slog.String("booking_id", booking.BookingID),
+		slog.String("reason", request.Reason),
+		slog.Duration("notice", notice),
+		slog.Float64("fees", breakdown.Fees),
+		slog.Float64("refund", breakdown.Refund))
+
+	// What was booked is now unwound here instead
+	compensations.Clear()
+	err := compensate(ctx, booking, unwind, nil)
+	setStatus(ctx, booking, types.ComponentBooking, types.StatusCancelled, "cancelled by user: "+request.Reason)
+	notifyUser(ctx, newMessage(*booking, notification.EventCancelled, "Travel Booking Cancelled",
+		fmt.Sprintf("Your travel booking %s has been cancelled. Cancellation fees come to %.2f and %.2f will be refunded.",
+			booking.BookingID, breakdown.Fees, breakdown.Refund)))
+	return breakdown, err
+}
+
+// refundPayment returns the compensation refunding the Refund of breakdown,
+// read when it runs, from the captured payment
+func refundPayment(booking *types.TravelBooking, breakdown *types.CancellationBreakdown) saga.Compensation {
+	return func(ctx workflow.Context) error {
+		payment := booking.Payment
+		if breakdown.Refund > 0 {
+			refundCtx, key := paymentCall(ctx, "cancel-refund")
+			err := executeActivity(refundCtx, RefundPaymentActivity, key, payment.AuthorizationRef, breakdown.Refund).Get(ctx, nil)
+			if err != nil {
+				return err
+			}
+		}
+		payment.RefundedAmount = breakdown.Refund
+		setStatus(ctx, booking, types.ComponentPayment, types.StatusRefunded,
+			fmt.Sprintf("refunded %.2f, keeping %.2f in cancellation fees", breakdown.Refund, payment.CapturedAmount-breakdown.Refund))
+		return nil
+	}
+}
+
+// componentPrice returns the price and booking reference of component
+func componentPrice(booking *types.TravelBooking, component string) (float64, string) {
+	switch kind, _ := types.ComponentKind(component); kind {
+	case types.ComponentHotel:
+		hotel := booking.Hotel(component)
+		return hotel.Price, hotel.BookingRef
+	case types.ComponentFlight:
+		flight := booking.Flight(component)
+		return flight.Price, flight.BookingRef
+	case types.ComponentCar:
+		car := booking.Car(component)
+		return car.Price, car.BookingRef
+	}
+	return 0, ""
+}
+
+// cents rounds amount to the nearest cent
+func cents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// disconnected returns a context for compensations that is not cancelled
+// with the workflow, so a cancelled workflow still unwinds what it booked.
+// Runs started before trip cancellation compensate on ctx as it is.
+func disconnected(ctx workflow.Context) workflow.Context {
+	if !changed(ctx, changeTripCancellation) {
+		return ctx
+	}
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	return ctx
+}
@@ -1,6 +1,14 @@
 package types
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type BookingStatus string
 
@@ -10,6 +18,53 @@ const (
 	StatusConfirmed BookingStatus = "CONFIRMED"
 	StatusFailed    BookingStatus = "FAILED"
 	StatusCancelled BookingStatus = "CANCELLED"
+
+	// StatusPartiallyConfirmed is a trip the user accepted without one of its components
+	StatusPartiallyConfirmed BookingStatus = "PARTIALLY_CONFIRMED"
+	// StatusNeedsIntervention is a booking parked until an operator sorts out
+	// a compensation that could not be completed automatically
+	StatusNeedsIntervention BookingStatus = "NEEDS_MANUAL_INTERVENTION"
+	// StatusCompleted is a confirmed trip whose EndDate has passed
+	StatusCompleted BookingStatus = "COMPLETED"
+
+	// Payment statuses: funds are held when authorized and taken when
+	// captured. An authorization that is not captured is voided; a capture
+	// that has to be given back is refunded.
+	StatusAuthorized BookingStatus = "AUTHORIZED"
+	StatusCaptured   BookingStatus = "CAPTURED"
+	StatusVoided     BookingStatus = "VOIDED"
+	StatusRefunded   BookingStatus = "REFUNDED"
+
+	// Hold statuses: in ModeTCC a provider sets a component aside for a
+	// while first. A hold is confirmed into a booking or released, which
+	// costs nothing; a hold left alone is released when it expires.
+	StatusHeld     BookingStatus = "HELD"
+	StatusReleased BookingStatus = "RELEASED"
+)
+
+// BookingMode selects how the components of a trip are booked
+type BookingMode string
+
+const (
+	// ModeSequential books hotel, then flight, then car; it is the default
+	ModeSequential BookingMode = ""
+	// ModeParallel books all three at once
+	ModeParallel BookingMode = "PARALLEL"
+	// ModeTCC holds all three at once and confirms them only once every
+	// hold is in place (try-confirm-cancel), so a failure releases holds
+	// instead of cancelling bookings
+	ModeTCC BookingMode = "TCC"
+)
+
+// Components of a travel booking; ComponentBooking is the trip as a whole.
+// In an itinerary the first hotel, flight or car is named by its kind and
+// the ones after it by ComponentName, such as "flight-2".
+const (
+	ComponentBooking = "booking"
+	ComponentHotel   = "hotel"
+	ComponentFlight  = "flight"
+	ComponentCar     = "car"
+	ComponentPayment = "payment"
 )
 
 type TravelBooking struct {
@@ -19,19 +74,348 @@ type TravelBooking struct {
 	EndDate     time.Time
 	TotalAmount float64
 	Status      BookingStatus
+	Mode        BookingMode
+
+	// Contact is where the user is sent notifications about the trip
+	Contact Contact
+
+	// ApprovalTimeout is how long to wait for the user to accept a partial
+	// booking before compensating; zero uses the workflow default
+	ApprovalTimeout time.Duration
+
+	// HoldTimeout is how long providers hold each component in ModeTCC
+	// before releasing it; zero uses the workflow default
+	HoldTimeout time.Duration
 
-	// Individual bookings
+	// Individual bookings, for a trip with one of each
 	HotelBooking  *HotelBooking
 	FlightBooking *FlightBooking
 	CarBooking    *CarBooking
+
+	// Hotels, Flights and Cars are the itinerary of a trip with several of
+	// a component, such as outbound and return flights, in travel order.
+	// They are used instead of HotelBooking, FlightBooking and CarBooking.
+	Hotels  []*HotelBooking
+	Flights []*FlightBooking
+	Cars    []*CarBooking
+
+	// VerifiedAt is when the providers last confirmed the bookings still
+	// stand, two days before the trip
+	VerifiedAt time.Time
+
+	// Payment is filled in by the workflow when TotalAmount is charged;
+	// bookings without a TotalAmount are not charged
+	Payment *Payment
+
+	// AuditLog records every status change, oldest first; it is only appended to
+	AuditLog []StateTransition
+	// Errors captures provider failures and fatal errors, such as
+	// compensations that ran out of retries
+	Errors []BookingError
+}
+
+// Validate checks a new booking request has everything the workflow needs
+func (b TravelBooking) Validate() error {
+	var errs []error
+	if b.BookingID == "" {
+		errs = append(errs, errors.New("booking id is required"))
+	}
+	if b.UserID == "" {
+		errs = append(errs, errors.New("user id is required"))
+	}
+	if b.StartDate.IsZero() || b.EndDate.IsZero() {
+		errs = append(errs, errors.New("start and end dates are required"))
+	} else if !b.EndDate.After(b.StartDate) {
+		errs = append(errs, errors.New("end date must be after start date"))
+	}
+	hotels, flights, cars := b.HotelLegs(), b.FlightLegs(), b.CarLegs()
+	if len(hotels) == 0 || len(flights) == 0 || len(cars) == 0 {
+		errs = append(errs, errors.New("hotel, flight and car bookings are required"))
+	}
+	if (b.HotelBooking != nil && len(b.Hotels) > 0) || (b.FlightBooking != nil && len(b.Flights) > 0) || (b.CarBooking != nil && len(b.Cars) > 0) {
+		errs = append(errs, errors.New("use either the single bookings or the itinerary lists for each component, not both"))
+	}
+	if b.TotalAmount < 0 {
+		errs = append(errs, errors.New("total amount must not be negative"))
+	}
+	if b.ApprovalTimeout < 0 {
+		errs = append(errs, errors.New("approval timeout must not be negative"))
+	}
+	if b.HoldTimeout < 0 {
+		errs = append(errs, errors.New("hold timeout must not be negative"))
+	}
+	for i, hotel := range hotels {
+		component := ComponentName(ComponentHotel, i)
+		if hotel == nil {
+			errs = append(errs, fmt.Errorf("%s is empty", component))
+			continue
+		}
+		errs = append(errs, validateAlternatives(component, hotel.MaxPrice, len(hotel.Alternatives), func(i int) (string, float64) {
+			return hotel.Alternatives[i].HotelID, hotel.Alternatives[i].Price
+		}))
+	}
+	for i, flight := range flights {
+		component := ComponentName(ComponentFlight, i)
+		if flight == nil {
+			errs = append(errs, fmt.Errorf("%s is empty", component))
+			continue
+		}
+		errs = append(errs, validateAlternatives(component, flight.MaxPrice, len(flight.Alternatives), func(i int) (string, float64) {
+			return flight.Alternatives[i].FlightNumber, flight.Alternatives[i].Price
+		}))
+	}
+	for i, car := range cars {
+		component := ComponentName(ComponentCar, i)
+		if car == nil {
+			errs = append(errs, fmt.Errorf("%s is empty", component))
+			continue
+		}
+		errs = append(errs, validateAlternatives(component, car.MaxPrice, len(car.Alternatives), func(i int) (string, float64) {
+			return car.Alternatives[i].CarType, car.Alternatives[i].Price
+		}))
+	}
+	if !slices.Contains(hotels, nil) && !slices.Contains(flights, nil) && !slices.Contains(cars, nil) {
+		errs = append(errs, validateDates(hotels, flights, cars))
+	}
+	switch b.Mode {
+	case ModeSequential, ModeParallel, ModeTCC:
+	default:
+		errs = append(errs, fmt.Errorf("unknown booking mode %q", b.Mode))
+	}
+	if b.Contact.Email != "" {
+		if _, err := mail.ParseAddress(b.Contact.Email); err != nil {
+			errs = append(errs, fmt.Errorf("contact email: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateAlternatives checks the n alternatives of component, where
+// alternative returns the ID and price of the ith
+func validateAlternatives(component string, maxPrice float64, n int, alternative func(i int) (string, float64)) error {
+	var errs []error
+	if maxPrice < 0 {
+		errs = append(errs, fmt.Errorf("%s max price must not be negative", component))
+	}
+	for i := range n {
+		id, price := alternative(i)
+		if id == "" {
+			errs = append(errs, fmt.Errorf("%s alternative %d has no id", component, i+1))
+		}
+		if price < 0 {
+			errs = append(errs, fmt.Errorf("%s alternative %d price must not be negative", component, i+1))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateDates checks the dates of an itinerary connect, where they are
+// given: every component ends after it starts, flights and hotel stays each
+// follow on from the one before, and no hotel is checked into before the
+// first flight lands or while a later one is in the air
+func validateDates(hotels []*HotelBooking, flights []*FlightBooking, cars []*CarBooking) error {
+	var errs []error
+	// inOrder reports an error unless to is after from, when both are set
+	inOrder := func(from, to time.Time, format string, args ...any) {
+		if !from.IsZero() && !to.IsZero() && to.Before(from) {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	const layout = time.RFC3339
+
+	// Any alternative may be booked in place of a flight, so each is checked
+	// as the flight itself would be
+	choices := make([][]*FlightBooking, len(flights))
+	for i, flight := range flights {
+		for n := range len(flight.Alternatives) + 1 {
+			choices[i] = append(choices[i], flight.Choice(n))
+		}
+	}
+	for i := range flights {
+		for n, flight := range choices[i] {
+			component := choiceName(ComponentName(ComponentFlight, i), n)
+			inOrder(flight.Departure, flight.Arrival, "%s lands before it departs", component)
+			if i == 0 {
+				continue
+			}
+			for m, previous := range choices[i-1] {
+				inOrder(previous.Arrival, flight.Departure, "%s departs at %s, before %s lands at %s",
+					component, flight.Departure.Format(layout), choiceName(ComponentName(ComponentFlight, i-1), m), previous.Arrival.Format(layout))
+			}
+		}
+	}
+	for i, hotel := range hotels {
+		component := ComponentName(ComponentHotel, i)
+		inOrder(hotel.CheckIn, hotel.CheckOut, "%s checks out before it checks in", component)
+		if i > 0 {
+			inOrder(hotels[i-1].CheckOut, hotel.CheckIn, "%s check-in at %s is before %s check-out at %s",
+				component, hotel.CheckIn.Format(layout), ComponentName(ComponentHotel, i-1), hotels[i-1].CheckOut.Format(layout))
+		}
+		for j := range flights {
+			for n, flight := range choices[j] {
+				if hotel.CheckIn.IsZero() || flight.Arrival.IsZero() || !hotel.CheckIn.Before(flight.Arrival) {
+					continue
+				}
+				// Only the first flight has to land before any check-in; a
+				// later one only while it is in the air
+				if j == 0 || (!flight.Departure.IsZero() && hotel.CheckIn.After(flight.Departure)) {
+					errs = append(errs, fmt.Errorf("%s check-in at %s is before %s lands at %s",
+						component, hotel.CheckIn.Format(layout), choiceName(ComponentName(ComponentFlight, j), n), flight.Arrival.Format(layout)))
+				}
+			}
+		}
+	}
+	for i, car := range cars {
+		inOrder(car.PickUp, car.DropOff, "%s is dropped off before it is picked up", ComponentName(ComponentCar, i))
+	}
+	return errors.Join(errs...)
+}
+
+// choiceName names the choice of component ranked n, as validateAlternatives
+// does
+func choiceName(component string, n int) string {
+	if n == 0 {
+		return component
+	}
+	return fmt.Sprintf("%s alternative %d", component, n)
+}
+
+// ComponentName names the ith hotel, flight or car of an itinerary: kind
+// for the first, as for a trip with one of each, then kind-2, kind-3 and so
+// on
+func ComponentName(kind string, i int) string {
+	if i == 0 {
+		return kind
+	}
+	return fmt.Sprintf("%s-%d", kind, i+1)
+}
+
+// ComponentKind splits a component name from ComponentName into its kind
+// and position in the itinerary
+func ComponentKind(component string) (kind string, i int) {
+	kind, n, found := strings.Cut(component, "-")
+	if !found {
+		return component, 0
+	}
+	position, err := strconv.Atoi(n)
+	if err != nil || position < 2 {
+		return component, 0
+	}
+	return kind, position - 1
+}
+
+// HotelLegs returns every hotel of the trip in travel order
+func (b TravelBooking) HotelLegs() []*HotelBooking {
+	if len(b.Hotels) == 0 && b.HotelBooking != nil {
+		return []*HotelBooking{b.HotelBooking}
+	}
+	return b.Hotels
+}
+
+// FlightLegs returns every flight of the trip in travel order
+func (b TravelBooking) FlightLegs() []*FlightBooking {
+	if len(b.Flights) == 0 && b.FlightBooking != nil {
+		return []*FlightBooking{b.FlightBooking}
+	}
+	return b.Flights
+}
+
+// CarLegs returns every car of the trip in travel order
+func (b TravelBooking) CarLegs() []*CarBooking {
+	if len(b.Cars) == 0 && b.CarBooking != nil {
+		return []*CarBooking{b.CarBooking}
+	}
+	return b.Cars
+}
+
+// Components names every hotel, flight and car of the trip in the order
+// they are booked: the hotels, then the flights, then the cars
+func (b TravelBooking) Components() []string {
+	var components []string
+	for i := range b.HotelLegs() {
+		components = append(components, ComponentName(ComponentHotel, i))
+	}
+	for i := range b.FlightLegs() {
+		components = append(components, ComponentName(ComponentFlight, i))
+	}
+	for i := range b.CarLegs() {
+		components = append(components, ComponentName(ComponentCar, i))
+	}
+	return components
+}
+
+// Hotel returns the hotel named component, such as "hotel-2", or nil
+func (b TravelBooking) Hotel(component string) *HotelBooking {
+	kind, i := ComponentKind(component)
+	if hotels := b.HotelLegs(); kind == ComponentHotel && i < len(hotels) {
+		return hotels[i]
+	}
+	return nil
+}
+
+// Flight returns the flight named component, such as "flight-2", or nil
+func (b TravelBooking) Flight(component string) *FlightBooking {
+	kind, i := ComponentKind(component)
+	if flights := b.FlightLegs(); kind == ComponentFlight && i < len(flights) {
+		return flights[i]
+	}
+	return nil
+}
+
+// Car returns the car named component, such as "car-2", or nil
+func (b TravelBooking) Car(component string) *CarBooking {
+	kind, i := ComponentKind(component)
+	if cars := b.CarLegs(); kind == ComponentCar && i < len(cars) {
+		return cars[i]
+	}
+	return nil
+}
+
+// Contact holds the user's details for notifications. Email is optional;
+// without it the trip is only notified through channels that need no
+// address, such as a webhook.
+type Contact struct {
+	Name  string
+	Email string
+	Phone string
+}
+
+// StateTransition is one entry in a booking's audit trail. At is workflow time.
+type StateTransition struct {
+	Component string
+	From      BookingStatus
+	To        BookingStatus
+	Reason    string
+	At        time.Time
 }
 
 type HotelBooking struct {
-	HotelID    string
-	RoomType   string
-	Price      float64
-	Status     BookingStatus
-	BookingRef string
+	HotelID     string
+	RoomType    string
+	Price       float64
+	Status      BookingStatus
+	BookingRef  string
+	ConfirmedAt time.Time
+
+	// CheckIn and CheckOut are optional, and checked against the flights
+	CheckIn  time.Time
+	CheckOut time.Time
+
+	// Alternatives are other hotels to try, best first, when HotelID has no
+	// rooms; those priced above MaxPrice are skipped. Zero MaxPrice is no
+	// ceiling.
+	Alternatives []HotelAlternative
+	MaxPrice     float64
+	// Alternative is the rank of the alternative booked, counting from 1,
+	// or zero for the first choice. Booking one replaces HotelID, RoomType
+	// and Price with its own.
+	Alternative int
+}
+
+type HotelAlternative struct {
+	HotelID  string
+	RoomType string
+	Price    float64
 }
 
 type FlightBooking struct {
@@ -40,17 +424,235 @@ type FlightBooking struct {
 	Price        float64
 	Status       BookingStatus
 	BookingRef   string
+	ConfirmedAt  time.Time
+
+	// Departure and Arrival are optional, and checked, with those of every
+	// alternative, against the other flights and the hotels
+	Departure time.Time
+	Arrival   time.Time
+
+	// Alternatives are other flights to try, best first, when FlightNumber
+	// has no seats; those priced above MaxPrice are skipped. Zero MaxPrice
+	// is no ceiling.
+	Alternatives []FlightAlternative
+	MaxPrice     float64
+	// Alternative is the rank of the alternative booked, counting from 1,
+	// or zero for the first choice. Booking one replaces FlightNumber,
+	// SeatClass, Price, Departure and Arrival with its own.
+	Alternative int
+}
+
+type FlightAlternative struct {
+	FlightNumber string
+	SeatClass    string
+	Price        float64
+	// Departure and Arrival are optional, as for the flight
+	Departure time.Time
+	Arrival   time.Time
 }
 
 type CarBooking struct {
-	CarType    string
-	Price      float64
-	Status     BookingStatus
-	BookingRef string
+	CarType     string
+	Price       float64
+	Status      BookingStatus
+	BookingRef  string
+	ConfirmedAt time.Time
+
+	// PickUp and DropOff are optional
+	PickUp  time.Time
+	DropOff time.Time
+
+	// Alternatives are other cars to try, best first, when CarType has none
+	// left; those priced above MaxPrice are skipped. Zero MaxPrice is no
+	// ceiling.
+	Alternatives []CarAlternative
+	MaxPrice     float64
+	// Alternative is the rank of the alternative booked, counting from 1,
+	// or zero for the first choice. Booking one replaces CarType and Price
+	// with its own.
+	Alternative int
+}
+
+type CarAlternative struct {
+	CarType string
+	Price   float64
+}
+
+// Payment is the charge for the whole trip. The amount is authorized before
+// anything is booked and only captured once the trip is confirmed.
+type Payment struct {
+	Amount           float64
+	Status           BookingStatus
+	AuthorizationRef string
+	CaptureRef       string
+	CapturedAmount   float64
+	AuthorizedAt     time.Time
+	// RefundedAmount is what was given back of CapturedAmount; a trip the
+	// user cancelled is only refunded part of it
+	RefundedAmount float64
+}
+
+// BookingConfirmation is what a provider returns for a successful booking;
+// ConfirmedAt is the provider's own timestamp
+type BookingConfirmation struct {
+	BookingRef  string
+	Status      BookingStatus
+	Price       float64
+	ConfirmedAt time.Time
+	// ExpiresAt is when a hold is released unless confirmed; zero for
+	// bookings
+	ExpiresAt time.Time
+	// Alternative is the rank of the alternative this confirms, counting
+	// from 1, or zero for the first choice. The workflow sets it, not the
+	// provider.
+	Alternative int
+}
+
+// Choice returns the hotel to book for rank n: the booking itself for 0,
+// otherwise its nth alternative
+func (b *HotelBooking) Choice(n int) *HotelBooking {
+	if n == 0 {
+		return b
+	}
+	alt := b.Alternatives[n-1]
+	return &HotelBooking{HotelID: alt.HotelID, RoomType: alt.RoomType, Price: alt.Price, CheckIn: b.CheckIn, CheckOut: b.CheckOut}
+}
+
+// Choice returns the flight to book for rank n: the booking itself for 0,
+// otherwise its nth alternative
+func (b *FlightBooking) Choice(n int) *FlightBooking {
+	if n == 0 {
+		return b
+	}
+	alt := b.Alternatives[n-1]
+	return &FlightBooking{FlightNumber: alt.FlightNumber, SeatClass: alt.SeatClass, Price: alt.Price, Departure: alt.Departure, Arrival: alt.Arrival}
+}
+
+// Choice returns the car to book for rank n: the booking itself for 0,
+// otherwise its nth alternative
+func (b *CarBooking) Choice(n int) *CarBooking {
+	if n == 0 {
+		return b
+	}
+	alt := b.Alternatives[n-1]
+	return &CarBooking{CarType: alt.CarType, Price: alt.Price, PickUp: b.PickUp, DropOff: b.DropOff}
+}
+
+// Confirm records a provider confirmation on the hotel booking
+func (b *HotelBooking) Confirm(c BookingConfirmation) {
+	if c.Alternative > 0 {
+		alt := b.Alternatives[c.Alternative-1]
+		b.HotelID, b.RoomType = alt.HotelID, alt.RoomType
+	}
+	b.BookingRef, b.Status, b.Price, b.ConfirmedAt, b.Alternative = c.BookingRef, c.Status, c.Price, c.ConfirmedAt, c.Alternative
+}
+
+// Confirm records a provider confirmation on the flight booking
+func (b *FlightBooking) Confirm(c BookingConfirmation) {
+	if c.Alternative > 0 {
+		alt := b.Alternatives[c.Alternative-1]
+		b.FlightNumber, b.SeatClass, b.Departure, b.Arrival = alt.FlightNumber, alt.SeatClass, alt.Departure, alt.Arrival
+	}
+	b.BookingRef, b.Status, b.Price, b.ConfirmedAt, b.Alternative = c.BookingRef, c.Status, c.Price, c.ConfirmedAt, c.Alternative
+}
+
+// Confirm records a provider confirmation on the car booking
+func (b *CarBooking) Confirm(c BookingConfirmation) {
+	if c.Alternative > 0 {
+		b.CarType = b.Alternatives[c.Alternative-1].CarType
+	}
+	b.BookingRef, b.Status, b.Price, b.ConfirmedAt, b.Alternative = c.BookingRef, c.Status, c.Price, c.ConfirmedAt, c.Alternative
+}
+
+// ErrorKind classifies why a provider call or a request to the booking
+// workflow failed. The values double as
+// Temporal application error types, so they can be listed in a retry
+// policy's non-retryable error types.
+type ErrorKind string
+
+const (
+	// ErrNotAvailable means there is nothing left to book, such as no seats
+	ErrNotAvailable ErrorKind = "NotAvailableError"
+	// ErrInvalidRequest means the provider, or the workflow itself, rejected
+	// the booking details
+	ErrInvalidRequest ErrorKind = "InvalidRequestError"
+	// ErrProviderDown means the provider could not be reached or failed
+	ErrProviderDown ErrorKind = "ProviderDownError"
+	// ErrRateLimited means the provider asked us to slow down
+	ErrRateLimited ErrorKind = "RateLimitedError"
+	// ErrHoldExpired means a hold lapsed or was released before it was
+	// confirmed
+	ErrHoldExpired ErrorKind = "HoldExpiredError"
+	// ErrCircuitOpen means the provider was not called because it has been
+	// failing and its circuit breaker is open
+	ErrCircuitOpen ErrorKind = "CircuitOpenError"
+	// ErrStateConflict means the booking is not in a state that allows the
+	// request, such as cancelling a trip that is still being booked
+	ErrStateConflict ErrorKind = "StateConflictError"
+)
+
+// Retryable reports whether trying the same call again might succeed.
+// Unclassified errors are assumed to be retryable.
+func (k ErrorKind) Retryable() bool {
+	return k != ErrNotAvailable && k != ErrInvalidRequest && k != ErrHoldExpired && k != ErrStateConflict
 }
 
 type BookingError struct {
-	Component  string
-	Message    string
+	Component string
+	Kind      ErrorKind
+	Message   string
+	// RetryCount is how many times the failing activity was attempted, as
+	// the activity recorded it; zero when it is not known
 	RetryCount int
+	// Fatal errors cannot be resolved by retrying and need a person to step in
+	Fatal bool
+}
+
+// NewBookingError returns a classified provider error for component
+func NewBookingError(component string, kind ErrorKind, format string, args ...any) BookingError {
+	return BookingError{
+		Component: component,
+		Kind:      kind,
+		Message:   fmt.Sprintf(format, args...),
+	}
+}
+
+func (e BookingError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Component, e.Message)
+}
+
+// ProviderCancellation is sent by a provider that cancels a confirmed booking
+type ProviderCancellation struct {
+	Component  string
+	BookingRef string
+	Reason     string
+}
+
+// TripCancellation is the user's request to cancel a confirmed trip
+type TripCancellation struct {
+	Reason string
+}
+
+// CancellationBreakdown is what cancelling a trip cost the user. Notice is
+// how long before StartDate the trip was cancelled, which sets RefundRate,
+// the share of each component's price given back.
+type CancellationBreakdown struct {
+	Notice     time.Duration
+	RefundRate float64
+	Components []ComponentRefund
+	// Fees is kept out of the price of the cancelled components
+	Fees float64
+	// Refund is what the user gets back from the captured payment, less the
+	// fees; nothing when the trip was not paid for
+	Refund float64
+}
+
+// ComponentRefund splits the price of one cancelled component into the fee
+// kept and the amount refunded
+type ComponentRefund struct {
+	Component  string
+	BookingRef string
+	Price      float64
+	Fee        float64
+	Refund     float64
 }
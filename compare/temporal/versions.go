@@ -0,0 +1,42 @@
+package main
+
+import (
+	"go.temporal.io/sdk/workflow"
+)
+
+// Change IDs passed to workflow.GetVersion. A travel booking runs until its
+// EndDate, so every deploy finds bookings started on older code that must
+// replay their history unchanged. Each change to the commands the workflow
+// issues is guarded by one of these; the histories in testdata/histories are
+// replayed against the current code by TestReplayHistories to prove it: v1
+// was recorded before any of these changes, v2 with those up to
+// changeTripCheckpoints, v3 with those up to changeTripCancellation and v4
+// with those up to changeBookingValidation.
+const (
+	// changePayment authorizes TotalAmount before the bookings and captures
+	// it after them
+	changePayment = "payment"
+	// changeHotelActivityID runs every hotel attempt as HotelBookingActivityID
+	changeHotelActivityID = "hotel-activity-id"
+	// changeNotifications sends a notification.Message through
+	// SendNotificationActivity instead of a plain email through
+	// SendEmailActivity
+	changeNotifications = "notifications"
+	// changeTripCheckpoints verifies the bookings and reminds the user before
+	// the trip, where older runs only wait for its EndDate
+	changeTripCheckpoints = "trip-checkpoints"
+	// changeTripCancellation runs compensations on a disconnected context, so
+	// they complete when the workflow is cancelled, and unwinds the trip when
+	// the workflow is cancelled while waiting for it or for the user to
+	// accept it without a car
+	changeTripCancellation = "trip-cancellation"
+	// changeBookingValidation fails a run whose booking does not pass
+	// TravelBooking.Validate before anything is booked
+	changeBookingValidation = "booking-validation"
+)
+
+// changed reports whether this run of the workflow includes the change
+// changeID. Every change so far has a single new version.
+func changed(ctx workflow.Context, changeID string) bool {
+	return workflow.GetVersion(ctx, changeID, workflow.DefaultVersion, 1) >= 1
+}
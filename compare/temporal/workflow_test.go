@@ -1,14 +1,34 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 
+	"github.com/leowmjw/go-durable-x/temporal/activities"
+	"github.com/leowmjw/go-durable-x/temporal/codec"
+	"github.com/leowmjw/go-durable-x/temporal/notification"
+	"github.com/leowmjw/go-durable-x/temporal/policy"
+	"github.com/leowmjw/go-durable-x/temporal/scenario"
+	"github.com/leowmjw/go-durable-x/temporal/simulator"
 	"github.com/leowmjw/go-durable-x/temporal/types"
 )
 
@@ -17,10 +37,10 @@ func Test_TravelBookingWorkflow_HappyPath(t *testing.T) {
 	env := testSuite.NewTestWorkflowEnvironment()
 
 	// Mock activities
-	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(nil)
-	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(nil)
-	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(nil)
-	env.OnActivity(SendEmailActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
 
 	booking := types.TravelBooking{
 		BookingID: "TEST-123",
@@ -54,10 +74,10 @@ func Test_TravelBookingWorkflow_FlightFailure(t *testing.T) {
 	env := testSuite.NewTestWorkflowEnvironment()
 
 	// Mock activities
-	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
 	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(
-		fmt.Errorf("flight booking failed"))
-	env.OnActivity(CancelHotelActivity, mock.Anything, mock.Anything).Return(nil)
+		types.BookingConfirmation{}, fmt.Errorf("flight booking failed"))
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil)
 
 	booking := types.TravelBooking{
 		BookingID: "TEST-124",
@@ -86,17 +106,38 @@ func Test_TravelBookingWorkflow_FlightFailure(t *testing.T) {
 	require.Error(t, env.GetWorkflowError())
 }
 
+func Test_TravelBookingWorkflow_InvalidBooking(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	booking := newTestBooking("TEST-194")
+	booking.EndDate = booking.StartDate.Add(-time.Hour)
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	err := env.GetWorkflowError()
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, string(types.ErrInvalidRequest), appErr.Type())
+	require.True(t, appErr.NonRetryable())
+	require.ErrorContains(t, err, "end date must be after start date")
+	// Nothing is charged or booked
+	env.AssertNotCalled(t, "AuthorizePaymentActivity", mock.Anything, mock.Anything)
+	env.AssertNotCalled(t, "BookHotelActivity", mock.Anything, mock.Anything)
+}
+
 func Test_TravelBookingWorkflow_CarFailure(t *testing.T) {
 	testSuite := &testsuite.WorkflowTestSuite{}
 	env := testSuite.NewTestWorkflowEnvironment()
 
 	// Mock activities
-	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(nil)
-	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
 	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
-		fmt.Errorf("car booking failed"))
-	env.OnActivity(CancelHotelActivity, mock.Anything, mock.Anything).Return(nil)
-	env.OnActivity(CancelFlightActivity, mock.Anything, mock.Anything).Return(nil)
+		types.BookingConfirmation{}, fmt.Errorf("car booking failed"))
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil)
+	env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
 
 	booking := types.TravelBooking{
 		BookingID: "TEST-125",
@@ -124,3 +165,2338 @@ func Test_TravelBookingWorkflow_CarFailure(t *testing.T) {
 	require.True(t, env.IsWorkflowCompleted())
 	require.Error(t, env.GetWorkflowError())
 }
+
+// Confirmations returned by the mocked providers
+var (
+	hotelConfirmation  = types.BookingConfirmation{BookingRef: "HTL-1", Status: types.StatusConfirmed, Price: 200.0}
+	flightConfirmation = types.BookingConfirmation{BookingRef: "FLT-1", Status: types.StatusConfirmed, Price: 500.0}
+	carConfirmation    = types.BookingConfirmation{BookingRef: "CAR-1", Status: types.StatusConfirmed, Price: 100.0}
+)
+
+// newTestBooking returns a complete booking for workflow tests
+func newTestBooking(bookingID string) types.TravelBooking {
+	return types.TravelBooking{
+		BookingID: bookingID,
+		UserID:    "user-1",
+		Contact:   types.Contact{Name: "Test User", Email: "user@example.com"},
+		StartDate: time.Now(),
+		EndDate:   time.Now().Add(24 * time.Hour * 7),
+		HotelBooking: &types.HotelBooking{
+			HotelID:  "hotel-1",
+			RoomType: "deluxe",
+			Price:    200.0,
+		},
+		FlightBooking: &types.FlightBooking{
+			FlightNumber: "FL123",
+			SeatClass:    "economy",
+			Price:        500.0,
+		},
+		CarBooking: &types.CarBooking{
+			CarType: "SUV",
+			Price:   100.0,
+		},
+	}
+}
+
+func Test_HotelRetryDelays(t *testing.T) {
+	delays := HotelRetryDelays()
+	require.Len(t, delays, HotelFirstDayRetries+HotelDailyRetryDays)
+
+	var firstDay, total time.Duration
+	for i, d := range delays {
+		if i < HotelFirstDayRetries {
+			firstDay += d
+		}
+		total += d
+	}
+	require.Less(t, firstDay, 24*time.Hour)
+	require.GreaterOrEqual(t, total, 7*24*time.Hour)
+}
+
+func Test_TravelBookingWorkflow_HotelSucceedsAfterRetries(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	// Fail through the first day and into the daily retries
+	failures := HotelFirstDayRetries + 2
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("hotel booking failed: service unavailable")).Times(failures)
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil).Once()
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventUpdated, "Hotel Booking Succeeded")).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventConfirmed, "Travel Booking Confirmed")).Return(nil).Once()
+
+	start := env.Now()
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-126"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	var waited time.Duration
+	for _, d := range HotelRetryDelays()[:failures] {
+		waited += d
+	}
+	require.GreaterOrEqual(t, env.Now().Sub(start), waited)
+}
+
+func Test_TravelBookingWorkflow_HotelRetriesExhausted(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	attempts := len(HotelRetryDelays()) + 1
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("hotel booking failed: service unavailable")).Times(attempts)
+
+	start := env.Now()
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-127"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "BookFlightActivity", mock.Anything, mock.Anything)
+	require.GreaterOrEqual(t, env.Now().Sub(start), 7*24*time.Hour)
+}
+
+func Test_TravelBookingWorkflow_CarFailureApproved(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("car booking failed"))
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventNeedsApproval, "Approval Needed: Travel Booking Without car")).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventPartial, "Travel Booking Confirmed Without Car")).Return(nil).Once()
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalApprovePartialBooking, nil)
+	}, time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-128"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "CancelHotelActivity", mock.Anything, mock.Anything)
+	env.AssertNotCalled(t, "CancelFlightActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_CarFailureRejected(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("car booking failed"))
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalRejectPartialBooking, nil)
+	}, time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-129"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_TravelBookingWorkflow_CarFailureApprovalTimeout(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("car booking failed"))
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventNeedsApproval, "Approval Needed: Travel Booking Without car")).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventNeedsApproval, "Reminder: Approval Needed: Travel Booking Without car")).Return(nil).Once()
+	env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+
+	booking := newTestBooking("TEST-130")
+	booking.ApprovalTimeout = 6 * time.Hour
+
+	// An approval after the deadline is ignored
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalApprovePartialBooking, nil)
+	}, 7*time.Hour)
+
+	start := env.Now()
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	require.GreaterOrEqual(t, env.Now().Sub(start), booking.ApprovalTimeout)
+}
+
+// newFutureTestBooking returns a booking for a trip starting in startIn and
+// lasting a week, relative to the test environment clock
+func newFutureTestBooking(env *testsuite.TestWorkflowEnvironment, bookingID string, startIn time.Duration) types.TravelBooking {
+	booking := newTestBooking(bookingID)
+	booking.StartDate = env.Now().Add(startIn)
+	booking.EndDate = booking.StartDate.Add(24 * time.Hour * 7)
+	return booking
+}
+
+func Test_TravelBookingWorkflow_HotelCancelledByProvider(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(CancelCarActivity, mock.Anything, carConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil).Once()
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+			Component: types.ComponentHotel,
+			Reason:    "overbooked",
+		})
+	}, 24*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-131", 14*24*time.Hour))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "CancelHotelActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_FlightCancelledByProvider(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(CancelCarActivity, mock.Anything, carConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+	mockVerifications(env)
+
+	// Two days before the flight
+	startIn := 5 * 24 * time.Hour
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+			Component: types.ComponentFlight,
+			Reason:    "route withdrawn",
+		})
+	}, startIn-2*24*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-132", startIn))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "CancelFlightActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_CarCancelledByProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		signal   string
+		wantErr  bool
+		wantUndo bool
+	}{
+		{name: "user accepts trip without car", signal: SignalApprovePartialBooking},
+		{name: "user rejects trip without car", signal: SignalRejectPartialBooking, wantErr: true, wantUndo: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+			env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+			env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+			env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventNeedsApproval, "Approval Needed: Travel Booking Without car")).Return(nil).Once()
+			env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+			if tt.wantUndo {
+				env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil).Once()
+				env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+			}
+			mockVerifications(env)
+
+			// On the day of the flight, a few hours before departure
+			startIn := 3 * 24 * time.Hour
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+					Component: types.ComponentCar,
+					Reason:    "vehicle unavailable",
+				})
+			}, startIn-6*time.Hour)
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(tt.signal, nil)
+			}, startIn-5*time.Hour)
+
+			env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-133", startIn))
+
+			require.True(t, env.IsWorkflowCompleted())
+			if tt.wantErr {
+				require.Error(t, env.GetWorkflowError())
+			} else {
+				require.NoError(t, env.GetWorkflowError())
+				env.AssertNotCalled(t, "CancelHotelActivity", mock.Anything, mock.Anything)
+				env.AssertNotCalled(t, "CancelFlightActivity", mock.Anything, mock.Anything)
+			}
+			env.AssertExpectations(t)
+			env.AssertNotCalled(t, "CancelCarActivity", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func Test_TravelBookingWorkflow_CancelledByProviderDuringTrip(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventUpdated, "Travel Booking Changed")).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	startIn := 24 * time.Hour
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+			Component: types.ComponentHotel,
+			Reason:    "flooding",
+		})
+	}, startIn+2*24*time.Hour)
+
+	start := env.Now()
+	booking := newFutureTestBooking(env, "TEST-134", startIn)
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "CancelFlightActivity", mock.Anything, mock.Anything)
+	env.AssertNotCalled(t, "CancelCarActivity", mock.Anything, mock.Anything)
+	require.GreaterOrEqual(t, env.Now().Sub(start), booking.EndDate.Sub(start))
+}
+
+func Test_TravelBookingWorkflow_CompensationFailureReported(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("flight booking failed"))
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(
+		func(ctx context.Context, _ string) error {
+			return attemptErr(ctx, types.ComponentHotel, types.ErrProviderDown, "hotel provider unreachable")
+		}).Times(CompensationRetryMaxAttempts)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalResolveCompensation, "refunded by phone")
+	}, 7*24*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-135"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	err := env.GetWorkflowError()
+	require.Error(t, err)
+	require.ErrorContains(t, err, "flight booking failed")
+	require.ErrorContains(t, err, types.ComponentHotel+": ")
+	env.AssertExpectations(t)
+}
+
+func Test_TravelBookingWorkflow_QueryBookingStatus(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	var during types.TravelBooking
+	env.RegisterDelayedCallback(func() {
+		result, err := env.QueryWorkflow(QueryBookingStatus)
+		require.NoError(t, err)
+		require.NoError(t, result.Get(&during))
+	}, time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-136", 24*time.Hour))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	require.Equal(t, types.StatusConfirmed, during.Status)
+	require.Equal(t, types.StatusConfirmed, during.HotelBooking.Status)
+	require.Equal(t, hotelConfirmation.BookingRef, during.HotelBooking.BookingRef)
+	require.Equal(t, types.StatusConfirmed, during.FlightBooking.Status)
+	require.Equal(t, flightConfirmation.BookingRef, during.FlightBooking.BookingRef)
+	require.Equal(t, types.StatusConfirmed, during.CarBooking.Status)
+	require.Equal(t, carConfirmation.BookingRef, during.CarBooking.BookingRef)
+
+	// Four components go pending, three get booked, then the trip is confirmed
+	require.Len(t, during.AuditLog, 8)
+	last := during.AuditLog[len(during.AuditLog)-1]
+	require.Equal(t, types.ComponentBooking, last.Component)
+	require.Equal(t, types.StatusPending, last.From)
+	require.Equal(t, types.StatusConfirmed, last.To)
+	require.False(t, last.At.IsZero())
+}
+
+// recordingCodec keeps every payload its codec encodes
+type recordingCodec struct {
+	*codec.Codec
+	encoded []*commonpb.Payload
+}
+
+func (r *recordingCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	encoded, err := r.Codec.Encode(payloads)
+	r.encoded = append(r.encoded, encoded...)
+	return encoded, err
+}
+
+func Test_TravelBookingWorkflow_EncryptedPayloads(t *testing.T) {
+	payloads, err := codec.New("test", map[string][]byte{"test": []byte("0123456789abcdef0123456789abcdef")})
+	require.NoError(t, err)
+	recorder := &recordingCodec{Codec: payloads}
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetDataConverter(converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), recorder))
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-173"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	// Activity inputs and results and the query answer all round-trip
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var booking types.TravelBooking
+	require.NoError(t, result.Get(&booking))
+	require.Equal(t, types.StatusCompleted, booking.Status)
+	require.Equal(t, hotelConfirmation.BookingRef, booking.HotelBooking.BookingRef)
+	require.Equal(t, "user@example.com", booking.Contact.Email)
+
+	require.NotEmpty(t, recorder.encoded)
+	for _, p := range recorder.encoded {
+		require.Equal(t, codec.MetadataEncoding, string(p.Metadata[converter.MetadataEncoding]))
+		require.NotContains(t, string(p.Data), "user@example.com")
+		require.NotContains(t, string(p.Data), hotelConfirmation.BookingRef)
+	}
+}
+
+func Test_TravelBookingWorkflow_AuditTrailOnCompensation(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("flight booking failed"))
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-137"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var booking types.TravelBooking
+	require.NoError(t, result.Get(&booking))
+
+	require.Equal(t, types.StatusFailed, booking.Status)
+	require.Equal(t, types.StatusCancelled, booking.HotelBooking.Status)
+	require.Equal(t, types.StatusFailed, booking.FlightBooking.Status)
+	require.Equal(t, types.StatusPending, booking.CarBooking.Status)
+
+	var hotel []types.StateTransition
+	for _, transition := range booking.AuditLog {
+		if transition.Component == types.ComponentHotel {
+			hotel = append(hotel, transition)
+		}
+	}
+	require.Len(t, hotel, 3)
+	require.Equal(t, types.StatusPending, hotel[1].From)
+	require.Equal(t, types.StatusConfirmed, hotel[1].To)
+	require.Equal(t, types.StatusConfirmed, hotel[2].From)
+	require.Equal(t, types.StatusCancelled, hotel[2].To)
+	require.Equal(t, "compensated", hotel[2].Reason)
+}
+
+func Test_TravelBookingWorkflow_CompensationNeedsIntervention(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("car booking failed"))
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(
+		func(ctx context.Context, _ string) error {
+			return attemptErr(ctx, types.ComponentHotel, types.ErrProviderDown, "hotel provider unreachable")
+		}).Times(CompensationRetryMaxAttempts)
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalRejectPartialBooking, nil)
+	}, time.Hour)
+
+	var parked types.TravelBooking
+	env.RegisterDelayedCallback(func() {
+		result, err := env.QueryWorkflow(QueryBookingStatus)
+		require.NoError(t, err)
+		require.NoError(t, result.Get(&parked))
+
+		env.SignalWorkflow(SignalRetryCompensation, nil)
+	}, 3*24*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-138"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	err := env.GetWorkflowError()
+	require.ErrorContains(t, err, "car booking failed")
+	require.NotContains(t, err.Error(), "hotel provider unreachable")
+	env.AssertExpectations(t)
+
+	require.Equal(t, types.StatusNeedsIntervention, parked.Status)
+	require.Equal(t, types.StatusConfirmed, parked.HotelBooking.Status)
+	require.Equal(t, types.StatusCancelled, parked.FlightBooking.Status)
+	// The car failure is recorded first, then the compensation that ran out of retries
+	require.Len(t, parked.Errors, 2)
+	require.Equal(t, types.ComponentCar, parked.Errors[0].Component)
+	require.False(t, parked.Errors[0].Fatal)
+	require.True(t, parked.Errors[1].Fatal)
+	require.Equal(t, types.ComponentHotel, parked.Errors[1].Component)
+	require.Equal(t, CompensationRetryMaxAttempts, parked.Errors[1].RetryCount)
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, types.StatusFailed, final.Status)
+	require.Equal(t, types.StatusCancelled, final.HotelBooking.Status)
+}
+
+func Test_TravelBookingWorkflow_NonRetryableCompensationFailure(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("car booking failed"))
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(
+		func(ctx context.Context, _ string) error {
+			return attemptErr(ctx, types.ComponentHotel, types.ErrInvalidRequest, "unknown booking")
+		}).Once()
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalRejectPartialBooking, nil)
+	}, time.Hour)
+
+	var parked types.TravelBooking
+	env.RegisterDelayedCallback(func() {
+		result, err := env.QueryWorkflow(QueryBookingStatus)
+		require.NoError(t, err)
+		require.NoError(t, result.Get(&parked))
+
+		env.SignalWorkflow(SignalResolveCompensation, "cancelled with the hotel by phone")
+	}, 3*24*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-139"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	require.Equal(t, types.StatusNeedsIntervention, parked.Status)
+	require.Len(t, parked.Errors, 2)
+	require.True(t, parked.Errors[1].Fatal)
+	require.Equal(t, types.ComponentHotel, parked.Errors[1].Component)
+	require.Equal(t, 1, parked.Errors[1].RetryCount)
+}
+
+func Test_TravelBookingWorkflow_BookingModes(t *testing.T) {
+	tests := []struct {
+		name string
+		mode types.BookingMode
+		// Whether the trip is confirmed 90 minutes in, with each provider taking an hour
+		wantConfirmed bool
+	}{
+		{name: "sequential", mode: types.ModeSequential, wantConfirmed: false},
+		{name: "parallel", mode: types.ModeParallel, wantConfirmed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil).After(time.Hour)
+			env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil).After(time.Hour)
+			env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil).After(time.Hour)
+			env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+			var during types.TravelBooking
+			env.RegisterDelayedCallback(func() {
+				result, err := env.QueryWorkflow(QueryBookingStatus)
+				require.NoError(t, err)
+				require.NoError(t, result.Get(&during))
+			}, 90*time.Minute)
+
+			booking := newFutureTestBooking(env, "TEST-139", 24*time.Hour)
+			booking.Mode = tt.mode
+			env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+			require.True(t, env.IsWorkflowCompleted())
+			require.NoError(t, env.GetWorkflowError())
+			require.Equal(t, tt.wantConfirmed, during.Status == types.StatusConfirmed)
+		})
+	}
+}
+
+func Test_TravelBookingWorkflow_ParallelFlightFailure(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("flight booking failed"))
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelCarActivity, mock.Anything, carConfirmation.BookingRef).Return(nil).Once()
+
+	booking := newTestBooking("TEST-140")
+	booking.Mode = types.ModeParallel
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "flight booking failed")
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "CancelFlightActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_ParallelHotelAndCarFailure(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("hotel booking failed"))
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("car booking failed"))
+	env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil).Once()
+
+	booking := newTestBooking("TEST-141")
+	booking.Mode = types.ModeParallel
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	err := env.GetWorkflowError()
+	require.ErrorContains(t, err, "hotel booking failed")
+	require.ErrorContains(t, err, "car booking failed")
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "CancelHotelActivity", mock.Anything, mock.Anything)
+	env.AssertNotCalled(t, "CancelCarActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_ParallelCarFailureApproved(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("car booking failed"))
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventPartial, "Travel Booking Confirmed Without Car")).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalApprovePartialBooking, nil)
+	}, time.Hour)
+
+	booking := newTestBooking("TEST-142")
+	booking.Mode = types.ModeParallel
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+var (
+	hotelHold  = types.BookingConfirmation{BookingRef: "HTL-1", Status: types.StatusHeld, Price: 200.0}
+	flightHold = types.BookingConfirmation{BookingRef: "FLT-1", Status: types.StatusHeld, Price: 500.0}
+	carHold    = types.BookingConfirmation{BookingRef: "CAR-1", Status: types.StatusHeld, Price: 100.0}
+)
+
+// mockHolds holds every component and confirms every hold
+func mockHolds(env *testsuite.TestWorkflowEnvironment) {
+	env.OnActivity(HoldHotelActivity, mock.Anything, mock.Anything, mock.Anything).Return(hotelHold, nil)
+	env.OnActivity(HoldFlightActivity, mock.Anything, mock.Anything, mock.Anything).Return(flightHold, nil)
+	env.OnActivity(HoldCarActivity, mock.Anything, mock.Anything, mock.Anything).Return(carHold, nil)
+	env.OnActivity(ConfirmHotelActivity, mock.Anything, hotelHold.BookingRef).Return(hotelConfirmation, nil)
+	env.OnActivity(ConfirmFlightActivity, mock.Anything, flightHold.BookingRef).Return(flightConfirmation, nil)
+	env.OnActivity(ConfirmCarActivity, mock.Anything, carHold.BookingRef).Return(carConfirmation, nil)
+}
+
+func Test_TravelBookingWorkflow_TCCHappyPath(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	mockHolds(env)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	var during types.TravelBooking
+	env.RegisterDelayedCallback(func() {
+		result, err := env.QueryWorkflow(QueryBookingStatus)
+		require.NoError(t, err)
+		require.NoError(t, result.Get(&during))
+	}, time.Hour)
+
+	booking := newFutureTestBooking(env, "TEST-174", 24*time.Hour)
+	booking.Mode = types.ModeTCC
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, types.StatusConfirmed, during.Status)
+	for _, component := range []string{types.ComponentHotel, types.ComponentFlight, types.ComponentCar} {
+		var transitions []types.StateTransition
+		for _, transition := range during.AuditLog {
+			if transition.Component == component {
+				transitions = append(transitions, transition)
+			}
+		}
+		require.Len(t, transitions, 3, component)
+		require.Equal(t, types.StatusHeld, transitions[1].To, component)
+		require.Equal(t, types.StatusHeld, transitions[2].From, component)
+		require.Equal(t, types.StatusConfirmed, transitions[2].To, component)
+	}
+	env.AssertNotCalled(t, "BookHotelActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_TCCHoldFailureReleasesHolds(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(HoldHotelActivity, mock.Anything, mock.Anything, mock.Anything).Return(hotelHold, nil)
+	env.OnActivity(HoldFlightActivity, mock.Anything, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, providerErr(types.ComponentFlight, types.ErrNotAvailable, "no seats left"))
+	env.OnActivity(HoldCarActivity, mock.Anything, mock.Anything, mock.Anything).Return(carHold, nil)
+	env.OnActivity(ReleaseHotelActivity, mock.Anything, hotelHold.BookingRef).Return(nil).Once()
+	env.OnActivity(ReleaseCarActivity, mock.Anything, carHold.BookingRef).Return(nil).Once()
+
+	booking := newTestBooking("TEST-175")
+	booking.Mode = types.ModeTCC
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "no seats left")
+	env.AssertExpectations(t)
+	for _, activity := range []string{"ConfirmHotelActivity", "ConfirmCarActivity", "CancelHotelActivity", "CancelCarActivity", "ReleaseFlightActivity"} {
+		env.AssertNotCalled(t, activity, mock.Anything, mock.Anything)
+	}
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, types.StatusFailed, final.Status)
+	require.Equal(t, types.StatusReleased, final.HotelBooking.Status)
+	require.Equal(t, types.StatusFailed, final.FlightBooking.Status)
+	require.Equal(t, types.StatusReleased, final.CarBooking.Status)
+}
+
+func Test_TravelBookingWorkflow_TCCHoldsExpireBeforeAllPlaced(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(HoldHotelActivity, mock.Anything, mock.Anything, 5*time.Minute).Return(hotelHold, nil)
+	env.OnActivity(HoldFlightActivity, mock.Anything, mock.Anything, 5*time.Minute).Return(flightHold, nil)
+	env.OnActivity(HoldCarActivity, mock.Anything, mock.Anything, 5*time.Minute).Return(carHold, nil).After(time.Hour)
+	env.OnActivity(ReleaseHotelActivity, mock.Anything, hotelHold.BookingRef).Return(nil).Once()
+	env.OnActivity(ReleaseFlightActivity, mock.Anything, flightHold.BookingRef).Return(nil).Once()
+
+	booking := newTestBooking("TEST-176")
+	booking.Mode = types.ModeTCC
+	booking.HoldTimeout = 5 * time.Minute
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "car hold not in place within 5m0s")
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "ConfirmHotelActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_TCCConfirmFailureCancelsConfirmed(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(ConfirmFlightActivity, mock.Anything, flightHold.BookingRef).Return(
+		types.BookingConfirmation{}, providerErr(types.ComponentFlight, types.ErrHoldExpired, "hold expired"))
+	mockHolds(env)
+	env.OnActivity(ReleaseFlightActivity, mock.Anything, flightHold.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelCarActivity, mock.Anything, carConfirmation.BookingRef).Return(nil).Once()
+
+	booking := newTestBooking("TEST-177")
+	booking.Mode = types.ModeTCC
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "hold expired")
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "ReleaseHotelActivity", mock.Anything, mock.Anything)
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, types.StatusCancelled, final.HotelBooking.Status)
+	require.Equal(t, types.StatusFailed, final.FlightBooking.Status)
+	require.Equal(t, types.StatusCancelled, final.CarBooking.Status)
+}
+
+func Test_TravelBookingWorkflow_CompensationByMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         types.BookingMode
+		wantCancels  int
+		wantReleases int
+	}{
+		// Only the hotel is booked before the flight fails
+		{name: "sequential", mode: types.ModeSequential, wantCancels: 1},
+		// Hotel and car are both booked by the time the flight fails
+		{name: "parallel", mode: types.ModeParallel, wantCancels: 2},
+		// Hotel and car are only ever held, so nothing needs cancelling
+		{name: "tcc", mode: types.ModeTCC, wantReleases: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			noSeats := providerErr(types.ComponentFlight, types.ErrNotAvailable, "no seats left")
+			env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+			env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{}, noSeats)
+			env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+			env.OnActivity(HoldHotelActivity, mock.Anything, mock.Anything, mock.Anything).Return(hotelHold, nil)
+			env.OnActivity(HoldFlightActivity, mock.Anything, mock.Anything, mock.Anything).Return(types.BookingConfirmation{}, noSeats)
+			env.OnActivity(HoldCarActivity, mock.Anything, mock.Anything, mock.Anything).Return(carHold, nil)
+
+			var cancels, releases int
+			undo := func(count *int) func(context.Context, string) error {
+				return func(context.Context, string) error {
+					*count++
+					return nil
+				}
+			}
+			env.OnActivity(CancelHotelActivity, mock.Anything, mock.Anything).Return(undo(&cancels))
+			env.OnActivity(CancelCarActivity, mock.Anything, mock.Anything).Return(undo(&cancels))
+			env.OnActivity(ReleaseHotelActivity, mock.Anything, mock.Anything).Return(undo(&releases))
+			env.OnActivity(ReleaseCarActivity, mock.Anything, mock.Anything).Return(undo(&releases))
+
+			booking := newTestBooking("TEST-178")
+			booking.Mode = tt.mode
+			env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+			require.True(t, env.IsWorkflowCompleted())
+			require.ErrorContains(t, env.GetWorkflowError(), "no seats left")
+			require.Equal(t, tt.wantCancels, cancels, "bookings cancelled")
+			require.Equal(t, tt.wantReleases, releases, "holds released")
+		})
+	}
+}
+
+// hotelID matches hotel bookings for the given hotel
+func hotelID(id string) any {
+	return mock.MatchedBy(func(b *types.HotelBooking) bool { return b.HotelID == id })
+}
+
+func Test_TravelBookingWorkflow_HotelAlternativeBooked(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	var activityIDs []string
+	env.SetOnActivityStartedListener(func(info *activity.Info, _ context.Context, _ converter.EncodedValues) {
+		if info.ActivityType.Name == "BookHotelActivity" {
+			activityIDs = append(activityIDs, info.ActivityID)
+		}
+	})
+	env.OnActivity(BookHotelActivity, mock.Anything, hotelID("hotel-1")).Return(
+		types.BookingConfirmation{}, providerErr(types.ComponentHotel, types.ErrNotAvailable, "no rooms left")).Once()
+	env.OnActivity(BookHotelActivity, mock.Anything, hotelID("hotel-3")).Return(
+		types.BookingConfirmation{BookingRef: "HTL-3", Status: types.StatusConfirmed, Price: 240}, nil).Once()
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	var confirmed notification.Message
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventConfirmed, "Travel Booking Confirmed")).Return(
+		func(_ context.Context, msg notification.Message) error {
+			confirmed = msg
+			return nil
+		})
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	var during types.TravelBooking
+	env.RegisterDelayedCallback(func() {
+		result, err := env.QueryWorkflow(QueryBookingStatus)
+		require.NoError(t, err)
+		require.NoError(t, result.Get(&during))
+	}, time.Hour)
+
+	booking := newFutureTestBooking(env, "TEST-179", 24*time.Hour)
+	booking.HotelBooking.MaxPrice = 250
+	booking.HotelBooking.Alternatives = []types.HotelAlternative{
+		{HotelID: "hotel-2", RoomType: "suite", Price: 400},
+		{HotelID: "hotel-3", RoomType: "standard", Price: 240},
+		{HotelID: "hotel-4", RoomType: "standard", Price: 180},
+	}
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	// hotel-2 is over budget, and hotel-4 is never needed
+	require.Equal(t, []string{HotelBookingActivityID, HotelBookingActivityID + "-2"}, activityIDs)
+
+	require.Equal(t, types.StatusConfirmed, during.Status)
+	hotel := during.HotelBooking
+	require.Equal(t, 2, hotel.Alternative)
+	require.Equal(t, "hotel-3", hotel.HotelID)
+	require.Equal(t, "standard", hotel.RoomType)
+	require.Equal(t, 240.0, hotel.Price)
+	require.Equal(t, "HTL-3", hotel.BookingRef)
+
+	var reasons []string
+	for _, transition := range during.AuditLog {
+		if transition.Component == types.ComponentHotel {
+			reasons = append(reasons, transition.Reason)
+		}
+	}
+	require.Contains(t, reasons, "booked as HTL-3 (alternative 2)")
+	require.Contains(t, confirmed.Detail, "we booked hotel-3 instead")
+}
+
+func Test_TravelBookingWorkflow_AlternativesOnlyWhenNotAvailable(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      types.ErrorKind
+		wantTries int
+	}{
+		// Both flights are full, so the trip fails after trying each once
+		{name: "not available", kind: types.ErrNotAvailable, wantTries: 2},
+		// A provider that is down is down for every flight; only the first
+		// choice is retried
+		{name: "provider down", kind: types.ErrProviderDown, wantTries: RetryMaxAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			var flights []string
+			env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+			env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(
+				func(_ context.Context, b *types.FlightBooking) (types.BookingConfirmation, error) {
+					flights = append(flights, b.FlightNumber)
+					return types.BookingConfirmation{}, providerErr(types.ComponentFlight, tt.kind, "flight booking failed")
+				})
+			env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+
+			booking := newTestBooking("TEST-180")
+			booking.FlightBooking.Alternatives = []types.FlightAlternative{{FlightNumber: "FL456", SeatClass: "economy", Price: 550}}
+			env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+			require.True(t, env.IsWorkflowCompleted())
+			require.ErrorContains(t, env.GetWorkflowError(), "flight booking failed")
+			env.AssertExpectations(t)
+			require.Len(t, flights, tt.wantTries)
+			require.Equal(t, "FL123", flights[0])
+			if tt.kind == types.ErrNotAvailable {
+				require.Equal(t, "FL456", flights[1])
+			} else {
+				require.NotContains(t, flights, "FL456")
+			}
+		})
+	}
+}
+
+func Test_TravelBookingWorkflow_TCCHoldsAlternative(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	suv := mock.MatchedBy(func(b *types.CarBooking) bool { return b.CarType == "SUV" })
+	sedan := mock.MatchedBy(func(b *types.CarBooking) bool { return b.CarType == "sedan" })
+	env.OnActivity(HoldCarActivity, mock.Anything, suv, mock.Anything).Return(
+		types.BookingConfirmation{}, providerErr(types.ComponentCar, types.ErrNotAvailable, "no cars left")).Once()
+	env.OnActivity(HoldCarActivity, mock.Anything, sedan, mock.Anything).Return(
+		types.BookingConfirmation{BookingRef: "CAR-2", Status: types.StatusHeld, Price: 80}, nil).Once()
+	env.OnActivity(ConfirmCarActivity, mock.Anything, "CAR-2").Return(
+		types.BookingConfirmation{BookingRef: "CAR-2", Status: types.StatusConfirmed, Price: 80}, nil).Once()
+	mockHolds(env)
+
+	booking := newTestBooking("TEST-181")
+	booking.Mode = types.ModeTCC
+	booking.CarBooking.Alternatives = []types.CarAlternative{{CarType: "sedan", Price: 80}}
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertNotCalled(t, "ConfirmCarActivity", mock.Anything, carHold.BookingRef)
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, "sedan", final.CarBooking.CarType)
+	require.Equal(t, 1, final.CarBooking.Alternative)
+	require.Equal(t, "CAR-2", final.CarBooking.BookingRef)
+}
+
+// updateOutcome records what happened to an update sent through the test
+// environment
+type updateOutcome struct {
+	rejected  error
+	completed bool
+	result    any
+	err       error
+}
+
+func (u *updateOutcome) Accept()          {}
+func (u *updateOutcome) Reject(err error) { u.rejected = err }
+func (u *updateOutcome) Complete(success any, err error) {
+	u.completed, u.result, u.err = true, success, err
+}
+
+// cancelTripAfter sends the cancel-trip update after delay
+func cancelTripAfter(env *testsuite.TestWorkflowEnvironment, delay time.Duration, reason string) *updateOutcome {
+	outcome := &updateOutcome{}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(UpdateCancelTrip, "cancel-"+reason, outcome, types.TripCancellation{Reason: reason})
+	}, delay)
+	return outcome
+}
+
+// mockCancellations records the order components are cancelled in
+func mockCancellations(env *testsuite.TestWorkflowEnvironment, cancelled *[]string) {
+	for component, cancel := range map[string]any{
+		types.ComponentHotel:  CancelHotelActivity,
+		types.ComponentFlight: CancelFlightActivity,
+		types.ComponentCar:    CancelCarActivity,
+	} {
+		env.OnActivity(cancel, mock.Anything, mock.Anything).Return(func(context.Context, string) error {
+			*cancelled = append(*cancelled, component)
+			return nil
+		})
+	}
+}
+
+func Test_TravelBookingWorkflow_CancelTripByUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		notice     time.Duration
+		wantRate   float64
+		wantFees   float64
+		wantRefund float64
+		// unpaid trips have no TotalAmount, so nothing is charged
+		unpaid bool
+	}{
+		{name: "a month ahead", notice: 30 * 24 * time.Hour, wantRate: 1, wantFees: 0, wantRefund: 1000},
+		{name: "ten days ahead without payment", notice: 10 * 24 * time.Hour, wantRate: 0.5, wantFees: 400, wantRefund: 0, unpaid: true},
+		{name: "ten days ahead", notice: 10 * 24 * time.Hour, wantRate: 0.5, wantFees: 400, wantRefund: 600},
+		{name: "three days ahead", notice: 3 * 24 * time.Hour, wantRate: 0.25, wantFees: 600, wantRefund: 400},
+		{name: "the day before", notice: 24 * time.Hour, wantRate: 0, wantFees: 800, wantRefund: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+			env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+			env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+			env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+			var cancelled []string
+			mockCancellations(env, &cancelled)
+			gateway := activities.NewPaymentProvider()
+			var keys []string
+			mockPayments(env, gateway, &keys)
+
+			booking := newFutureTestBooking(env, "TEST-182", tt.notice+time.Hour)
+			// 1000 charged for 800 of bookings, so the refund comes from the
+			// payment rather than the prices
+			if !tt.unpaid {
+				booking.TotalAmount = 1000
+			}
+			outcome := cancelTripAfter(env, time.Hour, "change of plans")
+			env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+			require.True(t, env.IsWorkflowCompleted())
+			require.NoError(t, env.GetWorkflowError())
+			require.NoError(t, outcome.rejected)
+			require.True(t, outcome.completed)
+			require.NoError(t, outcome.err)
+			breakdown, ok := outcome.result.(types.CancellationBreakdown)
+			require.True(t, ok)
+			require.Equal(t, tt.notice, breakdown.Notice)
+			require.Equal(t, tt.wantRate, breakdown.RefundRate)
+			require.Equal(t, tt.wantFees, breakdown.Fees)
+			require.Equal(t, tt.wantRefund, breakdown.Refund)
+			require.Len(t, breakdown.Components, 3)
+			require.Equal(t, types.ComponentRefund{
+				Component:  types.ComponentFlight,
+				BookingRef: flightConfirmation.BookingRef,
+				Price:      500,
+				Fee:        500 * (1 - tt.wantRate),
+				Refund:     500 * tt.wantRate,
+			}, breakdown.Components[1])
+
+			// Cancelled in the reverse of the order booked, then refunded
+			require.Equal(t, []string{types.ComponentCar, types.ComponentFlight, types.ComponentHotel}, cancelled)
+
+			result, err := env.QueryWorkflow(QueryBookingStatus)
+			require.NoError(t, err)
+			var final types.TravelBooking
+			require.NoError(t, result.Get(&final))
+			require.Equal(t, types.StatusCancelled, final.Status)
+			require.Equal(t, "cancelled by user: change of plans", final.AuditLog[len(final.AuditLog)-1].Reason)
+			if tt.unpaid {
+				require.Empty(t, keys, "nothing was taken, so nothing is refunded")
+				return
+			}
+			require.Equal(t, "default-test-workflow-id/default-test-run-id/payment-cancel-refund", keys[len(keys)-1])
+			require.Equal(t, types.StatusRefunded, final.Payment.Status)
+			require.Equal(t, tt.wantRefund, final.Payment.RefundedAmount)
+		})
+	}
+}
+
+func Test_TravelBookingWorkflow_CancelTripRejected(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil).After(time.Hour)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	// Still booking, then already travelling
+	whileBooking := cancelTripAfter(env, 30*time.Minute, "too early")
+	duringTrip := cancelTripAfter(env, 3*24*time.Hour, "too late")
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-183", 48*time.Hour))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.ErrorContains(t, whileBooking.rejected, "only a confirmed trip can be cancelled; booking is PENDING")
+	require.ErrorContains(t, duringTrip.rejected, "trip has already started")
+	env.AssertNotCalled(t, "CancelHotelActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_CancelTripOutlivedByTrip(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+	var cancelled []string
+	mockCancellations(env, &cancelled)
+
+	// The user cancels while the workflow waits on them to accept a trip
+	// without the car the provider cancelled, then rejects that trip, which
+	// unwinds it before the cancellation is taken
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+			Component:  types.ComponentCar,
+			BookingRef: carConfirmation.BookingRef,
+			Reason:     "fleet recalled",
+		})
+	}, time.Hour)
+	outcome := cancelTripAfter(env, 2*time.Hour, "change of plans")
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalRejectPartialBooking, nil)
+	}, 3*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-184", 30*24*time.Hour))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	require.NoError(t, outcome.rejected)
+	require.True(t, outcome.completed, "the update is answered before the workflow completes")
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, outcome.err, &appErr)
+	require.Equal(t, string(types.ErrStateConflict), appErr.Type())
+	require.ErrorContains(t, outcome.err, "trip was not cancelled; booking is CANCELLED")
+	require.Equal(t, []string{types.ComponentFlight, types.ComponentHotel}, cancelled)
+}
+
+func Test_TravelBookingWorkflow_WorkflowCancelledCancelsTrip(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+	var cancelled []string
+	mockCancellations(env, &cancelled)
+
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Hour)
+	env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-184", 30*24*time.Hour))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.True(t, temporal.IsCanceledError(env.GetWorkflowError()))
+	require.Equal(t, []string{types.ComponentCar, types.ComponentFlight, types.ComponentHotel}, cancelled)
+}
+
+func Test_TravelBookingWorkflow_CompensationSurvivesWorkflowCancellation(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, providerErr(types.ComponentFlight, types.ErrNotAvailable, "no seats left"))
+	// The workflow is cancelled while the hotel is being cancelled
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).After(time.Hour).Once()
+	env.RegisterDelayedCallback(env.CancelWorkflow, 30*time.Minute)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-185"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "no seats left")
+	env.AssertExpectations(t)
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, types.StatusCancelled, final.HotelBooking.Status)
+	require.Equal(t, types.StatusFailed, final.Status)
+}
+
+func Test_TravelBookingWorkflow_CancelledAwaitingApproval(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(env *testsuite.TestWorkflowEnvironment)
+		cancelAfter time.Duration
+		wantPayment types.BookingStatus
+	}{
+		{
+			name: "car failed while booking",
+			setup: func(env *testsuite.TestWorkflowEnvironment) {
+				env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+					types.BookingConfirmation{}, providerErr(types.ComponentCar, types.ErrNotAvailable, "no cars left"))
+			},
+			cancelAfter: time.Hour,
+			wantPayment: types.StatusVoided,
+		},
+		{
+			name: "car cancelled by provider",
+			setup: func(env *testsuite.TestWorkflowEnvironment) {
+				env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+				env.RegisterDelayedCallback(func() {
+					env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+						Component: types.ComponentCar,
+						Reason:    "vehicle unavailable",
+					})
+				}, 24*time.Hour)
+			},
+			cancelAfter: 25 * time.Hour,
+			wantPayment: types.StatusRefunded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+			env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+			env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+			tt.setup(env)
+			var cancelled []string
+			mockCancellations(env, &cancelled)
+			gateway := activities.NewPaymentProvider()
+			var keys []string
+			mockPayments(env, gateway, &keys)
+
+			// The user has not answered when the workflow is cancelled
+			env.RegisterDelayedCallback(env.CancelWorkflow, tt.cancelAfter)
+			booking := newFutureTestBooking(env, "TEST-192", 14*24*time.Hour)
+			booking.TotalAmount = 800
+			env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+			require.True(t, env.IsWorkflowCompleted())
+			require.True(t, temporal.IsCanceledError(env.GetWorkflowError()))
+			require.Equal(t, []string{types.ComponentFlight, types.ComponentHotel}, cancelled)
+
+			result, err := env.QueryWorkflow(QueryBookingStatus)
+			require.NoError(t, err)
+			var final types.TravelBooking
+			require.NoError(t, result.Get(&final))
+			require.Equal(t, types.StatusFailed, final.Status)
+			require.Equal(t, tt.wantPayment, final.Payment.Status)
+		})
+	}
+}
+
+func Test_TravelBookingWorkflow_ActivityPolicies(t *testing.T) {
+	policies, err := policy.ParseYAML([]byte(`
+activities:
+  BookHotelActivity:
+    retry:
+      maximum_attempts: 5
+  BookFlightActivity:
+    retry:
+      maximum_attempts: 1
+  CancelHotelActivity:
+    retry:
+      maximum_attempts: 2
+`))
+	require.NoError(t, err)
+	require.NoError(t, policies.Validate(activityNames()...))
+	activityPolicies = policies
+	t.Cleanup(func() { activityPolicies = nil })
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	// The hotel's own schedule, not the file's retries, books it on the
+	// second attempt, so the user hears it succeeded after a retry
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("hotel provider unreachable")).Once()
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventUpdated, "Hotel Booking Succeeded")).Return(nil).Once()
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, fmt.Errorf("flight booking failed")).Once()
+	// Compensations keep compensationRetryPolicy: the file's two attempts
+	// would give up before the third succeeds
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(
+		fmt.Errorf("hotel provider unreachable")).Twice()
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-143"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "flight booking failed")
+	env.AssertExpectations(t)
+}
+
+// providerErr builds the application error an activity returns for a
+// classified provider failure
+func providerErr(component string, kind types.ErrorKind, message string) error {
+	return applicationErr(types.NewBookingError(component, kind, "%s", message))
+}
+
+// attemptErr is providerErr as returned by the attempt of the activity
+// running in ctx, which it records
+func attemptErr(ctx context.Context, component string, kind types.ErrorKind, message string) error {
+	bookingErr := types.NewBookingError(component, kind, "%s", message)
+	bookingErr.RetryCount = int(activity.GetInfo(ctx).Attempt)
+	return applicationErr(bookingErr)
+}
+
+func applicationErr(bookingErr types.BookingError) error {
+	kind := bookingErr.Kind
+	if kind.Retryable() {
+		return temporal.NewApplicationError(bookingErr.Error(), string(kind), bookingErr)
+	}
+	return temporal.NewNonRetryableApplicationError(bookingErr.Error(), string(kind), nil, bookingErr)
+}
+
+func Test_TravelBookingWorkflow_HotelNotAvailableFailsFast(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{},
+		providerErr(types.ComponentHotel, types.ErrNotAvailable, "no rooms left")).Once()
+
+	start := env.Now()
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-144"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "no rooms left")
+	env.AssertExpectations(t)
+	require.Less(t, env.Now().Sub(start), time.Hour)
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var booking types.TravelBooking
+	require.NoError(t, result.Get(&booking))
+	require.Len(t, booking.Errors, 1)
+	require.Equal(t, types.ErrNotAvailable, booking.Errors[0].Kind)
+	require.Equal(t, "no rooms left", booking.Errors[0].Message)
+}
+
+func Test_TravelBookingWorkflow_HotelProviderDownKeepsRetrying(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{},
+		providerErr(types.ComponentHotel, types.ErrProviderDown, "service unavailable")).Times(2)
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil).Once()
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-145"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_TravelBookingWorkflow_ErrorRetryability(t *testing.T) {
+	tests := []struct {
+		kind         types.ErrorKind
+		wantAttempts int
+	}{
+		{kind: types.ErrNotAvailable, wantAttempts: 1},
+		{kind: types.ErrInvalidRequest, wantAttempts: 1},
+		{kind: types.ErrProviderDown, wantAttempts: RetryMaxAttempts},
+		{kind: types.ErrRateLimited, wantAttempts: RetryMaxAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+			env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{},
+				providerErr(types.ComponentFlight, tt.kind, "flight booking failed")).Times(tt.wantAttempts)
+			env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+
+			env.ExecuteWorkflow(TravelBookingWorkflow, newTestBooking("TEST-146"))
+
+			require.True(t, env.IsWorkflowCompleted())
+			require.Error(t, env.GetWorkflowError())
+			env.AssertExpectations(t)
+
+			var appErr *temporal.ApplicationError
+			require.ErrorAs(t, env.GetWorkflowError(), &appErr)
+			require.Equal(t, string(tt.kind), appErr.Type())
+		})
+	}
+}
+
+// discardLogger keeps activities run inside tests quiet
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newTestSimulator starts a provider simulator for the test and returns a
+// client for it
+// mockVerifications has the providers confirm every booking still stands
+// when the workflow checks before the trip
+func mockVerifications(env *testsuite.TestWorkflowEnvironment) {
+	stillConfirmed := types.BookingConfirmation{Status: types.StatusConfirmed}
+	env.OnActivity(VerifyHotelActivity, mock.Anything, mock.Anything).Return(stillConfirmed, nil).Maybe()
+	env.OnActivity(VerifyFlightActivity, mock.Anything, mock.Anything).Return(stillConfirmed, nil).Maybe()
+	env.OnActivity(VerifyCarActivity, mock.Anything, mock.Anything).Return(stillConfirmed, nil).Maybe()
+}
+
+// notice matches a notification of event with subject
+func notice(event notification.Event, subject string) any {
+	return mock.MatchedBy(func(msg notification.Message) bool {
+		return msg.Event == event && msg.Subject == subject
+	})
+}
+
+func newTestSimulator(t *testing.T) *simulator.Client {
+	server := httptest.NewServer(simulator.NewServer(discardLogger).Handler())
+	t.Cleanup(server.Close)
+	return simulator.NewClient(server.URL, server.Client())
+}
+
+// mockPayments routes the payment activities to a fake gateway and records
+// the idempotency key of every call. The keys show which payment activities
+// ran, so none of them are required.
+func mockPayments(env *testsuite.TestWorkflowEnvironment, gateway *activities.PaymentProvider, keys *[]string) {
+	env.OnActivity(AuthorizePaymentActivity, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, key string, amount float64) (types.BookingConfirmation, error) {
+			*keys = append(*keys, key)
+			return gateway.Authorize(key, amount)
+		}).Maybe()
+	env.OnActivity(CapturePaymentActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, key, authorizationRef string, amount float64) (types.BookingConfirmation, error) {
+			*keys = append(*keys, key)
+			return gateway.Capture(key, authorizationRef, amount)
+		}).Maybe()
+	env.OnActivity(VoidPaymentActivity, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, key, authorizationRef string) error {
+			*keys = append(*keys, key)
+			_, err := gateway.Void(key, authorizationRef)
+			return err
+		}).Maybe()
+	env.OnActivity(RefundPaymentActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, key, authorizationRef string, amount float64) error {
+			*keys = append(*keys, key)
+			_, err := gateway.Refund(key, authorizationRef, amount)
+			return err
+		}).Maybe()
+}
+
+// queryBooking returns the booking as the workflow sees it after delay
+func queryBooking(t *testing.T, env *testsuite.TestWorkflowEnvironment, delay time.Duration) *types.TravelBooking {
+	var booking types.TravelBooking
+	env.RegisterDelayedCallback(func() {
+		result, err := env.QueryWorkflow(QueryBookingStatus)
+		require.NoError(t, err)
+		require.NoError(t, result.Get(&booking))
+	}, delay)
+	return &booking
+}
+
+// auditIndex returns the position of the first transition of component to
+// status in the audit log, or -1
+func auditIndex(booking *types.TravelBooking, component string, to types.BookingStatus) int {
+	return slices.IndexFunc(booking.AuditLog, func(t types.StateTransition) bool {
+		return t.Component == component && t.To == to
+	})
+}
+
+func Test_TravelBookingWorkflow_PaymentCapturedAfterBookings(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	gateway := activities.NewPaymentProvider()
+	var keys []string
+	// The first capture goes through but its response is lost, so the
+	// activity is retried with the same key
+	env.OnActivity(CapturePaymentActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, key, authorizationRef string, amount float64) (types.BookingConfirmation, error) {
+			keys = append(keys, key)
+			// The same key the provider activities build for themselves
+			require.Equal(t, activities.IdempotencyKey(ctx), key)
+			if _, err := gateway.Capture(key, authorizationRef, amount); err != nil {
+				return types.BookingConfirmation{}, err
+			}
+			return types.BookingConfirmation{}, errors.New("capture response lost")
+		}).Once()
+	mockPayments(env, gateway, &keys)
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	booking := newFutureTestBooking(env, "TEST-150", 24*time.Hour)
+	booking.TotalAmount = 800
+	during := queryBooking(t, env, time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	payment := during.Payment
+	require.Equal(t, types.StatusCaptured, payment.Status)
+	require.Equal(t, 800.0, payment.CapturedAmount)
+	require.Equal(t, 1, gateway.Captures(payment.AuthorizationRef))
+	require.Zero(t, gateway.DuplicateCaptures(payment.AuthorizationRef), "retried capture must not charge twice")
+	require.Equal(t, []string{
+		"default-test-workflow-id/default-test-run-id/payment-authorize",
+		"default-test-workflow-id/default-test-run-id/payment-capture",
+		"default-test-workflow-id/default-test-run-id/payment-capture",
+	}, keys)
+
+	// Authorized before anything is booked, captured after everything is
+	authorized := auditIndex(during, types.ComponentPayment, types.StatusAuthorized)
+	captured := auditIndex(during, types.ComponentPayment, types.StatusCaptured)
+	require.Less(t, authorized, auditIndex(during, types.ComponentHotel, types.StatusConfirmed))
+	require.Greater(t, captured, auditIndex(during, types.ComponentCar, types.StatusConfirmed))
+}
+
+func Test_TravelBookingWorkflow_PaymentReleasedOnCompensation(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(env *testsuite.TestWorkflowEnvironment)
+		startIn     time.Duration
+		wantKeys    []string
+		wantPayment types.BookingStatus
+	}{
+		{
+			name: "hotel not available",
+			setup: func(env *testsuite.TestWorkflowEnvironment) {
+				env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(
+					types.BookingConfirmation{}, providerErr(types.ComponentHotel, types.ErrNotAvailable, "no rooms left"))
+			},
+			startIn:     24 * time.Hour,
+			wantKeys:    []string{"authorize", "void"},
+			wantPayment: types.StatusVoided,
+		},
+		{
+			name: "flight failure",
+			setup: func(env *testsuite.TestWorkflowEnvironment) {
+				env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+				env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(
+					types.BookingConfirmation{}, providerErr(types.ComponentFlight, types.ErrNotAvailable, "no seats left"))
+				env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+			},
+			startIn:     24 * time.Hour,
+			wantKeys:    []string{"authorize", "void"},
+			wantPayment: types.StatusVoided,
+		},
+		{
+			name: "hotel cancelled by provider after capture",
+			setup: func(env *testsuite.TestWorkflowEnvironment) {
+				env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+				env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+				env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+				env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+				env.OnActivity(CancelCarActivity, mock.Anything, carConfirmation.BookingRef).Return(nil).Once()
+				env.OnActivity(CancelFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(nil).Once()
+				env.RegisterDelayedCallback(func() {
+					env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+						Component: types.ComponentHotel,
+						Reason:    "overbooked",
+					})
+				}, 24*time.Hour)
+			},
+			startIn:     14 * 24 * time.Hour,
+			wantKeys:    []string{"authorize", "capture", "refund"},
+			wantPayment: types.StatusRefunded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			gateway := activities.NewPaymentProvider()
+			var keys []string
+			tt.setup(env)
+			mockPayments(env, gateway, &keys)
+
+			booking := newFutureTestBooking(env, "TEST-151", tt.startIn)
+			booking.TotalAmount = 800
+			env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+			require.True(t, env.IsWorkflowCompleted())
+			require.Error(t, env.GetWorkflowError())
+			env.AssertExpectations(t)
+
+			var wantKeys []string
+			for _, operation := range tt.wantKeys {
+				wantKeys = append(wantKeys, "default-test-workflow-id/default-test-run-id/payment-"+operation)
+			}
+			require.Equal(t, wantKeys, keys)
+
+			// Compensation ends with the payment, which was taken first
+			var final types.TravelBooking
+			result, err := env.QueryWorkflow(QueryBookingStatus)
+			require.NoError(t, err)
+			require.NoError(t, result.Get(&final))
+			require.Equal(t, tt.wantPayment, final.Payment.Status)
+		})
+	}
+}
+
+// runIDInterceptor gives the workflow its own run ID, which the test
+// environment otherwise fixes for every run
+type runIDInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	runID string
+}
+
+func (r *runIDInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &runIDInbound{WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next}, runID: r.runID}
+}
+
+type runIDInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+	runID string
+}
+
+func (r *runIDInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	return r.Next.Init(&runIDOutbound{WorkflowOutboundInterceptorBase: interceptor.WorkflowOutboundInterceptorBase{Next: outbound}, runID: r.runID})
+}
+
+type runIDOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+	runID string
+}
+
+func (r *runIDOutbound) GetInfo(ctx workflow.Context) *workflow.Info {
+	info := *r.Next.GetInfo(ctx)
+	info.WorkflowExecution.RunID = r.runID
+	return &info
+}
+
+func Test_TravelBookingWorkflow_PaymentResubmittedAfterFailure(t *testing.T) {
+	// Both runs share the gateway and the workflow ID, as a booking submitted
+	// again through the API would
+	gateway := activities.NewPaymentProvider()
+	run := func(runID string, flightErr error) (error, []string) {
+		env := (&testsuite.WorkflowTestSuite{}).NewTestWorkflowEnvironment()
+		env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{&runIDInterceptor{runID: runID}}})
+		var keys []string
+		mockPayments(env, gateway, &keys)
+		env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+		env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, flightErr)
+		env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+		env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Maybe()
+		env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+		booking := newFutureTestBooking(env, "TEST-191", 24*time.Hour)
+		booking.TotalAmount = 800
+		env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+		require.True(t, env.IsWorkflowCompleted())
+		return env.GetWorkflowError(), keys
+	}
+
+	err, failedKeys := run("run-1", providerErr(types.ComponentFlight, types.ErrNotAvailable, "no seats left"))
+	require.Error(t, err)
+	err, keys := run("run-2", nil)
+	require.NoError(t, err, "the new run must not reuse the voided authorization")
+	for _, key := range keys {
+		require.NotContains(t, failedKeys, key)
+	}
+}
+
+func Test_TravelBookingWorkflow_PaymentAuthorizationDeclined(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(AuthorizePaymentActivity, mock.Anything, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, providerErr(types.ComponentPayment, types.ErrInvalidRequest, "card declined"))
+
+	booking := newTestBooking("TEST-152")
+	booking.TotalAmount = 800
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertNotCalled(t, "BookHotelActivity", mock.Anything, mock.Anything)
+}
+
+func Test_TravelBookingWorkflow_PaymentCapturedWithoutCar(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	gateway := activities.NewPaymentProvider()
+	var keys []string
+	mockPayments(env, gateway, &keys)
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(
+		types.BookingConfirmation{}, providerErr(types.ComponentCar, types.ErrNotAvailable, "no cars left"))
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalApprovePartialBooking, nil)
+	}, time.Hour)
+
+	booking := newFutureTestBooking(env, "TEST-153", 48*time.Hour)
+	booking.TotalAmount = 800
+	during := queryBooking(t, env, 2*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, types.StatusCaptured, during.Payment.Status)
+	require.Equal(t, 800.0-booking.CarBooking.Price, during.Payment.CapturedAmount)
+}
+
+func Test_TravelBookingWorkflow_HotelTimeoutAfterBookingNotRebooked(t *testing.T) {
+	policies, err := policy.ParseYAML([]byte(`
+activities:
+  BookHotelActivity:
+    start_to_close_timeout: 1s
+`))
+	require.NoError(t, err)
+	activityPolicies = policies
+	t.Cleanup(func() { activityPolicies = nil })
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	providers := newTestSimulator(t)
+	acts := activities.NewActivitiesWithProviders(discardLogger, providers)
+
+	// The provider books the first attempt but the response never makes it
+	// back before the attempt times out
+	var keys []string
+	var attempts int
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, booking *types.HotelBooking) (types.BookingConfirmation, error) {
+			attempts++
+			keys = append(keys, activities.IdempotencyKey(ctx))
+			confirmation, err := acts.BookHotel(ctx, booking)
+			if attempts == 1 {
+				// Hold the response until the attempt's own deadline passes
+				<-ctx.Done()
+			}
+			return confirmation, err
+		})
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	booking := newFutureTestBooking(env, "TEST-154", 48*time.Hour)
+	during := queryBooking(t, env, 24*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	require.Equal(t, 2, attempts)
+	require.Equal(t, keys[0], keys[1], "attempts must share an idempotency key")
+	inventory, err := providers.Inventory(context.Background(), types.ComponentHotel)
+	require.NoError(t, err)
+	require.Equal(t, simulator.DefaultCapacity-1, inventory["hotel-1"], "timed out attempt must not be booked twice")
+	require.Equal(t, "HTL-1", during.HotelBooking.BookingRef)
+}
+
+func Test_TravelBookingWorkflow_Scenarios(t *testing.T) {
+	scripts, err := scenario.Scripts()
+	require.NoError(t, err)
+
+	for name, script := range scripts {
+		t.Run(name, func(t *testing.T) {
+			faults := scenario.NewFaults(script)
+			activities.UseFaults(faults)
+			t.Cleanup(func() { activities.UseFaults(nil) })
+
+			providers := newTestSimulator(t)
+			acts := activities.NewActivitiesWithProviders(discardLogger, providers)
+
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestWorkflowEnvironment()
+			env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(acts.BookHotel).Maybe()
+			env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(acts.BookFlight).Maybe()
+			env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(acts.BookCar).Maybe()
+			env.OnActivity(CancelHotelActivity, mock.Anything, mock.Anything).Return(acts.CancelHotel).Maybe()
+			env.OnActivity(CancelFlightActivity, mock.Anything, mock.Anything).Return(acts.CancelFlight).Maybe()
+			env.OnActivity(CancelCarActivity, mock.Anything, mock.Anything).Return(acts.CancelCar).Maybe()
+			env.OnActivity(VerifyHotelActivity, mock.Anything, mock.Anything).Return(acts.VerifyHotel).Maybe()
+			env.OnActivity(VerifyFlightActivity, mock.Anything, mock.Anything).Return(acts.VerifyFlight).Maybe()
+			env.OnActivity(VerifyCarActivity, mock.Anything, mock.Anything).Return(acts.VerifyCar).Maybe()
+			env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			for _, event := range script.Events {
+				env.RegisterDelayedCallback(func() {
+					if event.Signal != SignalProviderCancellation {
+						env.SignalWorkflow(event.Signal, nil)
+						return
+					}
+					// The provider cancels on its side before telling the workflow
+					result, err := env.QueryWorkflow(QueryBookingStatus)
+					require.NoError(t, err)
+					var current types.TravelBooking
+					require.NoError(t, result.Get(&current))
+					ref, err := componentRef(current, event.Component)
+					require.NoError(t, err)
+					provider, _ := types.ComponentKind(event.Component)
+					require.NoError(t, providers.Cancel(context.Background(), provider, "", ref))
+					env.SignalWorkflow(event.Signal, types.ProviderCancellation{
+						Component:  event.Component,
+						BookingRef: ref,
+						Reason:     event.Reason,
+					})
+				}, time.Duration(event.After))
+			}
+
+			booking := newFutureTestBooking(env, "TEST-"+name, time.Duration(script.StartsIn))
+			env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+			require.True(t, env.IsWorkflowCompleted())
+			if script.Expect.Error {
+				require.Error(t, env.GetWorkflowError())
+			} else {
+				require.NoError(t, env.GetWorkflowError())
+			}
+
+			result, err := env.QueryWorkflow(QueryBookingStatus)
+			require.NoError(t, err)
+			var final types.TravelBooking
+			require.NoError(t, result.Get(&final))
+			require.Equal(t, script.Expect.Status, final.Status)
+			if name == "hotel-retries" {
+				require.Equal(t, 4, faults.Attempts("default-test-workflow-id/default-test-run-id", types.ComponentHotel, scenario.OperationBook))
+			}
+
+			// Whatever was unwound was given back to the providers
+			for _, component := range []string{types.ComponentHotel, types.ComponentFlight, types.ComponentCar} {
+				inventory, err := providers.Inventory(context.Background(), component)
+				require.NoError(t, err)
+				for item, left := range inventory {
+					if *componentStatus(&final, component) == types.StatusConfirmed {
+						require.Equal(t, simulator.DefaultCapacity-1, left, item)
+					} else {
+						require.Equal(t, simulator.DefaultCapacity, left, item)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_TravelBookingWorkflow_NotifiesContact(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	var sent []notification.Email
+	transport := notification.TransportFunc(func(_ context.Context, email notification.Email) error {
+		sent = append(sent, email)
+		return nil
+	})
+	notifier := notification.NewNotifier(discardLogger, notification.DefaultSender, notification.NewLog(nil),
+		map[string]notification.Transport{"test": transport})
+	activities.UseNotifier(notifier)
+	t.Cleanup(func() { activities.UseNotifier(notification.Default()) })
+	acts := activities.NewActivitiesWithProviders(discardLogger, newTestSimulator(t))
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{},
+		temporal.NewNonRetryableApplicationError("sold out", string(types.ErrNotAvailable), nil))
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(acts.SendNotification)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalApprovePartialBooking, nil)
+	}, time.Hour)
+
+	booking := newFutureTestBooking(env, "TEST-160", 48*time.Hour)
+	booking.EndDate = booking.StartDate.Add(24 * time.Hour)
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	require.Len(t, sent, 3)
+	require.Equal(t, notification.EventNeedsApproval, sent[0].Event)
+	require.Contains(t, sent[0].HTML, "Hello Test User")
+	require.Contains(t, sent[0].HTML, "go ahead without the car by")
+	require.Equal(t, notification.EventPartial, sent[1].Event)
+	require.Contains(t, sent[1].HTML, "does not include a car")
+	require.Equal(t, notification.EventReminder, sent[2].Event)
+	for _, email := range sent {
+		require.Equal(t, "user@example.com", email.To)
+		require.Equal(t, "TEST-160", email.BookingID)
+	}
+
+	records := notifier.Log().Records()
+	require.Len(t, records, 3)
+	require.NotEqual(t, records[0].MessageID, records[1].MessageID)
+}
+
+func Test_TravelBookingWorkflow_TripCheckpoints(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	// Booked three months ahead for a week
+	startIn := 90 * 24 * time.Hour
+	booking := newFutureTestBooking(env, "TEST-170", startIn)
+
+	var verifiedAt []time.Time
+	verified := func(ctx context.Context, bookingRef string) (types.BookingConfirmation, error) {
+		verifiedAt = append(verifiedAt, env.Now())
+		return types.BookingConfirmation{BookingRef: bookingRef, Status: types.StatusConfirmed}, nil
+	}
+	var remindedAt time.Time
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(VerifyHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(verified).Once()
+	env.OnActivity(VerifyFlightActivity, mock.Anything, flightConfirmation.BookingRef).Return(verified).Once()
+	env.OnActivity(VerifyCarActivity, mock.Anything, carConfirmation.BookingRef).Return(verified).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventReminder, "Travel Day Reminder")).Return(
+		func(ctx context.Context, msg notification.Message) error {
+			remindedAt = env.Now()
+			require.Contains(t, msg.Detail, hotelConfirmation.BookingRef)
+			require.Contains(t, msg.Detail, flightConfirmation.BookingRef)
+			return nil
+		}).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	beforeVerify := queryBooking(t, env, startIn-VerifyBeforeStart-time.Hour)
+	afterVerify := queryBooking(t, env, startIn-VerifyBeforeStart+time.Hour)
+	duringTrip := queryBooking(t, env, startIn+24*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	require.Len(t, verifiedAt, 3)
+	for _, at := range verifiedAt {
+		require.WithinDuration(t, booking.StartDate.Add(-VerifyBeforeStart), at, 0)
+	}
+	require.True(t, beforeVerify.VerifiedAt.IsZero())
+	require.WithinDuration(t, booking.StartDate.Add(-VerifyBeforeStart), afterVerify.VerifiedAt, 0)
+
+	require.WithinDuration(t, travelDay(&booking), remindedAt, 0)
+	require.False(t, remindedAt.After(booking.StartDate))
+	require.Less(t, booking.StartDate.Sub(remindedAt), 24*time.Hour)
+
+	require.Equal(t, types.StatusConfirmed, duringTrip.Status)
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, types.StatusCompleted, final.Status)
+	last := final.AuditLog[len(final.AuditLog)-1]
+	require.Equal(t, types.StatusCompleted, last.To)
+	require.WithinDuration(t, booking.EndDate, last.At, 0)
+}
+
+func Test_TravelBookingWorkflow_VerificationFindsCancelledFlight(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(VerifyHotelActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{Status: types.StatusConfirmed}, nil)
+	// The airline dropped the flight without telling anyone
+	env.OnActivity(VerifyFlightActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{Status: types.StatusCancelled}, nil)
+	env.OnActivity(CancelHotelActivity, mock.Anything, hotelConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(CancelCarActivity, mock.Anything, carConfirmation.BookingRef).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventCancelled, "Travel Booking Cancelled")).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-171", 30*24*time.Hour))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	env.AssertNotCalled(t, "VerifyCarActivity", mock.Anything, mock.Anything)
+	env.AssertNotCalled(t, "CancelFlightActivity", mock.Anything, mock.Anything)
+	env.AssertNotCalled(t, "SendNotificationActivity", mock.Anything, notice(notification.EventReminder, "Travel Day Reminder"))
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, types.StatusCancelled, final.Status)
+	require.Equal(t, types.StatusCancelled, final.FlightBooking.Status)
+	require.GreaterOrEqual(t, auditIndex(&final, types.ComponentFlight, types.StatusCancelled), 0)
+}
+
+func Test_TravelBookingWorkflow_VerificationProviderDown(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.OnActivity(BookHotelActivity, mock.Anything, mock.Anything).Return(hotelConfirmation, nil)
+	env.OnActivity(BookFlightActivity, mock.Anything, mock.Anything).Return(flightConfirmation, nil)
+	env.OnActivity(BookCarActivity, mock.Anything, mock.Anything).Return(carConfirmation, nil)
+	env.OnActivity(VerifyHotelActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{},
+		temporal.NewApplicationError("hotel unreachable", string(types.ErrProviderDown)))
+	env.OnActivity(VerifyFlightActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{Status: types.StatusConfirmed}, nil)
+	env.OnActivity(VerifyCarActivity, mock.Anything, mock.Anything).Return(types.BookingConfirmation{Status: types.StatusConfirmed}, nil)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newFutureTestBooking(env, "TEST-172", 7*24*time.Hour))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError(), "a provider that cannot be asked does not cancel the trip")
+	env.AssertNotCalled(t, "CancelHotelActivity", mock.Anything, mock.Anything)
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, types.StatusCompleted, final.Status)
+	require.Equal(t, types.StatusConfirmed, final.HotelBooking.Status)
+	require.NotEmpty(t, final.Errors)
+	require.Equal(t, types.ErrProviderDown, final.Errors[len(final.Errors)-1].Kind)
+}
+
+// Confirmations for the second hotel and the return flight of an itinerary
+var (
+	hotel2Confirmation       = types.BookingConfirmation{BookingRef: "HTL-2", Status: types.StatusConfirmed, Price: 150.0}
+	returnFlightConfirmation = types.BookingConfirmation{BookingRef: "FLT-2", Status: types.StatusConfirmed, Price: 450.0}
+)
+
+// newTestItinerary returns a week-long trip starting at start with outbound
+// and return flights, a hotel for each half of the stay and one car
+func newTestItinerary(bookingID string, start time.Time) types.TravelBooking {
+	const day = 24 * time.Hour
+	booking := newTestBooking(bookingID)
+	booking.StartDate = start
+	booking.EndDate = start.Add(7 * day)
+	booking.HotelBooking, booking.FlightBooking, booking.CarBooking = nil, nil, nil
+	booking.Flights = []*types.FlightBooking{
+		{FlightNumber: "FL123", SeatClass: "economy", Price: 500, Departure: start, Arrival: start.Add(8 * time.Hour)},
+		{FlightNumber: "FL456", SeatClass: "economy", Price: 450, Departure: start.Add(7*day - 10*time.Hour), Arrival: start.Add(7*day - 2*time.Hour)},
+	}
+	booking.Hotels = []*types.HotelBooking{
+		{HotelID: "hotel-1", RoomType: "deluxe", Price: 200, CheckIn: start.Add(10 * time.Hour), CheckOut: start.Add(3 * day)},
+		{HotelID: "hotel-2", RoomType: "standard", Price: 150, CheckIn: start.Add(3 * day), CheckOut: start.Add(7*day - 12*time.Hour)},
+	}
+	booking.Cars = []*types.CarBooking{
+		{CarType: "SUV", Price: 100, PickUp: start.Add(9 * time.Hour), DropOff: start.Add(7*day - 12*time.Hour)},
+	}
+	return booking
+}
+
+// flightNumber matches flight bookings for the given flight
+func flightNumber(number string) any {
+	return mock.MatchedBy(func(b *types.FlightBooking) bool { return b.FlightNumber == number })
+}
+
+// carType matches car bookings for the given type of car
+func carType(carType string) any {
+	return mock.MatchedBy(func(b *types.CarBooking) bool { return b.CarType == carType })
+}
+
+// mockItinerary books every component of newTestItinerary except the ones
+// named in failing, which the providers have no availability for
+func mockItinerary(env *testsuite.TestWorkflowEnvironment, failing ...string) {
+	book := func(component string, confirmation types.BookingConfirmation) (types.BookingConfirmation, error) {
+		if slices.Contains(failing, component) {
+			kind, _ := types.ComponentKind(component)
+			return types.BookingConfirmation{}, providerErr(kind, types.ErrNotAvailable, component+" booking failed")
+		}
+		return confirmation, nil
+	}
+	env.OnActivity(BookHotelActivity, mock.Anything, hotelID("hotel-1")).Return(book("hotel", hotelConfirmation))
+	env.OnActivity(BookHotelActivity, mock.Anything, hotelID("hotel-2")).Return(book("hotel-2", hotel2Confirmation))
+	env.OnActivity(BookFlightActivity, mock.Anything, flightNumber("FL123")).Return(book("flight", flightConfirmation))
+	env.OnActivity(BookFlightActivity, mock.Anything, flightNumber("FL456")).Return(book("flight-2", returnFlightConfirmation))
+	env.OnActivity(BookCarActivity, mock.Anything, carType("SUV")).Return(book("car", carConfirmation))
+}
+
+// mockCancelledRefs records the booking references cancelled, in order
+func mockCancelledRefs(env *testsuite.TestWorkflowEnvironment, cancelled *[]string) {
+	for _, cancel := range []any{CancelHotelActivity, CancelFlightActivity, CancelCarActivity} {
+		env.OnActivity(cancel, mock.Anything, mock.Anything).Return(func(_ context.Context, bookingRef string) error {
+			*cancelled = append(*cancelled, bookingRef)
+			return nil
+		}).Maybe()
+	}
+}
+
+func Test_TravelBookingWorkflow_MultiLegItinerary(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	var started []string
+	env.SetOnActivityStartedListener(func(info *activity.Info, _ context.Context, _ converter.EncodedValues) {
+		if strings.HasPrefix(info.ActivityType.Name, "Book") {
+			started = append(started, info.ActivityType.Name)
+		}
+		if info.ActivityType.Name == "BookHotelActivity" {
+			started = append(started, info.ActivityID)
+		}
+	})
+	mockItinerary(env)
+	mockVerifications(env)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	booking := newTestItinerary("TEST-186", env.Now().Add(14*24*time.Hour))
+	require.NoError(t, booking.Validate())
+	require.Equal(t, []string{"hotel", "hotel-2", "flight", "flight-2", "car"}, booking.Components())
+	during := queryBooking(t, env, time.Hour)
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	// Booked in travel order, each hotel with its own activity ID
+	require.Equal(t, []string{
+		"BookHotelActivity", HotelBookingActivityID,
+		"BookHotelActivity", HotelBookingActivityID + "-leg-2",
+		"BookFlightActivity", "BookFlightActivity", "BookCarActivity",
+	}, started)
+
+	require.Equal(t, types.StatusConfirmed, during.Status)
+	require.Equal(t, "HTL-2", during.Hotels[1].BookingRef)
+	require.Equal(t, "FLT-2", during.Flights[1].BookingRef)
+	require.Nil(t, during.HotelBooking)
+	require.NotEqual(t, -1, auditIndex(during, "flight-2", types.StatusConfirmed))
+}
+
+func Test_TravelBookingWorkflow_ReturnFlightFailureCompensatesItinerary(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	mockItinerary(env, "flight-2")
+	var cancelled []string
+	mockCancelledRefs(env, &cancelled)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestItinerary("TEST-187", env.Now().Add(14*24*time.Hour)))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "flight-2 booking failed")
+	env.AssertNotCalled(t, "BookCarActivity", mock.Anything, mock.Anything)
+	// Everything booked before the return flight is unwound, last first
+	require.Equal(t, []string{"FLT-1", "HTL-2", "HTL-1"}, cancelled)
+
+	result, err := env.QueryWorkflow(QueryBookingStatus)
+	require.NoError(t, err)
+	var final types.TravelBooking
+	require.NoError(t, result.Get(&final))
+	require.Equal(t, types.StatusFailed, final.Status)
+	require.Equal(t, types.StatusFailed, final.Flights[1].Status)
+	require.Equal(t, types.StatusCancelled, final.Hotels[1].Status)
+	require.Equal(t, types.StatusPending, final.Cars[0].Status)
+	require.Equal(t, "flight-2 booking failed", final.AuditLog[len(final.AuditLog)-1].Reason)
+}
+
+func Test_TravelBookingWorkflow_ItineraryBooksAlternativeFlight(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	mockItinerary(env, "flight-2")
+	env.OnActivity(BookFlightActivity, mock.Anything, flightNumber("FL457")).Return(
+		types.BookingConfirmation{BookingRef: "FLT-3", Status: types.StatusConfirmed, Price: 480}, nil).Once()
+	mockVerifications(env)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	booking := newTestItinerary("TEST-193", env.Now().Add(14*24*time.Hour))
+	earlier := types.FlightAlternative{
+		FlightNumber: "FL457", SeatClass: "economy", Price: 480,
+		Departure: booking.Flights[1].Departure.Add(-2 * time.Hour), Arrival: booking.Flights[1].Arrival.Add(-2 * time.Hour),
+	}
+	booking.Flights[1].Alternatives = []types.FlightAlternative{earlier}
+	require.NoError(t, booking.Validate())
+	final := queryBooking(t, env, 15*24*time.Hour)
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	// The alternative's times replace the return flight's own
+	flight := final.Flights[1]
+	require.Equal(t, "FL457", flight.FlightNumber)
+	require.Equal(t, 1, flight.Alternative)
+	require.True(t, earlier.Departure.Equal(flight.Departure))
+	require.True(t, earlier.Arrival.Equal(flight.Arrival))
+}
+
+func Test_TravelBookingWorkflow_ParallelItineraryFailure(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	mockItinerary(env, "hotel-2")
+	var cancelled []string
+	mockCancelledRefs(env, &cancelled)
+
+	booking := newTestItinerary("TEST-188", env.Now().Add(14*24*time.Hour))
+	booking.Mode = types.ModeParallel
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "hotel-2 booking failed")
+	require.ElementsMatch(t, []string{"HTL-1", "FLT-1", "FLT-2", "CAR-1"}, cancelled)
+}
+
+func Test_TravelBookingWorkflow_SecondHotelCancelledByProvider(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	mockItinerary(env)
+	var cancelled []string
+	mockCancelledRefs(env, &cancelled)
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+			Component:  "hotel-2",
+			BookingRef: hotel2Confirmation.BookingRef,
+			Reason:     "overbooked",
+		})
+	}, 24*time.Hour)
+
+	env.ExecuteWorkflow(TravelBookingWorkflow, newTestItinerary("TEST-189", env.Now().Add(14*24*time.Hour)))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "hotel-2 booking cancelled by provider")
+	require.Equal(t, []string{"CAR-1", "FLT-2", "FLT-1", "HTL-1"}, cancelled)
+}
+
+func Test_TravelBookingWorkflow_SecondCarCancelledByProvider(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	mockItinerary(env)
+	env.OnActivity(BookCarActivity, mock.Anything, carType("compact")).Return(
+		types.BookingConfirmation{BookingRef: "CAR-2", Status: types.StatusConfirmed, Price: 60}, nil)
+	var cancelled []string
+	mockCancelledRefs(env, &cancelled)
+	// The approval names the car the provider cancelled
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventNeedsApproval, "Approval Needed: Travel Booking Without car-2")).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalProviderCancellation, types.ProviderCancellation{
+			Component:  "car-2",
+			BookingRef: "CAR-2",
+			Reason:     "fleet recalled",
+		})
+	}, 24*time.Hour)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalRejectPartialBooking, nil)
+	}, 25*time.Hour)
+
+	booking := newTestItinerary("TEST-195", env.Now().Add(14*24*time.Hour))
+	booking.Cars = append(booking.Cars, &types.CarBooking{CarType: "compact", Price: 60})
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "car-2 booking cancelled by provider")
+	env.AssertExpectations(t)
+	require.Contains(t, cancelled, "CAR-1")
+}
+
+func Test_TravelBookingWorkflow_ItineraryWithoutOneCar(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	gateway := activities.NewPaymentProvider()
+	var keys []string
+	mockPayments(env, gateway, &keys)
+	mockItinerary(env)
+	env.OnActivity(BookCarActivity, mock.Anything, carType("compact")).Return(
+		types.BookingConfirmation{}, providerErr(types.ComponentCar, types.ErrNotAvailable, "no cars left"))
+	env.OnActivity(SendNotificationActivity, mock.Anything, notice(notification.EventNeedsApproval, "Approval Needed: Travel Booking Without car-2")).Return(nil).Once()
+	env.OnActivity(SendNotificationActivity, mock.Anything, mock.Anything).Return(nil)
+	mockVerifications(env)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(SignalApprovePartialBooking, nil)
+	}, time.Hour)
+
+	booking := newTestItinerary("TEST-190", env.Now().Add(14*24*time.Hour))
+	// A second car for the drive home from the airport
+	booking.Cars = append(booking.Cars, &types.CarBooking{CarType: "compact", Price: 60})
+	booking.TotalAmount = 1460
+	during := queryBooking(t, env, 2*time.Hour)
+	env.ExecuteWorkflow(TravelBookingWorkflow, booking)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	require.Equal(t, types.StatusPartiallyConfirmed, during.Status)
+	require.Equal(t, types.StatusConfirmed, during.Cars[0].Status)
+	require.Equal(t, types.StatusFailed, during.Cars[1].Status)
+	require.Equal(t, 1400.0, during.Payment.CapturedAmount)
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"net/http"
 	"os"
 	"time"
 
+	"github.com/leowmjw/go-durable-x/telemetry"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
@@ -187,9 +191,32 @@ func MaybeCrash(probability float32) error {
 	return nil
 }
 
+// defaultMetricsAddr serves /metrics when METRICS_ADDR is unset
+const defaultMetricsAddr = ":9464"
+
 func main() {
+	tel, err := telemetry.FromEnv("machine-operator")
+	if err != nil {
+		slog.Error("Invalid telemetry settings", "err", err)
+		os.Exit(1)
+	}
+	defer tel.Shutdown(context.Background())
+
+	metricsAddr := os.Getenv(telemetry.MetricsAddrEnv)
+	if metricsAddr == "" {
+		metricsAddr = defaultMetricsAddr
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", tel.Handler())
+	go func() {
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server failed", "err", err)
+		}
+	}()
+
 	client, err := client.NewClient(client.Options{
-		HostPort: "localhost:7233",
+		HostPort:     "localhost:7233",
+		Interceptors: []interceptor.ClientInterceptor{tel.ClientInterceptor()},
 	})
 	if err != nil {
 		slog.Error("Unable to create client", "err", err)
@@ -197,7 +224,9 @@ func main() {
 	}
 	defer client.Close()
 
-	w := worker.New(client, "machine-operator", worker.Options{})
+	w := worker.New(client, "machine-operator", worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{tel.WorkerInterceptor()},
+	})
 	w.RegisterWorkflow(MachineOperatorWorkflow)
 	w.RegisterActivity(BringUpMachine)
 	w.RegisterActivity(TearDownMachine)
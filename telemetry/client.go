@@ -0,0 +1,71 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+)
+
+type clientInterceptor struct {
+	interceptor.ClientInterceptorBase
+	t *Telemetry
+}
+
+func (c *clientInterceptor) InterceptClient(next interceptor.ClientOutboundInterceptor) interceptor.ClientOutboundInterceptor {
+	return &clientOutbound{ClientOutboundInterceptorBase: interceptor.ClientOutboundInterceptorBase{Next: next}, t: c.t}
+}
+
+// clientOutbound times the calls that start, signal and query workflows,
+// each in a span whose context is sent on to the workflow
+type clientOutbound struct {
+	interceptor.ClientOutboundInterceptorBase
+	t *Telemetry
+}
+
+func (c *clientOutbound) ExecuteWorkflow(ctx context.Context, in *interceptor.ClientExecuteWorkflowInput) (client.WorkflowRun, error) {
+	ctx, done := c.start(ctx, "start_workflow", "StartWorkflow:"+in.WorkflowType, in.Options.ID)
+	run, err := c.Next.ExecuteWorkflow(ctx, in)
+	done(err)
+	return run, err
+}
+
+func (c *clientOutbound) SignalWorkflow(ctx context.Context, in *interceptor.ClientSignalWorkflowInput) error {
+	ctx, done := c.start(ctx, "signal_workflow", "SignalWorkflow:"+in.SignalName, in.WorkflowID)
+	err := c.Next.SignalWorkflow(ctx, in)
+	done(err)
+	return err
+}
+
+func (c *clientOutbound) SignalWithStartWorkflow(ctx context.Context, in *interceptor.ClientSignalWithStartWorkflowInput) (client.WorkflowRun, error) {
+	ctx, done := c.start(ctx, "signal_with_start_workflow", "SignalWithStartWorkflow:"+in.WorkflowType, in.Options.ID)
+	run, err := c.Next.SignalWithStartWorkflow(ctx, in)
+	done(err)
+	return run, err
+}
+
+func (c *clientOutbound) QueryWorkflow(ctx context.Context, in *interceptor.ClientQueryWorkflowInput) (converter.EncodedValue, error) {
+	ctx, done := c.start(ctx, "query_workflow", "QueryWorkflow:"+in.QueryType, in.WorkflowID)
+	value, err := c.Next.QueryWorkflow(ctx, in)
+	done(err)
+	return value, err
+}
+
+// start opens the span for a client call and writes it to the call's header.
+// The returned func records the call once it returns.
+func (c *clientOutbound) start(ctx context.Context, operation, spanName, workflowID string) (context.Context, func(error)) {
+	ctx, span := c.t.tracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("temporal.workflow_id", workflowID)))
+	inject(ctx, interceptor.Header(ctx))
+
+	start := time.Now()
+	return ctx, func(err error) {
+		c.t.metrics.clientDuration.WithLabelValues(operation, outcome(err)).Observe(time.Since(start).Seconds())
+		endSpan(span, err)
+	}
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Outcomes of a workflow, activity or client call
+const (
+	outcomeCompleted      = "completed"
+	outcomeFailed         = "failed"
+	outcomeCanceled       = "canceled"
+	outcomeContinuedAsNew = "continued_as_new"
+)
+
+// metrics are the collectors the interceptors record to
+type metrics struct {
+	workflowDuration *prometheus.HistogramVec
+	activityDuration *prometheus.HistogramVec
+	activityRetries  *prometheus.CounterVec
+	compensations    *prometheus.CounterVec
+	clientDuration   *prometheus.HistogramVec
+}
+
+func newMetrics(registry prometheus.Registerer) *metrics {
+	m := &metrics{
+		// Trips and other long workflows run for weeks
+		workflowDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "temporal_workflow_duration_seconds",
+			Help:    "Time from a workflow starting to it closing, by outcome.",
+			Buckets: prometheus.ExponentialBucketsRange(0.1, (60 * 24 * time.Hour).Seconds(), 16),
+		}, []string{"workflow_type", "outcome"}),
+		activityDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "temporal_activity_duration_seconds",
+			Help:    "Time taken by one activity attempt, by outcome.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 4, 10),
+		}, []string{"activity_type", "outcome"}),
+		activityRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "temporal_activity_retries_total",
+			Help: "Activity attempts after the first.",
+		}, []string{"activity_type"}),
+		compensations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "temporal_compensations_total",
+			Help: "Compensating activities scheduled by workflows.",
+		}, []string{"workflow_type", "activity_type"}),
+		clientDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "temporal_client_request_duration_seconds",
+			Help:    "Time taken by client calls to start, signal or query workflows, by outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+	}
+	registry.MustRegister(m.workflowDuration, m.activityDuration, m.activityRetries, m.compensations, m.clientDuration)
+	return m
+}
+
+// outcome labels how a call ended
+func outcome(err error) string {
+	var canceled *temporal.CanceledError
+	switch {
+	case err == nil:
+		return outcomeCompleted
+	case workflow.IsContinueAsNewError(err):
+		return outcomeContinuedAsNew
+	case errors.As(err, &canceled):
+		return outcomeCanceled
+	default:
+		return outcomeFailed
+	}
+}
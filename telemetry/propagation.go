@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// traceHeader is the Temporal header the trace context travels in
+const traceHeader = "_tracer-data"
+
+var propagator = propagation.TraceContext{}
+
+// inject writes the span context of ctx to header, if both exist
+func inject(ctx context.Context, header map[string]*commonpb.Payload) {
+	if header == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	payload, err := converter.GetDefaultDataConverter().ToPayload(map[string]string(carrier))
+	if err != nil {
+		return
+	}
+	header[traceHeader] = payload
+}
+
+// extract returns ctx with the remote span context in header, if any
+func extract(ctx context.Context, header map[string]*commonpb.Payload) context.Context {
+	payload, ok := header[traceHeader]
+	if !ok {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, (*map[string]string)(&carrier)); err != nil {
+		return ctx
+	}
+	return propagator.Extract(ctx, carrier)
+}
@@ -0,0 +1,173 @@
+// Package telemetry instruments Temporal clients and workers. Its interceptors
+// record workflow and activity latency, activity retries, compensations and
+// failures as Prometheus metrics, served by Handler on /metrics, and carry an
+// OpenTelemetry trace from the client call that started a workflow through to
+// every activity it runs. Spans go to the TracerProvider it is given; FromEnv
+// writes them to a local file or stdout.
+//
+// Failure rates come from the outcome label on the duration histograms, e.g.
+//
+//	sum by (workflow_type) (rate(temporal_workflow_duration_seconds_count{outcome="failed"}[5m]))
+//	  / sum by (workflow_type) (rate(temporal_workflow_duration_seconds_count[5m]))
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// Environment variables configuring FromEnv and the workers that use it
+const (
+	// TracesFileEnv is a file spans are appended to as JSON, or "stdout";
+	// unset drops spans
+	TracesFileEnv = "TRACES_FILE"
+	// MetricsAddrEnv is where a worker without an HTTP server of its own
+	// serves /metrics
+	MetricsAddrEnv = "METRICS_ADDR"
+)
+
+// tracerName identifies the spans of this package
+const tracerName = "github.com/leowmjw/go-durable-x/telemetry"
+
+// Options configures New
+type Options struct {
+	// TracerProvider receives the spans; nil drops them
+	TracerProvider trace.TracerProvider
+	// Compensation reports whether an activity undoes earlier work; nil
+	// uses IsCompensation
+	Compensation func(activityType string) bool
+}
+
+// Telemetry holds the metrics and tracer shared by a process's client and
+// worker interceptors
+type Telemetry struct {
+	registry     *prometheus.Registry
+	metrics      *metrics
+	tracer       trace.Tracer
+	compensation func(activityType string) bool
+	shutdown     func(context.Context) error
+}
+
+// New returns telemetry with its own metrics registry, which also reports the
+// Go runtime and process
+func New(opts Options) *Telemetry {
+	if opts.TracerProvider == nil {
+		opts.TracerProvider = noop.NewTracerProvider()
+	}
+	if opts.Compensation == nil {
+		opts.Compensation = IsCompensation
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return &Telemetry{
+		registry:     registry,
+		metrics:      newMetrics(registry),
+		tracer:       opts.TracerProvider.Tracer(tracerName),
+		compensation: opts.Compensation,
+		shutdown:     func(context.Context) error { return nil },
+	}
+}
+
+// FromEnv returns telemetry for service that exports spans to the file or
+// stdout named by TracesFileEnv, if set. Shutdown flushes them.
+func FromEnv(service string) (*Telemetry, error) {
+	var w io.Writer
+	var file *os.File
+	switch path := os.Getenv(TracesFileEnv); path {
+	case "":
+		return New(Options{}), nil
+	case "stdout", "-":
+		w = os.Stdout
+	default:
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open traces file: %w", err)
+		}
+		w, file = f, f
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, fmt.Errorf("create trace exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	)
+
+	t := New(Options{TracerProvider: provider})
+	t.shutdown = func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}
+	return t, nil
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (t *Telemetry) Handler() http.Handler {
+	return promhttp.HandlerFor(t.registry, promhttp.HandlerOpts{Registry: t.registry})
+}
+
+// Register adds collectors of the process's own, such as circuit breaker
+// states, to the metrics served by Handler
+func (t *Telemetry) Register(collectors ...prometheus.Collector) error {
+	for _, c := range collectors {
+		if err := t.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientInterceptor returns the interceptor for client.Options.Interceptors
+func (t *Telemetry) ClientInterceptor() interceptor.ClientInterceptor {
+	return &clientInterceptor{t: t}
+}
+
+// WorkerInterceptor returns the interceptor for worker.Options.Interceptors
+func (t *Telemetry) WorkerInterceptor() interceptor.WorkerInterceptor {
+	return &workerInterceptor{t: t}
+}
+
+// Shutdown flushes any spans not yet exported
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	return t.shutdown(ctx)
+}
+
+// compensationPrefixes name activities that undo a booking, payment or hold
+var compensationPrefixes = []string{"Cancel", "Void", "Refund", "Release", "Compensate"}
+
+// IsCompensation reports whether activityType is named as a compensation,
+// such as CancelHotelActivity or RefundPaymentActivity
+func IsCompensation(activityType string) bool {
+	for _, prefix := range compensationPrefixes {
+		if strings.HasPrefix(activityType, prefix) {
+			return true
+		}
+	}
+	return false
+}
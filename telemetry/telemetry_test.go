@@ -0,0 +1,222 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func BookRoom(ctx context.Context) error {
+	if activity.GetInfo(ctx).Attempt == 1 {
+		return errors.New("provider down")
+	}
+	return nil
+}
+
+func CancelRoom(context.Context) error {
+	return nil
+}
+
+// bookStay books a room, waits a day and, if told to give up, cancels it
+func bookStay(ctx workflow.Context, giveUp bool) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3},
+	})
+	if err := workflow.ExecuteActivity(ctx, BookRoom).Get(ctx, nil); err != nil {
+		return err
+	}
+	if err := workflow.Sleep(ctx, 24*time.Hour); err != nil {
+		return err
+	}
+	if giveUp {
+		if err := workflow.ExecuteActivity(ctx, CancelRoom).Get(ctx, nil); err != nil {
+			return err
+		}
+		return errors.New("stay cancelled")
+	}
+	return nil
+}
+
+// runStay runs bookStay with tel's worker interceptor and header as the
+// header it was started with
+func runStay(t *testing.T, tel *Telemetry, header map[string]*commonpb.Payload, giveUp bool) {
+	env := (&testsuite.WorkflowTestSuite{}).NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{tel.WorkerInterceptor()}})
+	env.SetHeader(&commonpb.Header{Fields: header})
+	env.RegisterActivity(BookRoom)
+	env.RegisterActivity(CancelRoom)
+	env.ExecuteWorkflow(bookStay, giveUp)
+	require.True(t, env.IsWorkflowCompleted())
+}
+
+func scrape(t *testing.T, tel *Telemetry) string {
+	server := httptest.NewServer(tel.Handler())
+	t.Cleanup(server.Close)
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestWorkerMetrics(t *testing.T) {
+	tel := New(Options{})
+	runStay(t, tel, nil, false)
+	runStay(t, tel, nil, true)
+
+	metrics := scrape(t, tel)
+	for _, want := range []string{
+		`temporal_workflow_duration_seconds_count{outcome="completed",workflow_type="bookStay"} 1`,
+		`temporal_workflow_duration_seconds_count{outcome="failed",workflow_type="bookStay"} 1`,
+		`temporal_activity_duration_seconds_count{activity_type="BookRoom",outcome="failed"} 2`,
+		`temporal_activity_duration_seconds_count{activity_type="BookRoom",outcome="completed"} 2`,
+		`temporal_activity_retries_total{activity_type="BookRoom"} 2`,
+		`temporal_compensations_total{activity_type="CancelRoom",workflow_type="bookStay"} 1`,
+		`go_goroutines`,
+	} {
+		require.Contains(t, metrics, want)
+	}
+	// The day and retry of workflow time are measured, not the milliseconds
+	// the test took
+	require.Contains(t, metrics, `temporal_workflow_duration_seconds_sum{outcome="completed",workflow_type="bookStay"} 86401`)
+}
+
+func TestTraceFollowsWorkflow(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tel := New(Options{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))})
+
+	// The header as the client interceptor would have sent it
+	ctx, caller := tel.tracer.Start(context.Background(), "caller")
+	header := map[string]*commonpb.Payload{}
+	inject(ctx, header)
+	caller.End()
+	runStay(t, tel, header, true)
+
+	var names []string
+	for _, span := range spans.Ended()[1:] {
+		names = append(names, span.Name())
+		require.Equal(t, caller.SpanContext().TraceID(), span.SpanContext().TraceID(), span.Name())
+		require.Equal(t, caller.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+	}
+	require.Equal(t, []string{"RunActivity:BookRoom", "RunActivity:BookRoom", "RunActivity:CancelRoom", "RunWorkflow:bookStay"}, names)
+
+	first, run := spans.Ended()[1], spans.Ended()[4]
+	require.Equal(t, codes.Error, first.Status().Code)
+	require.Equal(t, codes.Error, run.Status().Code)
+	require.Equal(t, "stay cancelled", run.Status().Description)
+	require.GreaterOrEqual(t, run.EndTime().Sub(run.StartTime()), 24*time.Hour)
+}
+
+// fakeClient stands in for the SDK client behind the interceptor
+type fakeClient struct {
+	interceptor.ClientOutboundInterceptorBase
+	err error
+}
+
+func (f *fakeClient) ExecuteWorkflow(context.Context, *interceptor.ClientExecuteWorkflowInput) (client.WorkflowRun, error) {
+	return nil, f.err
+}
+
+func (f *fakeClient) SignalWorkflow(context.Context, *interceptor.ClientSignalWorkflowInput) error {
+	return f.err
+}
+
+func (f *fakeClient) QueryWorkflow(context.Context, *interceptor.ClientQueryWorkflowInput) (converter.EncodedValue, error) {
+	return nil, f.err
+}
+
+func TestClientInterceptor(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tel := New(Options{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))})
+	fake := &fakeClient{}
+	c := tel.ClientInterceptor().InterceptClient(fake)
+	ctx := context.Background()
+
+	_, err := c.ExecuteWorkflow(ctx, &interceptor.ClientExecuteWorkflowInput{
+		Options:      &client.StartWorkflowOptions{ID: "stay-1"},
+		WorkflowType: "bookStay",
+	})
+	require.NoError(t, err)
+	fake.err = errors.New("workflow not found")
+	require.Error(t, c.SignalWorkflow(ctx, &interceptor.ClientSignalWorkflowInput{WorkflowID: "stay-1", SignalName: "give-up"}))
+	_, err = c.QueryWorkflow(ctx, &interceptor.ClientQueryWorkflowInput{WorkflowID: "stay-1", QueryType: "status"})
+	require.Error(t, err)
+
+	metrics := scrape(t, tel)
+	require.Contains(t, metrics, `temporal_client_request_duration_seconds_count{operation="start_workflow",outcome="completed"} 1`)
+	require.Contains(t, metrics, `temporal_client_request_duration_seconds_count{operation="signal_workflow",outcome="failed"} 1`)
+	require.Contains(t, metrics, `temporal_client_request_duration_seconds_count{operation="query_workflow",outcome="failed"} 1`)
+
+	ended := spans.Ended()
+	require.Len(t, ended, 3)
+	require.Equal(t, "StartWorkflow:bookStay", ended[0].Name())
+	require.Equal(t, codes.Error, ended[1].Status().Code)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(TracesFileEnv, "")
+	tel, err := FromEnv("travel-booking")
+	require.NoError(t, err)
+	require.NoError(t, tel.Shutdown(context.Background()))
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	t.Setenv(TracesFileEnv, path)
+	tel, err = FromEnv("travel-booking")
+	require.NoError(t, err)
+	_, span := tel.tracer.Start(context.Background(), "StartWorkflow:bookStay")
+	span.End()
+	require.NoError(t, tel.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"Name":"StartWorkflow:bookStay"`)
+	require.Contains(t, string(data), `"Value":"travel-booking"`)
+
+	t.Setenv(TracesFileEnv, filepath.Join(t.TempDir(), "missing", "traces.json"))
+	_, err = FromEnv("travel-booking")
+	require.ErrorContains(t, err, "open traces file")
+}
+
+func TestRegister(t *testing.T) {
+	tel := New(Options{})
+	breakerState := prometheus.NewGauge(prometheus.GaugeOpts{Name: "provider_circuit_breaker_state"})
+	breakerState.Set(2)
+	require.NoError(t, tel.Register(breakerState))
+	require.Contains(t, scrape(t, tel), "provider_circuit_breaker_state 2")
+	require.Error(t, tel.Register(breakerState), "registered twice")
+}
+
+func TestIsCompensation(t *testing.T) {
+	for activityType, want := range map[string]bool{
+		"CancelHotelActivity":   true,
+		"VoidPaymentActivity":   true,
+		"RefundPaymentActivity": true,
+		"ReleaseHoldActivity":   true,
+		"BookHotelActivity":     false,
+		"TearDownMachine":       false,
+	} {
+		require.Equal(t, want, IsCompensation(activityType), activityType)
+	}
+}
@@ -0,0 +1,136 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+)
+
+type workerInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	t *Telemetry
+}
+
+func (w *workerInterceptor) InterceptActivity(_ context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityInbound{ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next}, t: w.t}
+}
+
+func (w *workerInterceptor) InterceptWorkflow(_ workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &workflowInbound{WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next}, t: w.t}
+}
+
+// activityInbound times every activity attempt in its own span
+type activityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+	t *Telemetry
+}
+
+func (a *activityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (any, error) {
+	info := activity.GetInfo(ctx)
+	activityType := info.ActivityType.Name
+	if info.Attempt > 1 {
+		a.t.metrics.activityRetries.WithLabelValues(activityType).Inc()
+	}
+
+	ctx, span := a.t.tracer.Start(extract(ctx, interceptor.Header(ctx)), "RunActivity:"+activityType,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("temporal.workflow_id", info.WorkflowExecution.ID),
+			attribute.String("temporal.run_id", info.WorkflowExecution.RunID),
+			attribute.String("temporal.activity_id", info.ActivityID),
+			attribute.Int("temporal.attempt", int(info.Attempt)),
+		))
+	start := time.Now()
+	result, err := a.Next.ExecuteActivity(ctx, in)
+
+	a.t.metrics.activityDuration.WithLabelValues(activityType, outcome(err)).Observe(time.Since(start).Seconds())
+	endSpan(span, err)
+	return result, err
+}
+
+// workflowInbound records a workflow once it closes. Nothing is recorded
+// while replaying, so a workflow is only counted by the worker that ran it to
+// the end. The workflow span is written then, backdated to the start.
+type workflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+	t *Telemetry
+	// parent carries the span context of the client call that started the
+	// workflow, passed on to its activities and child workflows
+	parent context.Context
+}
+
+func (w *workflowInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	return w.Next.Init(&workflowOutbound{WorkflowOutboundInterceptorBase: interceptor.WorkflowOutboundInterceptorBase{Next: outbound}, inbound: w})
+}
+
+func (w *workflowInbound) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (any, error) {
+	w.parent = extract(context.Background(), interceptor.WorkflowHeader(ctx))
+	result, err := w.Next.ExecuteWorkflow(ctx, in)
+	if workflow.IsReplaying(ctx) {
+		return result, err
+	}
+
+	info := workflow.GetInfo(ctx)
+	workflowType := info.WorkflowType.Name
+	start, end := info.WorkflowStartTime, workflow.Now(ctx)
+	w.t.metrics.workflowDuration.WithLabelValues(workflowType, outcome(err)).Observe(end.Sub(start).Seconds())
+
+	_, span := w.t.tracer.Start(w.parent, "RunWorkflow:"+workflowType,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("temporal.workflow_id", info.WorkflowExecution.ID),
+			attribute.String("temporal.run_id", info.WorkflowExecution.RunID),
+		))
+	if err != nil && outcome(err) != outcomeContinuedAsNew {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+	return result, err
+}
+
+// workflowOutbound counts compensations and passes the trace on
+type workflowOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+	inbound *workflowInbound
+}
+
+func (o *workflowOutbound) ExecuteActivity(ctx workflow.Context, activityType string, args ...any) workflow.Future {
+	o.countCompensation(ctx, activityType)
+	inject(o.inbound.parent, interceptor.WorkflowHeader(ctx))
+	return o.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (o *workflowOutbound) ExecuteLocalActivity(ctx workflow.Context, activityType string, args ...any) workflow.Future {
+	o.countCompensation(ctx, activityType)
+	inject(o.inbound.parent, interceptor.WorkflowHeader(ctx))
+	return o.Next.ExecuteLocalActivity(ctx, activityType, args...)
+}
+
+func (o *workflowOutbound) ExecuteChildWorkflow(ctx workflow.Context, childWorkflowType string, args ...any) workflow.ChildWorkflowFuture {
+	inject(o.inbound.parent, interceptor.WorkflowHeader(ctx))
+	return o.Next.ExecuteChildWorkflow(ctx, childWorkflowType, args...)
+}
+
+func (o *workflowOutbound) countCompensation(ctx workflow.Context, activityType string) {
+	if !o.inbound.t.compensation(activityType) || workflow.IsReplaying(ctx) {
+		return
+	}
+	o.inbound.t.metrics.compensations.WithLabelValues(workflow.GetInfo(ctx).WorkflowType.Name, activityType).Inc()
+}
+
+// endSpan ends span, marking it failed by err
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}